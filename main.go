@@ -1,21 +1,26 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
+	"os"
 	"strconv"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/kosha/passthrough-connector/pkg/app"
+	"github.com/kosha/passthrough-connector/pkg/config"
 	"github.com/kosha/passthrough-connector/pkg/logger"
+	"github.com/kosha/passthrough-connector/pkg/recorder"
 )
 
 var (
-	log  = logger.New("app", "passthrough-connector")
-	port = 8010
+	log = logger.New("app", "passthrough-connector")
 )
 
 type responseWriter struct {
@@ -37,7 +42,7 @@ var totalRequests = prometheus.NewCounterVec(
 		Name: "http_requests_total",
 		Help: "Number of get requests.",
 	},
-	[]string{"path"},
+	[]string{"route", "operation"},
 )
 
 var responseStatus = prometheus.NewCounterVec(
@@ -51,23 +56,29 @@ var responseStatus = prometheus.NewCounterVec(
 var httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 	Name: "http_response_time_seconds",
 	Help: "Duration of HTTP requests.",
-}, []string{"path"})
+}, []string{"route", "operation"})
 
 func prometheusMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		//route := mux.CurrentRoute(r)
-		//path, _ := route.GetPathTemplate()
-
-		timer := prometheus.NewTimer(httpDuration.WithLabelValues(r.URL.Path))
+		start := time.Now()
+		r, op := app.TrackOperation(r)
 		rw := NewResponseWriter(w)
 		next.ServeHTTP(rw, r)
 
+		// the connector's own endpoints are labelled by their path template, proxied
+		// requests by their route and operation so IDs in paths do not become labels
+		route, operation := op.Route, op.Name
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil && template != "/" {
+				route, operation = "", template
+			}
+		}
+
 		statusCode := rw.statusCode
 
 		responseStatus.WithLabelValues(strconv.Itoa(statusCode)).Inc()
-		totalRequests.WithLabelValues(r.URL.Path).Inc()
-
-		timer.ObserveDuration()
+		totalRequests.WithLabelValues(route, operation).Inc()
+		httpDuration.WithLabelValues(route, operation).Observe(time.Since(start).Seconds())
 	})
 }
 
@@ -77,6 +88,62 @@ func init() {
 	prometheus.Register(httpDuration)
 }
 
+// inferAPI prints the OpenAPI document inferred from recorded traffic, for the openapi command
+func inferAPI(args []string) error {
+	flags := flag.NewFlagSet("openapi", flag.ExitOnError)
+	file := flags.String("recording", "", "File the traffic was recorded to, see RECORDING_FILE")
+	route := flags.String("route", "", "Route to print the document of, required when several routes were recorded")
+	flags.Parse(args)
+
+	if *file == "" {
+		return fmt.Errorf("-recording is required")
+	}
+	recording, err := recorder.Open(*file)
+	if err != nil {
+		return err
+	}
+	if *route == "" {
+		routes := recording.Routes()
+		if len(routes) != 1 {
+			names := make([]string, 0, len(routes))
+			for _, summary := range routes {
+				names = append(names, summary.Route)
+			}
+			return fmt.Errorf("%s holds %d recorded routes %v, choose one with -route", *file, len(routes), names)
+		}
+		*route = routes[0].Route
+	}
+	doc, err := recording.Document(*route)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc.OpenAPI())
+}
+
+// serveMCP serves the operations of the routes' OpenAPI documents as Model Context Protocol
+// tools over stdio, for the mcp command. Logs go to stderr, stdout carries the protocol.
+func serveMCP(args []string) error {
+	cfg, err := config.Load(args)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cfg.GetAuditLog() == "stdout" {
+		return fmt.Errorf("AUDIT_LOG cannot be stdout, which carries the protocol")
+	}
+
+	a := app.App{Cfg: cfg}
+	a.Initialize(log)
+	a.Router.Use(prometheusMiddleware)
+	a.InitializeRoutes()
+	a.WatchConfig(cfg.GetConfigWatchInterval())
+	return a.ServeMCP(os.Stdin, os.Stdout)
+}
+
 // @title Passthrough Connector API
 // @version 2.0
 // @description This is a Kosha REST serice for exposing features as passthrough REST APIs with better consistency, observability etc
@@ -87,16 +154,42 @@ func init() {
 // @BasePath /
 func main() {
 
-	a := app.App{}
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "openapi":
+			if err := inferAPI(os.Args[2:]); err != nil {
+				log.Fatalf("Unable to infer the OpenAPI document: %v", err)
+			}
+			return
+		case "mcp":
+			if err := serveMCP(os.Args[2:]); err != nil {
+				log.Fatalf("Unable to serve the MCP tools: %v", err)
+			}
+			return
+		}
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Unable to load configuration: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Refusing to start, %v", err)
+	}
+
+	a := app.App{Cfg: cfg}
 	a.Initialize(log)
 	a.Router.Use(prometheusMiddleware)
-
-	// Prometheus metrics endpoint
-	a.Router.HandleFunc("/metrics", promhttp.Handler().ServeHTTP)
-
 	a.InitializeRoutes()
+	a.WatchConfig(cfg.GetConfigWatchInterval())
+
+	if adminPort, _, _ := cfg.GetAdmin(); adminPort > 0 {
+		a.InitializeAdminRoutes()
+		log.Infof("Running admin API on port %d", adminPort)
+		go a.RunAdmin(fmt.Sprintf(":%d", adminPort))
+	}
 
-	log.Infof("Running passthrough-connector on port %d", port)
-	a.Run(fmt.Sprintf(":%d", port))
+	log.Infof("Running passthrough-connector on port %d", cfg.GetPort())
+	a.Run(fmt.Sprintf(":%d", cfg.GetPort()))
 
 }
@@ -0,0 +1,253 @@
+package app
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/kosha/passthrough-connector/pkg/config"
+	"github.com/kosha/passthrough-connector/pkg/store"
+)
+
+// InitializeAdminRoutes registers the admin API, which manages upstream routes at runtime.
+// It does nothing when the admin API is disabled.
+func (a *App) InitializeAdminRoutes() {
+	if a.AdminRouter == nil {
+		return
+	}
+	admin := a.AdminRouter.PathPrefix(a.current().cfg.GetReservedPrefix() + "/admin").Subrouter()
+	admin.Use(a.adminAuthMiddleware)
+	admin.HandleFunc("/connectors", a.listConnectors).Methods("GET")
+	admin.HandleFunc("/connectors", a.createConnector).Methods("POST")
+	admin.HandleFunc("/connectors/{name}", a.getConnector).Methods("GET")
+	admin.HandleFunc("/connectors/{name}", a.updateConnector).Methods("PUT")
+	admin.HandleFunc("/connectors/{name}", a.deleteConnector).Methods("DELETE")
+	admin.HandleFunc("/recording", a.listRecordings).Methods("GET")
+	admin.HandleFunc("/recording/{route}", a.getRecording).Methods("GET")
+	admin.HandleFunc("/recording/{route}", a.deleteRecording).Methods("DELETE")
+}
+
+// RunAdmin serves the admin API on the specified addr
+func (a *App) RunAdmin(addr string) {
+	log.Fatal(http.ListenAndServe(addr, a.AdminRouter))
+}
+
+// adminAuthMiddleware requires the admin token as a bearer token
+func (a *App) adminAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, token, _ := a.current().cfg.GetAdmin()
+		header := r.Header.Get("Authorization")
+		if token == "" || !strings.HasPrefix(header, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, "Bearer ")), []byte(token)) != 1 {
+			respondWithError(w, http.StatusUnauthorized, "invalid admin token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// listConnectors returns every route managed through the admin API, without credentials
+func (a *App) listConnectors(w http.ResponseWriter, r *http.Request) {
+	routes := a.store.List()
+	for i := range routes {
+		routes[i] = withoutSecrets(routes[i])
+	}
+	respondWithJSON(w, http.StatusOK, routes)
+}
+
+func (a *App) getConnector(w http.ResponseWriter, r *http.Request) {
+	route, err := a.store.Get(mux.Vars(r)["name"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, withoutSecrets(route))
+}
+
+// createConnector adds a route, failing when a route with the same name already exists
+func (a *App) createConnector(w http.ResponseWriter, r *http.Request) {
+	var route config.Route
+	if err := json.NewDecoder(r.Body).Decode(&route); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid connector: "+err.Error())
+		return
+	}
+	if route.Name == "" {
+		route.Name = strings.Trim(route.Prefix, "/")
+	}
+	a.saveConnector(w, route, true)
+}
+
+// updateConnector creates or replaces a route. Credentials left out of the request keep
+// their stored value, so a definition can be changed without resending its secrets.
+func (a *App) updateConnector(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	var route config.Route
+	if err := json.NewDecoder(r.Body).Decode(&route); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid connector: "+err.Error())
+		return
+	}
+	if route.Name == "" {
+		route.Name = name
+	}
+	if route.Name != name {
+		respondWithError(w, http.StatusBadRequest, "connector name does not match the url")
+		return
+	}
+	a.saveConnector(w, route, false)
+}
+
+func (a *App) deleteConnector(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	existing, _ := a.store.Get(name)
+	if err := a.store.Delete(name); err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			code = http.StatusNotFound
+		}
+		respondWithError(w, code, err.Error())
+		return
+	}
+	if err := a.applyStore(); err != nil {
+		// the connector is still served, so it is kept
+		if rollback := a.store.Put(existing); rollback != nil {
+			a.Log.Errorf("Unable to restore connector %s: %v", name, rollback)
+		}
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a.Log.Infow("Connector deleted", "connector", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// saveConnector validates and stores route, then applies it to the running connector. A
+// route is created, failing when one with the same name exists, or else created or replaced
+// keeping the stored credentials it leaves out. The stored route is read under a.mu so
+// concurrent requests cannot both create it.
+func (a *App) saveConnector(w http.ResponseWriter, route config.Route, create bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	code := http.StatusOK
+	existing, err := a.store.Get(route.Name)
+	stored := err == nil
+	switch {
+	case create && err == nil:
+		respondWithError(w, http.StatusConflict, "connector "+route.Name+" already exists")
+		return
+	case create:
+		code = http.StatusCreated
+	case err == nil:
+		route.Auth = keepSecrets(route.Auth, existing.Auth)
+		for name, auth := range route.Credentials {
+			route.Credentials[name] = keepSecrets(auth, existing.Credentials[name])
+		}
+	}
+
+	if err := config.ValidateRoute(route); err != nil {
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			respondWithJSON(w, http.StatusBadRequest, map[string]interface{}{"error": "invalid connector", "problems": invalid.Problems})
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if cfg := a.current().cfg; cfg.Reserves(route.Prefix) {
+		respondWithError(w, http.StatusConflict, "prefix "+route.Prefix+" collides with the reserved prefix "+cfg.GetReservedPrefix())
+		return
+	}
+	for _, other := range a.current().routes {
+		if other.Name == route.Name {
+			if _, err := a.store.Get(route.Name); err != nil {
+				respondWithError(w, http.StatusConflict, "route "+route.Name+" is defined in the configuration")
+				return
+			}
+			continue
+		}
+		if strings.TrimSuffix(other.Prefix, "/") == strings.TrimSuffix(route.Prefix, "/") {
+			respondWithError(w, http.StatusConflict, "prefix "+route.Prefix+" is already used by route "+other.Name)
+			return
+		}
+	}
+
+	if err := a.store.Put(route); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := a.applyStore(); err != nil {
+		// the connector is not served, so it is not kept either
+		var rollback error
+		if stored {
+			rollback = a.store.Put(existing)
+		} else {
+			rollback = a.store.Delete(route.Name)
+		}
+		if rollback != nil {
+			a.Log.Errorf("Unable to restore connector %s: %v", route.Name, rollback)
+		}
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a.Log.Infow("Connector saved", "connector", route.Name, "prefix", route.Prefix)
+	respondWithJSON(w, code, withoutSecrets(route))
+}
+
+// applyStore rebuilds the active state with the stored routes. The caller holds a.mu.
+func (a *App) applyStore() error {
+	old := a.current()
+	st, err := a.newState(old.cfg, old)
+	if err != nil {
+		return err
+	}
+	a.apply(st)
+	return nil
+}
+
+// withoutSecrets removes the credentials of route, which are never returned by the admin API
+func withoutSecrets(route config.Route) config.Route {
+	route.Auth = authWithoutSecrets(route.Auth)
+	if route.Credentials != nil {
+		credentials := make(map[string]config.Auth, len(route.Credentials))
+		for name, auth := range route.Credentials {
+			credentials[name] = authWithoutSecrets(auth)
+		}
+		route.Credentials = credentials
+	}
+	return route
+}
+
+func authWithoutSecrets(auth config.Auth) config.Auth {
+	auth.ApiKey = ""
+	auth.BearerToken = ""
+	auth.Password = ""
+	auth.SKey = ""
+	auth.AccessToken = ""
+	auth.RefreshToken = ""
+	auth.ClientSecret = ""
+	return auth
+}
+
+// keepSecrets fills the credentials missing from auth with those of existing
+func keepSecrets(auth, existing config.Auth) config.Auth {
+	keep := func(value *string, previous string) {
+		if *value == "" {
+			*value = previous
+		}
+	}
+	keep(&auth.ApiKey, existing.ApiKey)
+	keep(&auth.BearerToken, existing.BearerToken)
+	keep(&auth.Password, existing.Password)
+	keep(&auth.SKey, existing.SKey)
+	keep(&auth.AccessToken, existing.AccessToken)
+	keep(&auth.RefreshToken, existing.RefreshToken)
+	keep(&auth.ClientSecret, existing.ClientSecret)
+	return auth
+}
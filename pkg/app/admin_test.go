@@ -0,0 +1,248 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+	"github.com/kosha/passthrough-connector/pkg/store"
+)
+
+func TestAdminConnectors(t *testing.T) {
+	upstream := echoServer()
+	defer upstream.Close()
+
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("AUTH_TYPE", "")
+	t.Setenv("ROUTES", `[{"prefix": "/other", "serverUrl": "example.com"}]`)
+	t.Setenv("ADMIN_PORT", "8011")
+	t.Setenv("ADMIN_TOKEN", "admin-secret")
+	t.Setenv("ADMIN_STORE", filepath.Join(t.TempDir(), "connectors.json"))
+	a := App{Log: logging, Cfg: config.Get()}
+	a.Initialize(logging)
+	a.InitializeAdminRoutes()
+	proxy := a.requestInfoMiddleware(a.commonMiddleware())
+
+	admin := func(method, path, token, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		a.AdminRouter.ServeHTTP(rr, req)
+		var res map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		return rr.Code, res
+	}
+	proxied := func(uri string) (int, map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		proxy.ServeHTTP(rr, httptest.NewRequest("GET", uri, nil))
+		var res map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		return rr.Code, res
+	}
+
+	if code, _ := admin("GET", "/_connector/admin/connectors", "wrong", ""); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with a wrong token, got %d", code)
+	}
+	req := httptest.NewRequest("GET", "/_connector/admin/connectors", nil)
+	req.Header.Set("Authorization", "admin-secret")
+	rr := httptest.NewRecorder()
+	if a.AdminRouter.ServeHTTP(rr, req); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the bearer scheme, got %d", rr.Code)
+	}
+	if code, _ := proxied("/fs/tickets"); code != http.StatusNotFound {
+		t.Errorf("expected 404 before the connector exists, got %d", code)
+	}
+
+	code, res := admin("POST", "/_connector/admin/connectors", "admin-secret", `{"name": "fs", "prefix": "/fs", "serverUrl": "`+upstream.URL+`/api/v2",
+		"auth": {"type": "API_KEY", "apiKey": "key-1", "apiKeyHeaderName": "X-Key"}}`)
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201 creating a connector, got %d: %v", code, res)
+	}
+	if auth := res["auth"].(map[string]interface{}); auth["apiKey"] != nil {
+		t.Errorf("expected the api key not to be returned, got %v", auth)
+	}
+
+	code, res = proxied("/fs/tickets")
+	if code != http.StatusOK || res["path"] != "/api/v2/tickets" {
+		t.Fatalf("expected the new connector to be applied live, got %d: %v", code, res)
+	}
+	if got := res["headers"].(map[string]interface{})["X-Key"]; got == nil || got.([]interface{})[0] != "key-1" {
+		t.Errorf("expected the api key to be sent upstream, got %v", got)
+	}
+
+	// changing the definition without credentials keeps the stored key
+	if code, res = admin("PUT", "/_connector/admin/connectors/fs", "admin-secret", `{"prefix": "/freshservice", "serverUrl": "`+upstream.URL+`/api/v2",
+		"auth": {"type": "API_KEY", "apiKeyHeaderName": "X-Key"}}`); code != http.StatusOK {
+		t.Fatalf("expected 200 updating a connector, got %d: %v", code, res)
+	}
+	code, res = proxied("/freshservice/tickets")
+	if got := res["headers"].(map[string]interface{})["X-Key"]; code != http.StatusOK || got == nil || got.([]interface{})[0] != "key-1" {
+		t.Errorf("expected the stored api key to be kept, got %d: %v", code, res)
+	}
+
+	if code, res = admin("POST", "/_connector/admin/connectors", "admin-secret", `{"name": "bad", "prefix": "/bad", "serverUrl": "ftp://example.com",
+		"auth": {"type": "HMAC"}}`); code != http.StatusBadRequest || len(res["problems"].([]interface{})) != 3 {
+		t.Errorf("expected 400 listing 3 problems, got %d: %v", code, res)
+	}
+	if code, _ = admin("POST", "/_connector/admin/connectors", "admin-secret", `{"name": "fs2", "prefix": "/freshservice/", "serverUrl": "example.com"}`); code != http.StatusConflict {
+		t.Errorf("expected 409 reusing a prefix, got %d", code)
+	}
+
+	// the store survives a restart
+	_, _, path := a.Cfg.GetAdmin()
+	s, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.List()) != 1 {
+		t.Errorf("expected the connector to be persisted, got %+v", s.List())
+	}
+
+	if code, _ = admin("DELETE", "/_connector/admin/connectors/fs", "admin-secret", ""); code != http.StatusNoContent {
+		t.Errorf("expected 204 deleting a connector, got %d", code)
+	}
+	if code, _ = proxied("/freshservice/tickets"); code != http.StatusNotFound {
+		t.Errorf("expected 404 after the connector was deleted, got %d", code)
+	}
+
+	// concurrent creations of a connector create it once
+	var wg sync.WaitGroup
+	codes := make([]int, 8)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i], _ = admin("POST", "/_connector/admin/connectors", "admin-secret", fmt.Sprintf(`{"name": "race", "prefix": "/race%d", "serverUrl": "example.com"}`, i))
+		}(i)
+	}
+	wg.Wait()
+	created := 0
+	for _, code := range codes {
+		if code == http.StatusCreated {
+			created++
+		} else if code != http.StatusConflict {
+			t.Errorf("expected 409 creating an existing connector, got %d", code)
+		}
+	}
+	if created != 1 {
+		t.Errorf("expected the connector to be created once, got %v", codes)
+	}
+}
+
+func TestRecordTraffic(t *testing.T) {
+	upstream := echoServer()
+	defer upstream.Close()
+
+	t.Setenv("SERVER_URL", upstream.URL)
+	t.Setenv("AUTH_TYPE", "API_KEY")
+	t.Setenv("API_KEY", "secret")
+	t.Setenv("API_KEY_IN", "query")
+	t.Setenv("API_KEY_HEADER_NAME", "api_key")
+	t.Setenv("ROUTES", "")
+	t.Setenv("RECORD_TRAFFIC", "true")
+	t.Setenv("ADMIN_PORT", "8011")
+	t.Setenv("ADMIN_TOKEN", "admin-secret")
+	t.Setenv("ADMIN_STORE", filepath.Join(t.TempDir(), "connectors.json"))
+	a := App{Log: logging, Cfg: config.Get()}
+	a.Initialize(logging)
+	a.InitializeAdminRoutes()
+
+	for _, uri := range []string{"/tickets/7?page=2", "/tickets/8"} {
+		rr := httptest.NewRecorder()
+		a.commonMiddleware().ServeHTTP(rr, httptest.NewRequest("GET", uri, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	admin := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		rr := httptest.NewRecorder()
+		a.AdminRouter.ServeHTTP(rr, req)
+		return rr
+	}
+	rr := admin("GET", "/_connector/admin/recording/default")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+	}
+	var doc map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &doc)
+	get, ok := doc["paths"].(map[string]interface{})["/tickets/{ticketId}"].(map[string]interface{})["get"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected the path template to be inferred, got %v", doc["paths"])
+	}
+	// the api key injected in the query is not recorded
+	var params []string
+	for _, param := range get["parameters"].([]interface{}) {
+		params = append(params, param.(map[string]interface{})["name"].(string))
+	}
+	if strings.Join(params, ",") != "ticketId,page" {
+		t.Errorf("expected the path parameter and the caller's query, got %v", params)
+	}
+
+	if rr := admin("DELETE", "/_connector/admin/recording/default"); rr.Code != http.StatusNoContent {
+		t.Errorf("expected the recording to be deleted, got %d", rr.Code)
+	}
+	if rr := admin("GET", "/_connector/admin/recording/default"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected nothing recorded, got %d", rr.Code)
+	}
+}
+
+func TestAdminConnectorNotApplied(t *testing.T) {
+	tenants := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(tenants, []byte(`{"acme": {"serverUrl": "https://acme.example.com", "routes": ["other"]}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("AUTH_TYPE", "")
+	t.Setenv("ROUTES", `[{"name": "other", "prefix": "/other", "serverUrl": "example.com"}]`)
+	t.Setenv("TENANTS_FILE", tenants)
+	t.Setenv("TENANT_SOURCE", "header")
+	t.Setenv("ADMIN_PORT", "8011")
+	t.Setenv("ADMIN_TOKEN", "admin-secret")
+	t.Setenv("ADMIN_STORE", filepath.Join(t.TempDir(), "connectors.json"))
+	a := App{Log: logging, Cfg: config.Get()}
+	a.Initialize(logging)
+	a.InitializeAdminRoutes()
+
+	admin := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		rr := httptest.NewRecorder()
+		a.AdminRouter.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := admin("POST", "/_connector/admin/connectors", `{"name": "fs", "prefix": "/fs", "serverUrl": "example.com"}`); code != http.StatusCreated {
+		t.Fatalf("expected 201 creating a connector, got %d", code)
+	}
+
+	// a state that cannot be built keeps the store as it was
+	if err := os.WriteFile(tenants, []byte(`{`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if code := admin("POST", "/_connector/admin/connectors", `{"name": "crm", "prefix": "/crm", "serverUrl": "example.com"}`); code != http.StatusInternalServerError {
+		t.Errorf("expected 500 when the connector cannot be applied, got %d", code)
+	}
+	if code := admin("PUT", "/_connector/admin/connectors/fs", `{"prefix": "/freshservice", "serverUrl": "example.com"}`); code != http.StatusInternalServerError {
+		t.Errorf("expected 500 when the connector cannot be applied, got %d", code)
+	}
+	if code := admin("DELETE", "/_connector/admin/connectors/fs", ""); code != http.StatusInternalServerError {
+		t.Errorf("expected 500 when the deletion cannot be applied, got %d", code)
+	}
+	_, _, path := a.Cfg.GetAdmin()
+	s, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if routes := s.List(); len(routes) != 1 || routes[0].Prefix != "/fs" {
+		t.Errorf("expected only the applied connector to be stored, got %+v", routes)
+	}
+}
@@ -4,14 +4,38 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/kosha/passthrough-connector/pkg/config"
 	"github.com/kosha/passthrough-connector/pkg/logger"
+	"github.com/kosha/passthrough-connector/pkg/recorder"
+	"github.com/kosha/passthrough-connector/pkg/store"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 )
 
 type App struct {
 	Router *mux.Router
 	Log    logger.Logger
-	Cfg    *config.Config
+	// Cfg is the configuration the app was started with, see current for the active one
+	Cfg *config.Config
+	// AdminRouter serves the admin API when ADMIN_PORT is set
+	AdminRouter *mux.Router
+
+	// mu serializes state changes, reloads and admin API updates
+	mu    sync.Mutex
+	state atomic.Value
+	store *store.Store
+	// tokens caches OAuth2 access tokens refreshed from token endpoints
+	tokens tokenCache
+	// drift accumulates the fields of validated responses for the drift report
+	drift driftTracker
+	// recorder records the structure of proxied calls when RECORD_TRAFFIC is set
+	recorder *recorder.Recorder
+	// saving starts the periodic saves of the recorder once
+	saving sync.Once
 }
 
 func router() *mux.Router {
@@ -29,34 +53,83 @@ func router() *mux.Router {
 //	})
 //}
 
-// Initialize creates the necessary scaffolding of the app
+// Initialize creates the necessary scaffolding of the app. The configuration is read from
+// the environment unless Cfg was already set.
 func (a *App) Initialize(log logger.Logger) {
 
-	cfg := config.Get()
+	cfg := a.Cfg
+	if cfg == nil {
+		cfg = config.Get()
+	}
 
 	a.Cfg = cfg
-	a.Log = log
-
-	//ctx := context.Background()
-	//loader := &openapi3.Loader{Context: ctx, IsExternalRefsAllowed: true}
-	//u, _ := url.Parse("https://petstore.swagger.io/v2/swagger.json")
-	//doc, _ := loader.LoadFromURI(u)
-	//// Validate OAS document
-	//err := doc.Validate(ctx)
-	//if err != nil {
-	//	a.Log.Error(err)
-	//}
-	//
-	//// check what the security scheme that is being used
-	//securitySchema := doc.Components.SecuritySchemes
-	//for name, schema := range securitySchema {
-	//	name
-	//	schema.Value.
-	//}
+	if port, _, path := cfg.GetAdmin(); port > 0 {
+		s, err := store.Open(path)
+		if err != nil {
+			log.Fatalf("Unable to open the admin store: %v", err)
+		}
+		a.store = s
+		a.AdminRouter = router()
+	}
+	st, err := a.newState(cfg, nil)
+	if err != nil {
+		log.Fatalf("Unable to initialize the connector: %v", err)
+	}
+	a.apply(st)
+	a.Log = logger.WithRedaction(log, stateRedactor{a})
+
+	for file, api := range st.apis {
+		a.Log.Infof("Loaded the OpenAPI document %s, %s %s with %d operations", file, api.Title, api.Version, len(api.Operations))
+	}
+
+	// the recorder exists even when recording is off so it can be turned on by a reload
+	_, recordingFile := cfg.GetRecording()
+	if a.recorder, err = recorder.Open(recordingFile); err != nil {
+		log.Fatalf("Unable to open the recorded traffic: %v", err)
+	}
+	a.followRecording(cfg)
 	a.Router = router()
 }
 
 // Run starts the app and serves on the specified addr
 func (a *App) Run(addr string) {
-	log.Fatal(http.ListenAndServe(addr, a.Router))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if a.current().cfg.GetProxyProtocol() {
+		listener = newProxyProtoListener(listener, func() []*net.IPNet {
+			if filter := a.current().ipFilter; filter != nil {
+				return filter.trusted
+			}
+			return nil
+		})
+	}
+	a.closeOnSignal()
+	err = http.Serve(listener, a.Router)
+	a.Close()
+	log.Fatal(err)
+}
+
+// Close saves the recorded traffic, it is called before the connector exits
+func (a *App) Close() error {
+	if a.recorder == nil {
+		return nil
+	}
+	if err := a.recorder.Save(); err != nil {
+		a.Log.Errorf("Unable to save the recorded traffic: %v", err)
+		return err
+	}
+	return nil
+}
+
+// closeOnSignal closes the app and exits when the process is interrupted or terminated
+func (a *App) closeOnSignal() {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		a.Close()
+		os.Exit(0)
+	}()
 }
@@ -33,9 +33,9 @@ func TestNoAuth(t *testing.T) {
 
 	cfg := config.Get()
 	a := App{
-		r,
-		logging,
-		cfg,
+		Router: r,
+		Log:    logging,
+		Cfg:    cfg,
 	}
 	a.TestCommonMiddlewareNoAuth(t)
 }
@@ -48,9 +48,9 @@ func TestApiKeyAuthCustomHeader(t *testing.T) {
 
 	cfg := config.Get()
 	a := App{
-		r,
-		logging,
-		cfg,
+		Router: r,
+		Log:    logging,
+		Cfg:    cfg,
 	}
 	a.TestCommonMiddlewareApiKeyCustomHeader(t)
 }
@@ -62,9 +62,9 @@ func TestApiKeyAuthDefaultHeader(t *testing.T) {
 
 	cfg := config.Get()
 	a := App{
-		r,
-		logging,
-		cfg,
+		Router: r,
+		Log:    logging,
+		Cfg:    cfg,
 	}
 	a.TestCommonMiddlewareApiKeyDefaultHeader(t)
 }
@@ -77,9 +77,9 @@ func TestBearerTokenAuth(t *testing.T) {
 
 	cfg := config.Get()
 	a := App{
-		r,
-		logging,
-		cfg,
+		Router: r,
+		Log:    logging,
+		Cfg:    cfg,
 	}
 
 	a.TestCommonMiddlewareBearerToken(t)
@@ -94,9 +94,9 @@ func TestBasicAuth(t *testing.T) {
 
 	cfg := config.Get()
 	a := App{
-		r,
-		logging,
-		cfg,
+		Router: r,
+		Log:    logging,
+		Cfg:    cfg,
 	}
 
 	a.TestCommonMiddlewareBasicAuth(t)
@@ -129,9 +129,9 @@ func TestOAuth(t *testing.T) {
 
 	cfg := config.Get()
 	a := App{
-		r,
-		logging,
-		cfg,
+		Router: r,
+		Log:    logging,
+		Cfg:    cfg,
 	}
 
 	a.TestCommonMiddlewareOAuth(t)
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/kosha/passthrough-connector/pkg/audit"
+)
+
+// auditMiddleware writes one audit entry per proxied call when an audit sink is configured
+func (a *App) auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := a.stateOf(r)
+		if st.audit == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		info := getRequestInfo(r)
+
+		entry := &audit.Entry{
+			Timestamp: start.UTC(),
+			RequestID: info.RequestID,
+			Caller:    info.Caller,
+			Method:    r.Method,
+		}
+		if r.ContentLength > 0 {
+			entry.BytesIn = r.ContentLength
+		}
+		if st.cfg.GetAuditHashBodies() && isMutating(r.Method) && r.Body != nil {
+			body, err := ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				a.Log.Errorf("unable to read request body for audit: %v", err)
+			}
+			sum := sha256.Sum256(body)
+			entry.BodySHA256 = hex.EncodeToString(sum[:])
+			entry.BytesIn = int64(len(body))
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		sw := newStatusWriter(w)
+		next.ServeHTTP(sw, r)
+
+		entry.Tenant = info.Tenant
+		entry.Operation = info.Operation
+		entry.URL = audit.StripURL(info.UpstreamURL)
+		entry.Status = sw.status
+		entry.BytesOut = sw.bytes
+		entry.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+		if err := st.audit.Write(entry); err != nil {
+			a.Log.Errorf("unable to write audit entry: %v", err)
+		}
+	})
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// callerIdentity returns a stable label for the caller of a request, which quotas and audit
+// entries are keyed on. Only identities the caller cannot choose are used, in order: the
+// configured caller header when it was set by a trusted proxy, the subject of a bearer JWT
+// signed with the JWT secret, the caller a configured key in the key header belongs to, and
+// finally the client address.
+func callerIdentity(r *http.Request, callerHeader string, fromTrustedProxy bool, jwtSecret, keyHeader string, keys map[string]string, clientIP string) string {
+	if callerHeader != "" && fromTrustedProxy {
+		if caller := r.Header.Get(callerHeader); caller != "" {
+			return caller
+		}
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		if sub, _ := verifiedClaims(strings.TrimPrefix(auth, "Bearer "), jwtSecret)["sub"].(string); sub != "" {
+			return "jwt:" + sub
+		}
+	}
+	if key := r.Header.Get(keyHeader); key != "" && len(keys) > 0 {
+		hash := sha256.Sum256([]byte(key))
+		if caller, ok := keys[hex.EncodeToString(hash[:])]; ok {
+			return "key:" + caller
+		}
+	}
+	return "ip:" + clientIP
+}
+
+// jwtClaims decodes the claims of a JWT without verifying its signature
+func jwtClaims(token string) map[string]interface{} {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil
+	}
+	return claims
+}
+
+// verifiedClaims returns the claims of a JWT signed with secret using HS256, HS384 or HS512,
+// or nil when the signature does not match or the token is expired or not yet valid
+func verifiedClaims(token, secret string) map[string]interface{} {
+	parts := strings.Split(token, ".")
+	if secret == "" || len(parts) != 3 {
+		return nil
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(raw, &header) != nil {
+		return nil
+	}
+	var newHash func() hash.Hash
+	switch header.Alg {
+	case "HS256":
+		newHash = sha256.New
+	case "HS384":
+		newHash = sha512.New384
+	case "HS512":
+		newHash = sha512.New
+	default:
+		// none and asymmetric algorithms are never accepted
+		return nil
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil
+	}
+
+	claims := jwtClaims(token)
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); ok && now >= exp {
+		return nil
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return nil
+	}
+	return claims
+}
+
+// requestID returns the caller supplied X-Request-Id or a new random id
+func requestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-Id"); id != "" {
+		return id
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
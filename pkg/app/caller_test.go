@@ -0,0 +1,51 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCallerIdentity(t *testing.T) {
+	f, err := newIPFilter(nil, nil, []string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"alice"}`))
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(unsigned))
+	signed := unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	hash := sha256.Sum256([]byte("billing-key"))
+	keys := map[string]string{hex.EncodeToString(hash[:]): "billing"}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     map[string]string
+		caller     string
+	}{
+		{"header from a trusted proxy", "10.0.0.1:1234", map[string]string{"X-Caller-Id": "billing"}, "billing"},
+		{"header from a client", "192.0.2.1:1234", map[string]string{"X-Caller-Id": "billing"}, "ip:192.0.2.1"},
+		{"signed token", "192.0.2.1:1234", map[string]string{"Authorization": "Bearer " + signed}, "jwt:alice"},
+		{"forged token", "192.0.2.1:1234", map[string]string{"Authorization": "Bearer " + unsigned + ".forged"}, "ip:192.0.2.1"},
+		{"api key", "192.0.2.1:1234", map[string]string{"X-Api-Key": "rotating"}, "ip:192.0.2.1"},
+		{"caller key", "192.0.2.1:1234", map[string]string{"X-Caller-Key": "billing-key"}, "key:billing"},
+		{"unknown caller key", "192.0.2.1:1234", map[string]string{"X-Caller-Key": "guessed"}, "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			if caller := callerIdentity(req, "X-Caller-Id", f.fromTrustedProxy(req), "secret", "X-Caller-Key", keys, f.clientIP(req).String()); caller != tt.caller {
+				t.Errorf("expected caller %s, got %s", tt.caller, caller)
+			}
+		})
+	}
+}
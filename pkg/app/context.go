@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kosha/passthrough-connector/pkg/parser"
+)
+
+type contextKey int
+
+const (
+	requestInfoKey contextKey = iota
+	operationKey
+	upstreamPathKey
+)
+
+// Unmatched labels requests that match no route or no operation of the route's OpenAPI document
+const Unmatched = "unmatched"
+
+// requestInfo carries per request details that are filled in while the request is
+// proxied and read back by the middlewares wrapping the proxy handler
+type requestInfo struct {
+	RequestID   string
+	ClientIP    string
+	Caller      string
+	Tenant      string
+	Route       string
+	Operation   string
+	UpstreamURL string
+
+	state *state
+}
+
+// requestInfoMiddleware identifies the request, its client and caller before any other middleware runs
+func (a *App) requestInfoMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := a.current()
+		info := &requestInfo{RequestID: requestID(r), state: st}
+		if ip := st.ipFilter.clientIP(r); ip != nil {
+			info.ClientIP = ip.String()
+		}
+		keyHeader, keys := st.cfg.GetCallerKeys()
+		info.Caller = callerIdentity(r, st.cfg.GetCallerIdHeader(), st.ipFilter.fromTrustedProxy(r), st.cfg.GetJWTSecret(), keyHeader, keys, info.ClientIP)
+		if len(keys) > 0 {
+			// caller keys identify callers to the connector and are not sent upstream
+			r.Header.Del(keyHeader)
+		}
+		w.Header().Set("X-Request-Id", info.RequestID)
+
+		next.ServeHTTP(w, withRequestInfo(r, info))
+	})
+}
+
+func withRequestInfo(r *http.Request, info *requestInfo) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey, info))
+}
+
+// getRequestInfo returns the request info attached to the request, or an empty one
+// when the handler is invoked without the wrapping middlewares (e.g. in tests)
+func getRequestInfo(r *http.Request) *requestInfo {
+	if info, ok := r.Context().Value(requestInfoKey).(*requestInfo); ok {
+		return info
+	}
+	return &requestInfo{}
+}
+
+// Operation is the route and the OpenAPI operation a proxied request was matched to. Both
+// are Unmatched until the request is matched, so they make bounded metric labels.
+type Operation struct {
+	Route string
+	Name  string
+}
+
+// TrackOperation returns r with an Operation that is filled in when the request is proxied,
+// for middlewares wrapping the router
+func TrackOperation(r *http.Request) (*http.Request, *Operation) {
+	op := &Operation{Route: Unmatched, Name: Unmatched}
+	return r.WithContext(context.WithValue(r.Context(), operationKey, op)), op
+}
+
+// withUpstreamPath marks the path of requests made with ctx as the upstream path, which the
+// route's rewrite rules are not applied to again, as for requests built from the operations of
+// the route's OpenAPI document
+func withUpstreamPath(ctx context.Context) context.Context {
+	return context.WithValue(ctx, upstreamPathKey, true)
+}
+
+func hasUpstreamPath(r *http.Request) bool {
+	marked, _ := r.Context().Value(upstreamPathKey).(bool)
+	return marked
+}
+
+// setOperation records the route and operation the request was matched to and returns the
+// operation's label
+func setOperation(r *http.Request, route string, op *parser.Operation) string {
+	name := Unmatched
+	if op != nil {
+		name = op.Name()
+	}
+	info := getRequestInfo(r)
+	info.Route, info.Operation = route, name
+	if tracked, ok := r.Context().Value(operationKey).(*Operation); ok {
+		tracked.Route, tracked.Name = route, name
+	}
+	return name
+}
+
+// statusWriter records the status code and number of bytes written to the response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func newStatusWriter(w http.ResponseWriter) *statusWriter {
+	return &statusWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
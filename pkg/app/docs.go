@@ -0,0 +1,102 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+	"github.com/kosha/passthrough-connector/pkg/parser"
+	httpSwagger "github.com/swaggo/http-swagger"
+)
+
+// docs serves the Swagger UI over the OpenAPI documents of the routes
+func (a *App) docs(w http.ResponseWriter, r *http.Request) {
+	st := a.stateOf(r)
+	// the UI resolves its assets relative to the docs directory
+	if r.URL.Path == st.cfg.GetReservedPrefix()+"/docs" {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		return
+	}
+	var urls []map[string]string
+	for _, route := range st.routes {
+		if route.OpenAPI == "" {
+			continue
+		}
+		title := route.Name
+		if api := st.apis[route.OpenAPI]; api.Title != "" {
+			title += " - " + api.Title
+		}
+		urls = append(urls, map[string]string{
+			"name": title,
+			"url":  st.cfg.GetReservedPrefix() + "/docs/openapi.json?route=" + route.Name,
+		})
+	}
+	if len(urls) == 0 {
+		respondWithError(w, http.StatusNotFound, "no route has an OpenAPI document")
+		return
+	}
+	// the values of the UI config are JavaScript
+	list, _ := json.Marshal(urls)
+	httpSwagger.Handler(
+		httpSwagger.URL(urls[0]["url"]),
+		httpSwagger.UIConfig(map[string]string{"urls": string(list)}),
+	)(w, r)
+}
+
+// apiDocument godoc
+// @Summary Get the OpenAPI document of a route
+// @Description Get the OpenAPI 3 document of the route's upstream, served through the connector. Its servers point at the connector and its security schemes are removed since the connector injects the credentials.
+// @Tags docs
+// @Produce  json
+// @Param route query string false "Route name, the first route with a document by default"
+// @Success 200 {object} object
+// @Router /_connector/docs/openapi.json [get]
+func (a *App) apiDocument(w http.ResponseWriter, r *http.Request) {
+	st := a.stateOf(r)
+	name := r.URL.Query().Get("route")
+	for _, route := range st.routes {
+		if route.OpenAPI != "" && (name == "" || route.Name == name) {
+			respondWithJSON(w, http.StatusOK, connectorDocument(st.apis[route.OpenAPI], route, r, st.ipFilter.fromTrustedProxy(r)).OpenAPI())
+			return
+		}
+	}
+	respondWithError(w, http.StatusNotFound, "no OpenAPI document for this route")
+}
+
+// connectorDocument returns a copy of api describing the route as served by the connector:
+// its only server is the connector and it has no security since the connector authenticates
+// the requests it forwards
+func connectorDocument(api *parser.Document, route config.Route, r *http.Request, fromTrustedProxy bool) *parser.Document {
+	// the server is relative to the document unless a trusted proxy reports how it was reached,
+	// clients could otherwise point it anywhere
+	server := strings.TrimSuffix(route.Prefix, "/")
+	if fromTrustedProxy {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+		}
+		host := r.Host
+		if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+			host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+		server = scheme + "://" + host + server
+	} else if server == "" {
+		server = "/"
+	}
+
+	doc := *api
+	doc.Servers = []string{server}
+	doc.SecuritySchemes = nil
+	doc.Security = nil
+	doc.Operations = make([]*parser.Operation, len(api.Operations))
+	for i, op := range api.Operations {
+		copied := *op
+		copied.Security = nil
+		doc.Operations[i] = &copied
+	}
+	return &doc
+}
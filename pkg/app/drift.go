@@ -0,0 +1,324 @@
+package app
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kosha/passthrough-connector/pkg/parser"
+)
+
+// maxDriftDepth bounds how deep response bodies and recursive schemas are walked
+const maxDriftDepth = 16
+
+// maxDriftFields bounds the fields tracked per operation and status, fields seen after
+// it is reached are ignored
+const maxDriftFields = 1000
+
+// checkResponse validates a sample of the upstream responses of op against the API
+// description, counting and logging mismatches, and records their fields for the drift
+// report when it is enabled
+func (a *App) checkResponse(st *state, route string, op *parser.Operation, status int, body interface{}) {
+	rate, drift := st.cfg.GetResponseValidation()
+	if rate <= 0 || rate < 100 && rand.Intn(100) >= rate {
+		return
+	}
+	response := op.Response(status)
+	if response == nil {
+		return
+	}
+	media := parser.MediaTypeFor(response.Content, "application/json")
+	if media == nil {
+		return
+	}
+
+	validatedResponses.WithLabelValues(route, op.Name()).Inc()
+	if violations := op.ValidateResponse(status, "application/json", body); len(violations) > 0 {
+		responseMismatches.WithLabelValues(route, op.Name()).Inc()
+		a.Log.Warnf("Response %d of operation %s on route %s does not match the API description: %v", status, op.Name(), route, violations)
+	}
+	if drift {
+		a.drift.record(route, op.Name(), status, media.Schema, body)
+	}
+}
+
+// driftTracker accumulates the fields seen in validated responses, by route, operation and
+// status, so they can be compared with the fields the API description declares
+type driftTracker struct {
+	mu      sync.Mutex
+	entries map[driftKey]*driftEntry
+}
+
+type driftKey struct {
+	route     string
+	operation string
+	status    int
+}
+
+type driftEntry struct {
+	schema *parser.Schema
+	// declared are the fields of schema
+	declared map[string]*declaredField
+	samples  int
+	lastSeen time.Time
+	// seen counts the JSON types of every field by JSON pointer, array items being /*
+	seen map[string]map[string]int
+}
+
+// TypeChange is a field whose type differs from the declared one
+type TypeChange struct {
+	Field    string   `json:"field"`
+	Expected []string `json:"expected"`
+	Got      string   `json:"got"`
+}
+
+// DriftReport lists the differences between the responses of an operation and its
+// description. Fields are JSON pointers, array items being /*.
+type DriftReport struct {
+	Route       string       `json:"route"`
+	Operation   string       `json:"operation"`
+	Status      int          `json:"status"`
+	Samples     int          `json:"samples"`
+	LastSeen    time.Time    `json:"lastSeen"`
+	Appeared    []string     `json:"appeared"`
+	Disappeared []string     `json:"disappeared"`
+	Changed     []TypeChange `json:"changed"`
+}
+
+func (d *driftTracker) record(route, operation string, status int, schema *parser.Schema, body interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := driftKey{route, operation, status}
+	entry, ok := d.entries[key]
+	if !ok {
+		if d.entries == nil {
+			d.entries = make(map[driftKey]*driftEntry)
+		}
+		entry = &driftEntry{seen: make(map[string]map[string]int)}
+		d.entries[key] = entry
+	}
+	// the latest schema is kept so reloaded documents are reported against
+	if entry.schema != schema || entry.declared == nil {
+		entry.schema = schema
+		entry.declared = make(map[string]*declaredField)
+		declare(entry.declared, "", schema, false, 0)
+	}
+	entry.samples++
+	entry.lastSeen = time.Now()
+	observe(entry.seen, entry.declared, "", body, 0)
+}
+
+// report returns the drift of every tracked operation with at least one difference
+func (d *driftTracker) report() []DriftReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	reports := []DriftReport{}
+	for key, entry := range d.entries {
+		declared := entry.declared
+		report := DriftReport{
+			Route:       key.route,
+			Operation:   key.operation,
+			Status:      key.status,
+			Samples:     entry.samples,
+			LastSeen:    entry.lastSeen,
+			Appeared:    []string{},
+			Disappeared: []string{},
+			Changed:     []TypeChange{},
+		}
+		for field, types := range entry.seen {
+			expected, ok := declared[field]
+			if !ok {
+				if parent, ok := declared[parentField(field)]; ok && field != "" && !parent.open {
+					report.Appeared = append(report.Appeared, field)
+				}
+				continue
+			}
+			for t := range types {
+				if !expected.accepts(t) {
+					report.Changed = append(report.Changed, TypeChange{Field: field, Expected: expected.typeList(), Got: t})
+				}
+			}
+		}
+		for field := range declared {
+			if _, ok := entry.seen[field]; ok || field == "" || strings.HasSuffix(field, "/*") {
+				continue
+			}
+			// optional fields and fields of objects that were never seen are not reported
+			if _, ok := entry.seen[parentField(field)]; ok && declared[field].required {
+				report.Disappeared = append(report.Disappeared, field)
+			}
+		}
+		if len(report.Appeared)+len(report.Disappeared)+len(report.Changed) == 0 {
+			continue
+		}
+		sort.Strings(report.Appeared)
+		sort.Strings(report.Disappeared)
+		sort.Slice(report.Changed, func(i, j int) bool {
+			if report.Changed[i].Field != report.Changed[j].Field {
+				return report.Changed[i].Field < report.Changed[j].Field
+			}
+			return report.Changed[i].Got < report.Changed[j].Got
+		})
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		a, b := reports[i], reports[j]
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		if a.Operation != b.Operation {
+			return a.Operation < b.Operation
+		}
+		return a.Status < b.Status
+	})
+	return reports
+}
+
+// observe records the JSON type of value and of the fields within it. Only the fields of
+// declared objects that do not accept other fields are walked, the keys of maps and of
+// undeclared objects would never be reported.
+func observe(seen map[string]map[string]int, declared map[string]*declaredField, field string, value interface{}, depth int) {
+	if depth > maxDriftDepth {
+		return
+	}
+	if seen[field] == nil {
+		if len(seen) >= maxDriftFields {
+			return
+		}
+		seen[field] = make(map[string]int)
+	}
+	seen[field][jsonType(value)]++
+	if f, ok := declared[field]; !ok || f.open {
+		return
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, child := range v {
+			observe(seen, declared, field+"/"+name, child, depth+1)
+		}
+	case []interface{}:
+		for _, item := range v {
+			observe(seen, declared, field+"/*", item, depth+1)
+		}
+	}
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+// declaredField is a field described by the schema, with the types it may have
+type declaredField struct {
+	types map[string]bool
+	// open objects accept fields they do not list
+	open bool
+	// required fields are listed as required by their object
+	required bool
+}
+
+func (f *declaredField) accepts(t string) bool {
+	if len(f.types) == 0 || len(f.types) == 1 && f.types["null"] {
+		// the schema does not restrict the type
+		return true
+	}
+	return f.types[t] || t == "integer" && f.types["number"]
+}
+
+func (f *declaredField) typeList() []string {
+	var types []string
+	for t := range f.types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// declare collects the fields described by s, merging the alternatives of compositions. The
+// properties s requires are required fields unless s is one of several alternatives.
+func declare(declared map[string]*declaredField, field string, s *parser.Schema, alternative bool, depth int) {
+	if s == nil || depth > maxDriftDepth {
+		return
+	}
+	f, ok := declared[field]
+	if !ok {
+		f = &declaredField{types: make(map[string]bool)}
+		declared[field] = f
+	}
+	if s.Type != "" {
+		f.types[s.Type] = true
+	}
+	if s.Nullable {
+		f.types["null"] = true
+	}
+	if s.Type == "object" || len(s.Properties) > 0 {
+		f.open = f.open || len(s.Properties) == 0 || s.AdditionalProperties != nil
+	}
+	for name, property := range s.Properties {
+		declare(declared, field+"/"+name, property, false, depth+1)
+	}
+	if !alternative {
+		// required properties may be described by another schema of a composition
+		for _, name := range s.Required {
+			property, ok := declared[field+"/"+name]
+			if !ok {
+				property = &declaredField{types: make(map[string]bool)}
+				declared[field+"/"+name] = property
+			}
+			property.required = true
+		}
+	}
+	if s.Items != nil {
+		declare(declared, field+"/*", s.Items, false, depth+1)
+	}
+	for _, sub := range s.AllOf {
+		declare(declared, field, sub, alternative, depth+1)
+	}
+	for _, subs := range [][]*parser.Schema{s.OneOf, s.AnyOf} {
+		for _, sub := range subs {
+			declare(declared, field, sub, true, depth+1)
+		}
+	}
+}
+
+func parentField(field string) string {
+	if i := strings.LastIndex(field, "/"); i >= 0 {
+		return field[:i]
+	}
+	return ""
+}
+
+// listDrift godoc
+// @Summary Get the drift of upstream responses from the API description
+// @Description List, per route, operation and status, the response fields that appeared, disappeared or changed type compared to the OpenAPI document
+// @Tags drift
+// @Produce  json
+// @Success 200 {object} object
+// @Router /_connector/drift [get]
+func (a *App) listDrift(w http.ResponseWriter, r *http.Request) {
+	if _, enabled := a.current().cfg.GetResponseValidation(); !enabled {
+		respondWithError(w, http.StatusNotFound, "the drift report is not enabled")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, a.drift.report())
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/kosha/passthrough-connector/pkg/httpclient"
+)
+
+// ipFilter enforces allow and deny lists on the address of the client, which is taken
+// from X-Forwarded-For when the request comes through one of the trusted proxies
+type ipFilter struct {
+	allow   []*net.IPNet
+	deny    []*net.IPNet
+	trusted []*net.IPNet
+}
+
+func newIPFilter(allow, deny, trusted []string) (*ipFilter, error) {
+	f := &ipFilter{}
+	var err error
+	if f.allow, err = httpclient.ParseNetworks(allow); err != nil {
+		return nil, err
+	}
+	if f.deny, err = httpclient.ParseNetworks(deny); err != nil {
+		return nil, err
+	}
+	if f.trusted, err = httpclient.ParseNetworks(trusted); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// fromTrustedProxy returns true if the request was sent by one of the trusted proxies, whose
+// headers can be relied on
+func (f *ipFilter) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return f != nil && ip != nil && contains(f.trusted, ip)
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is walked from right to
+// left, skipping trusted proxies, so a client cannot spoof its address by sending the header.
+func (f *ipFilter) clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if f == nil || ip == nil || !contains(f.trusted, ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !contains(f.trusted, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// check returns an empty string if the client address is allowed, or the rejection reason.
+// Clients without an address, such as those of unix sockets, are only rejected by an allow list.
+func (f *ipFilter) check(ip net.IP) string {
+	if ip == nil {
+		if len(f.allow) > 0 {
+			return "unknown_address"
+		}
+		return ""
+	}
+	if contains(f.deny, ip) {
+		return "denied"
+	}
+	if len(f.allow) > 0 && !contains(f.allow, ip) {
+		return "not_allowed"
+	}
+	return ""
+}
+
+// ipFilterMiddleware rejects clients whose address is denied or not in the allow list
+func (a *App) ipFilterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter := a.stateOf(r).ipFilter
+		if filter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if reason := filter.check(net.ParseIP(getRequestInfo(r).ClientIP)); reason != "" {
+			rejectedClients.WithLabelValues(reason).Inc()
+			a.Log.Warnf("Rejected request from %s: %s", getRequestInfo(r).ClientIP, reason)
+			respondWithError(w, http.StatusForbidden, "client address is not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
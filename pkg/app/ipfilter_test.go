@@ -0,0 +1,102 @@
+package app
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+)
+
+func TestIPFilterClientIP(t *testing.T) {
+	f, err := newIPFilter([]string{"10.1.0.0/16"}, []string{"10.1.2.0/24"}, []string{"192.168.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remote, forwarded, want, reason string
+	}{
+		// untrusted peers cannot spoof their address
+		{"10.9.0.1:1234", "10.1.0.5", "10.9.0.1", "not_allowed"},
+		{"192.168.0.10:1234", "10.1.0.5", "10.1.0.5", ""},
+		{"192.168.0.10:1234", "1.2.3.4, 10.1.0.5, 192.168.0.11", "10.1.0.5", ""},
+		{"192.168.0.10:1234", "10.1.2.3", "10.1.2.3", "denied"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		r.Header.Set("X-Forwarded-For", tt.forwarded)
+
+		ip := f.clientIP(r)
+		if ip.String() != tt.want {
+			t.Errorf("clientIP(%s, %s) = %s, want %s", tt.remote, tt.forwarded, ip, tt.want)
+		}
+		if reason := f.check(ip); reason != tt.reason {
+			t.Errorf("check(%s) = %q, want %q", ip, reason, tt.reason)
+		}
+	}
+}
+
+func TestIPFilterUnknownAddress(t *testing.T) {
+	denied, _ := newIPFilter(nil, []string{"10.0.0.0/8"}, nil)
+	if reason := denied.check(nil); reason != "" {
+		t.Errorf("expected clients without an address to pass a deny list, got %q", reason)
+	}
+	allowed, _ := newIPFilter([]string{"10.0.0.0/8"}, nil, nil)
+	if reason := allowed.check(nil); reason != "unknown_address" {
+		t.Errorf("expected clients without an address to be rejected by an allow list, got %q", reason)
+	}
+}
+
+func TestIPFilterMiddleware(t *testing.T) {
+	t.Setenv("DENIED_CIDRS", "10.0.0.0/8")
+	a := App{Router: r, Log: logging, Cfg: config.Get()}
+	handler := a.requestInfoMiddleware(a.ipFilterMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected denied client to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestIPFilterReservedEndpoints(t *testing.T) {
+	t.Setenv("SERVER_URL", "https://example.com")
+	t.Setenv("ROUTES", "")
+	t.Setenv("DENIED_CIDRS", "10.0.0.0/8")
+	a := App{Router: router(), Log: logging, Cfg: config.Get()}
+	a.InitializeRoutes()
+
+	for _, path := range []string{"/_connector/health", "/_connector/usage", "/_connector/specification", "/_connector/metrics", "/_connector/docs"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected denied client to be rejected from %s, got %d", path, rr.Code)
+		}
+	}
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	v1 := "PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\nGET / HTTP/1.1\r\n"
+	addr, err := readProxyHeader(bufio.NewReader(strings.NewReader(v1)))
+	if err != nil || addr.String() != "203.0.113.7:56324" {
+		t.Errorf("unexpected v1 result %v %v", addr, err)
+	}
+
+	v2 := string(proxyProtocolV2Signature) + "\x21\x11\x00\x0c" + "\xcb\x00\x71\x07" + "\x0a\x00\x00\x01" + "\xdc\x04\x01\xbb"
+	addr, err = readProxyHeader(bufio.NewReader(strings.NewReader(v2)))
+	if err != nil || addr.String() != "203.0.113.7:56324" {
+		t.Errorf("unexpected v2 result %v %v", addr, err)
+	}
+
+	if _, err := readProxyHeader(bufio.NewReader(strings.NewReader("GET / HTTP/1.1\r\n"))); err == nil {
+		t.Errorf("expected an error for a missing header")
+	}
+}
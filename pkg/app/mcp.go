@@ -0,0 +1,383 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+	"github.com/kosha/passthrough-connector/pkg/mcp"
+	"github.com/kosha/passthrough-connector/pkg/parser"
+)
+
+const (
+	mcpServerName    = "passthrough-connector"
+	mcpServerVersion = "2.0"
+	// bodyArgument is the argument holding the request body of tools
+	bodyArgument = "body"
+	// stdioClient is the client address of tool calls read from stdio, a local process
+	stdioClient = "127.0.0.1:0"
+)
+
+// toolInstructions tell models how the tools map to the upstream APIs
+const toolInstructions = "Each tool calls an operation of an upstream API through the connector, which authenticates the calls. " +
+	"Path, query, header and cookie parameters are arguments named after them, the request body is the body argument. " +
+	"Tools return the upstream response body, failed calls report the HTTP status."
+
+// mcpTool is an operation of a route's OpenAPI document served as a tool
+type mcpTool struct {
+	mcp.Tool
+	route config.Route
+	op    *parser.Operation
+	// params are the parameters of the operation by argument name
+	params map[string]*parser.Parameter
+}
+
+var unsafeToolName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// maxToolName is the longest tool name clients accept
+const maxToolName = 64
+
+// mcpTools returns a tool for every operation of the routes' OpenAPI documents that the proxy
+// serves. Tools are named after their operation, prefixed by the route name when several
+// routes have a document.
+func mcpTools(st *state) []*mcpTool {
+	var routes []config.Route
+	for _, route := range st.routes {
+		if st.apis[route.OpenAPI] != nil {
+			routes = append(routes, route)
+		}
+	}
+
+	var tools []*mcpTool
+	taken := make(map[string]bool)
+	for _, route := range routes {
+		for _, op := range st.apis[route.OpenAPI].Operations {
+			if !isProxiedMethod(op.Method) {
+				continue
+			}
+			base := op.ID
+			if base == "" {
+				base = strings.ToLower(op.Method) + "_" + op.Path
+			}
+			if len(routes) > 1 {
+				base = route.Name + "_" + base
+			}
+			base = strings.Trim(unsafeToolName.ReplaceAllString(base, "_"), "_")
+			if len(base) > maxToolName-4 {
+				base = base[:maxToolName-4]
+			}
+			name := base
+			for i := 2; taken[name]; i++ {
+				name = base + "_" + strconv.Itoa(i)
+			}
+			taken[name] = true
+			tools = append(tools, newMCPTool(name, route, op))
+		}
+	}
+	return tools
+}
+
+func newMCPTool(name string, route config.Route, op *parser.Operation) *mcpTool {
+	tool := &mcpTool{route: route, op: op, params: make(map[string]*parser.Parameter)}
+	renderer := parser.NewJSONSchemaRenderer()
+	properties := make(map[string]interface{})
+	var required []string
+
+	for _, param := range op.Parameters {
+		argument := param.Name
+		if _, taken := properties[argument]; taken || argument == bodyArgument {
+			argument = param.In + "_" + param.Name
+		}
+		schema := renderer.Render(param.Schema)
+		description := param.Description
+		if description == "" {
+			description = param.In + " parameter " + param.Name
+		}
+		if _, ok := schema["$ref"]; !ok {
+			schema["description"] = description
+		}
+		properties[argument] = schema
+		tool.params[argument] = param
+		if param.Required || param.In == parser.InPath {
+			required = append(required, argument)
+		}
+	}
+	if op.RequestBody != nil {
+		var schema map[string]interface{}
+		if media := parser.MediaTypeFor(op.RequestBody.Content, "application/json"); media != nil {
+			schema = renderer.Render(media.Schema)
+		} else {
+			schema = map[string]interface{}{}
+		}
+		if _, ok := schema["$ref"]; ok {
+			// keywords next to a reference are ignored by older JSON Schema drafts
+			schema = map[string]interface{}{"allOf": []interface{}{schema}}
+		}
+		schema["description"] = "request body"
+		if op.RequestBody.Description != "" {
+			schema["description"] = op.RequestBody.Description
+		}
+		properties[bodyArgument] = schema
+		if op.RequestBody.Required {
+			required = append(required, bodyArgument)
+		}
+	}
+
+	input := map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+	if len(required) > 0 {
+		sort.Strings(required)
+		input["required"] = required
+	}
+	if defs := renderer.Definitions(); len(defs) > 0 {
+		input["$defs"] = defs
+	}
+
+	description := strings.TrimSpace(op.Summary + "\n\n" + op.Description)
+	if description == "" {
+		description = op.Method + " " + op.Path
+	}
+	if op.Deprecated {
+		description = "Deprecated. " + description
+	}
+	tool.Tool = mcp.Tool{
+		Name:        name,
+		Title:       op.Summary,
+		Description: description,
+		InputSchema: input,
+	}
+	switch op.Method {
+	case http.MethodGet:
+		tool.Annotations = &mcp.Annotations{ReadOnlyHint: true, IdempotentHint: true}
+	case http.MethodPut, http.MethodDelete:
+		tool.Annotations = &mcp.Annotations{IdempotentHint: true}
+	}
+	return tool
+}
+
+// request builds the proxied request calling the tool's operation with the arguments
+func (t *mcpTool) request(ctx context.Context, arguments map[string]interface{}) (*http.Request, error) {
+	var unknown []string
+	for name := range arguments {
+		if _, ok := t.params[name]; !ok && !(name == bodyArgument && t.op.RequestBody != nil) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%w: unknown arguments %s", mcp.ErrInvalidArguments, strings.Join(unknown, ", "))
+	}
+
+	path := t.op.Path
+	query := url.Values{}
+	header := make(http.Header)
+	var cookies []string
+	for argument, param := range t.params {
+		value, ok := arguments[argument]
+		if !ok || value == nil {
+			if param.In == parser.InPath || param.Required {
+				return nil, fmt.Errorf("%w: %s is required", mcp.ErrInvalidArguments, argument)
+			}
+			continue
+		}
+		values := argumentValues(value)
+		switch param.In {
+		case parser.InPath:
+			path = strings.ReplaceAll(path, "{"+param.Name+"}", url.PathEscape(strings.Join(values, ",")))
+		case parser.InQuery:
+			query[param.Name] = values
+		case parser.InHeader:
+			header.Set(param.Name, strings.Join(values, ","))
+		case parser.InCookie:
+			cookies = append(cookies, param.Name+"="+url.QueryEscape(strings.Join(values, ",")))
+		}
+	}
+	if len(cookies) > 0 {
+		sort.Strings(cookies)
+		header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+
+	var body io.Reader
+	if value, ok := arguments[bodyArgument]; ok && t.op.RequestBody != nil {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", mcp.ErrInvalidArguments, err)
+		}
+		body = bytes.NewReader(encoded)
+		header.Set("Content-Type", "application/json")
+	} else if t.op.RequestBody != nil && t.op.RequestBody.Required {
+		return nil, fmt.Errorf("%w: %s is required", mcp.ErrInvalidArguments, bodyArgument)
+	}
+
+	requestUri := strings.TrimSuffix(t.route.Prefix, "/") + path
+	if len(query) > 0 {
+		requestUri += "?" + query.Encode()
+	}
+	// the operation's path is already the upstream path
+	r, err := http.NewRequestWithContext(withUpstreamPath(ctx), t.op.Method, requestUri, body)
+	if err != nil {
+		return nil, err
+	}
+	r.RequestURI = requestUri
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	return r, nil
+}
+
+// argumentValues formats an argument as parameter values, arrays giving one value per item
+// and objects their JSON encoding
+func argumentValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, argumentValues(item)...)
+		}
+		return values
+	}
+	encoded, _ := json.Marshal(value)
+	return []string{string(encoded)}
+}
+
+// connectorTools serves the operations of the routes as MCP tools, called through the proxy
+// with its auth and policies. Calls made over HTTP carry the address and headers of the MCP
+// request, so callers and tenants apply as to proxied requests, and are audited, filtered and
+// counted once as the MCP request. Calls read from stdio go through the whole proxy.
+type connectorTools struct {
+	a *App
+	// client is the MCP request over HTTP, nil over stdio
+	client *http.Request
+}
+
+// state returns the state of the MCP request, the active one over stdio
+func (t connectorTools) state() *state {
+	if t.client != nil {
+		return t.a.stateOf(t.client)
+	}
+	return t.a.current()
+}
+
+func (t connectorTools) Tools(ctx context.Context) []mcp.Tool {
+	var tools []mcp.Tool
+	for _, tool := range t.state().tools {
+		tools = append(tools, tool.Tool)
+	}
+	return tools
+}
+
+func (t connectorTools) Call(ctx context.Context, name string, arguments map[string]interface{}) (*mcp.Result, error) {
+	var tool *mcpTool
+	for _, candidate := range t.state().tools {
+		if candidate.Name == name {
+			tool = candidate
+			break
+		}
+	}
+	if tool == nil {
+		return nil, fmt.Errorf("%w %q", mcp.ErrUnknownTool, name)
+	}
+	r, err := tool.request(ctx, arguments)
+	if err != nil {
+		return nil, err
+	}
+	r.RemoteAddr = stdioClient
+	if t.client != nil {
+		r.RemoteAddr = t.client.RemoteAddr
+		for name, values := range t.client.Header {
+			switch http.CanonicalHeaderKey(name) {
+			case "Accept", "Accept-Encoding", "Content-Type", "Content-Length", "Origin", "Mcp-Session-Id", "Mcp-Protocol-Version":
+				continue
+			}
+			if _, ok := r.Header[name]; !ok {
+				r.Header[name] = values
+			}
+		}
+	}
+
+	w := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+	if t.client != nil {
+		info := *getRequestInfo(t.client)
+		t.a.commonMiddleware().ServeHTTP(w, withRequestInfo(r, &info))
+	} else {
+		t.a.Router.ServeHTTP(w, r)
+	}
+	if w.status >= http.StatusBadRequest {
+		return mcp.TextResult(fmt.Sprintf("%s %s failed with status %d: %s", tool.op.Method, r.URL.Path, w.status, strings.TrimSpace(w.body.String())), true), nil
+	}
+	if w.body.Len() == 0 {
+		return mcp.TextResult(fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)), false), nil
+	}
+	return mcp.TextResult(w.body.String(), false), nil
+}
+
+// bufferedResponse keeps the response of a proxied tool call in memory
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponse) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bufferedResponse) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// mcpServer returns the MCP server of the connector's tools, client is the MCP request over HTTP
+func (a *App) mcpServer(client *http.Request) *mcp.Server {
+	return &mcp.Server{
+		Name:         mcpServerName,
+		Version:      mcpServerVersion,
+		Instructions: toolInstructions,
+		Tools:        connectorTools{a, client},
+	}
+}
+
+// ServeMCP serves the tools over stdio, reading requests from in and answering on out until
+// in is closed. The routes must be initialized.
+func (a *App) ServeMCP(in io.Reader, out io.Writer) error {
+	a.closeOnSignal()
+	err := a.mcpServer(nil).ServeStdio(context.Background(), in, out)
+	if closeErr := a.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// serveMCP godoc
+// @Summary Call the upstream operations as Model Context Protocol tools
+// @Description Streamable HTTP endpoint of the MCP server exposing every operation of the routes' OpenAPI documents as a tool. Tool calls go through the proxy, with its auth and policies.
+// @Tags mcp
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} object
+// @Success 202
+// @Router /_connector/mcp [post]
+func (a *App) serveMCP(w http.ResponseWriter, r *http.Request) {
+	if !a.stateOf(r).cfg.GetMCP() {
+		respondWithError(w, http.StatusNotFound, "the MCP server is not enabled")
+		return
+	}
+	a.mcpServer(r).ServeHTTP(w, r)
+}
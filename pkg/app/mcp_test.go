@@ -0,0 +1,213 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+)
+
+func TestMCP(t *testing.T) {
+	var received []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, fmt.Sprintf("%s %s %s %s", r.Method, r.URL.RequestURI(), r.Header.Get("X-Api-Key"), body))
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/404") {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": "no such ticket"}`)
+			return
+		}
+		fmt.Fprint(w, `{"id": 7}`)
+	}))
+	defer upstream.Close()
+
+	api := filepath.Join(t.TempDir(), "tickets.yaml")
+	err := os.WriteFile(api, []byte(`openapi: 3.0.3
+info: {title: Tickets, version: "2"}
+servers: [{url: "`+upstream.URL+`"}]
+components:
+  securitySchemes:
+    key: {type: apiKey, in: header, name: X-Api-Key}
+  schemas:
+    NewTicket:
+      type: object
+      required: [subject]
+      properties:
+        subject: {type: string}
+security: [{key: []}]
+paths:
+  /tickets:
+    post:
+      operationId: createTicket
+      summary: Create a ticket
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/NewTicket"}
+      responses: {"201": {description: created}}
+  /tickets/{id}:
+    get:
+      operationId: getTicket
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: integer}}
+        - {name: include, in: query, schema: {type: array, items: {type: string}}}
+      responses: {"200": {description: ticket}}
+    patch:
+      operationId: updateTicket
+      responses: {"200": {description: updated}}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("MCP", "true")
+	t.Setenv("ROUTES", `[{"prefix": "/helpdesk", "openapi": "`+api+`", "auth": {"apiKey": "key"}}]`)
+	cfg := config.Get()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a := App{Router: router(), Log: logging, Cfg: cfg}
+	a.InitializeRoutes()
+
+	rpc := func(method, params string) map[string]interface{} {
+		t.Helper()
+		body := `{"jsonrpc": "2.0", "id": 1, "method": "` + method + `", "params": ` + params + `}`
+		req := httptest.NewRequest("POST", "/_connector/mcp", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+		}
+		var res map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatalf("invalid response %s", rr.Body.String())
+		}
+		return res
+	}
+
+	tools := rpc("tools/list", "{}")["result"].(map[string]interface{})["tools"].([]interface{})
+	byName := make(map[string]map[string]interface{})
+	for _, tool := range tools {
+		byName[tool.(map[string]interface{})["name"].(string)] = tool.(map[string]interface{})
+	}
+	if len(byName) != 2 || byName["getTicket"] == nil || byName["createTicket"] == nil {
+		t.Fatalf("expected a tool per proxied operation, got %v", tools)
+	}
+	input := byName["createTicket"]["inputSchema"].(map[string]interface{})
+	if input["$defs"].(map[string]interface{})["NewTicket"] == nil || input["required"].([]interface{})[0] != "body" {
+		t.Errorf("unexpected input schema %v", input)
+	}
+	if hints := byName["getTicket"]["annotations"].(map[string]interface{}); hints["readOnlyHint"] != true {
+		t.Errorf("expected GET operations to be read only, got %v", hints)
+	}
+
+	text := func(res map[string]interface{}) (string, bool) {
+		result := res["result"].(map[string]interface{})
+		isError, _ := result["isError"].(bool)
+		return result["content"].([]interface{})[0].(map[string]interface{})["text"].(string), isError
+	}
+	if out, isError := text(rpc("tools/call", `{"name": "getTicket", "arguments": {"id": 7, "include": ["comments", "tags"]}}`)); isError || out != `{"id":7}` {
+		t.Errorf("unexpected result %q", out)
+	}
+	if out, isError := text(rpc("tools/call", `{"name": "createTicket", "arguments": {"body": {"subject": "printer"}}}`)); isError || out != `{"id":7}` {
+		t.Errorf("unexpected result %q", out)
+	}
+	expected := []string{
+		"GET /tickets/7?include=comments&include=tags key ",
+		`POST /tickets key {"subject":"printer"}`,
+	}
+	if strings.Join(received, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected the calls to be proxied with the route auth, got %q", received)
+	}
+
+	if out, isError := text(rpc("tools/call", `{"name": "getTicket", "arguments": {"id": 404}}`)); !isError || !strings.Contains(out, "404") || !strings.Contains(out, "no such ticket") {
+		t.Errorf("expected a failed call, got %q", out)
+	}
+	if res := rpc("tools/call", `{"name": "getTicket", "arguments": {"include": ["tags"]}}`); res["error"] == nil {
+		t.Errorf("expected missing path parameters to be rejected, got %v", res)
+	}
+	if res := rpc("tools/call", `{"name": "getTicket", "arguments": {"id": 1, "verbose": true}}`); res["error"] == nil {
+		t.Errorf("expected unknown arguments to be rejected, got %v", res)
+	}
+	if len(received) != 3 {
+		t.Errorf("expected rejected calls not to reach the upstream, got %q", received)
+	}
+
+	// MCP requests go through the client filter of proxied requests
+	t.Setenv("DENIED_CIDRS", "192.0.2.0/24")
+	a = App{Router: router(), Log: logging, Cfg: config.Get()}
+	a.InitializeRoutes()
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, httptest.NewRequest("POST", "/_connector/mcp", strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`)))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected denied clients to be rejected, got %d", rr.Code)
+	}
+
+	t.Setenv("DENIED_CIDRS", "")
+	t.Setenv("MCP", "false")
+	a = App{Router: router(), Log: logging, Cfg: config.Get()}
+	a.InitializeRoutes()
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, httptest.NewRequest("POST", "/_connector/mcp", strings.NewReader(`{}`)))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected the MCP server to be disabled, got %d", rr.Code)
+	}
+}
+
+func TestMCPToolCallsProxiedOnce(t *testing.T) {
+	upstream := echoServer()
+	defer upstream.Close()
+
+	api := filepath.Join(t.TempDir(), "tickets.yaml")
+	err := os.WriteFile(api, []byte(`openapi: 3.0.3
+info: {title: Tickets, version: "2"}
+paths:
+  /api/tickets/{id}:
+    get:
+      operationId: getTicket
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: integer}}
+      responses: {"200": {description: ticket}}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("MCP", "true")
+	t.Setenv("QUOTA_LIMIT", "2")
+	t.Setenv("ROUTES", `[{"prefix": "/helpdesk", "serverUrl": "`+upstream.URL+`", "openapi": "`+api+`", "rewrite": [{"addPrefix": "/api"}]}]`)
+	a := App{Router: router(), Log: logging, Cfg: config.Get()}
+	a.InitializeRoutes()
+
+	rpc := func(method, params string) *httptest.ResponseRecorder {
+		body := `{"jsonrpc": "2.0", "id": 1, "method": "` + method + `", "params": ` + params + `}`
+		req := httptest.NewRequest("POST", "/_connector/mcp", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// the operation's path is the upstream path, the route's rewrite rules are not applied again
+	rr := rpc("tools/call", `{"name": "getTicket", "arguments": {"id": 7}}`)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `\"path\":\"/api/tickets/7\"`) {
+		t.Errorf("expected the operation to be called, got %d %s", rr.Code, rr.Body.String())
+	}
+	// a tool call counts once against the quota
+	if rr := rpc("tools/list", "{}"); rr.Code != http.StatusOK {
+		t.Errorf("expected the second MCP request to be within quota, got %d", rr.Code)
+	}
+	if rr := rpc("tools/list", "{}"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the third MCP request to be over quota, got %d", rr.Code)
+	}
+}
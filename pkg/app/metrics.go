@@ -0,0 +1,31 @@
+package app
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var rejectedClients = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rejected_clients_total",
+	Help: "Number of requests and connections rejected because of the client address.",
+}, []string{"reason"})
+
+var validatedResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "upstream_responses_validated_total",
+	Help: "Number of upstream responses validated against the OpenAPI document.",
+}, []string{"route", "operation"})
+
+var responseMismatches = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "upstream_response_schema_mismatches_total",
+	Help: "Number of validated upstream responses that do not match the OpenAPI document.",
+}, []string{"route", "operation"})
+
+var configReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "config_reloads_total",
+	Help: "Number of configuration reloads.",
+}, []string{"result"})
+
+var configLastReload = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "config_last_reload_success_timestamp_seconds",
+	Help: "Timestamp of the last successful configuration reload.",
+})
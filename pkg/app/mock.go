@@ -0,0 +1,102 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+	"github.com/kosha/passthrough-connector/pkg/parser"
+)
+
+// serveMock answers the request from the examples of the route's OpenAPI document without
+// calling the upstream. The request is validated first, whatever the validation mode short
+// of off, since there is no upstream to reject it.
+func (a *App) serveMock(w http.ResponseWriter, r *http.Request, st *state, route *config.Route, api *parser.Document, op *parser.Operation, pathParams map[string]string, path string) {
+	if api == nil {
+		respondWithError(w, http.StatusNotFound, "route "+route.Name+" has no OpenAPI document to mock")
+		return
+	}
+	if op == nil {
+		upstreamPath, _, _ := strings.Cut(path, "?")
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("no operation of the API description matches %s %s", r.Method, upstreamPath))
+		return
+	}
+
+	body, hasBody, bodyErr := decodeBody(r)
+	if route.GetRequestValidation(st.cfg.GetRequestValidation()) != config.ValidationOff {
+		headers := make(map[string]string, len(r.Header))
+		for name := range r.Header {
+			headers[name] = r.Header.Get(name)
+		}
+		if violations := validateRequest(op, pathParams, path, headers, body, hasBody, bodyErr); len(violations) > 0 {
+			respondWithJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error":      "the request does not match operation " + op.Name(),
+				"violations": violations,
+			})
+			return
+		}
+	}
+
+	status, example, err := preferences(r.Header.Values("Prefer"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	mock, err := op.Mock(status, example)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for name, value := range mock.Headers {
+		w.Header().Set(name, value)
+	}
+	if mock.Body == nil {
+		w.WriteHeader(mock.Status)
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(mock.ContentType)
+	text, isText := mock.Body.(string)
+	if isText && mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		w.Header().Set("Content-Type", mock.ContentType)
+		w.WriteHeader(mock.Status)
+		w.Write([]byte(text))
+		return
+	}
+	response, err := json.Marshal(mock.Body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", mock.ContentType)
+	w.WriteHeader(mock.Status)
+	w.Write(response)
+}
+
+// preferences reads the status code and example name requested with Prefer headers, such
+// as Prefer: code=404 or Prefer: example=closed
+func preferences(values []string) (int, string, error) {
+	var status int
+	var example string
+	for _, value := range values {
+		for _, preference := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+			name, value, _ := strings.Cut(strings.TrimSpace(preference), "=")
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "code":
+				code, err := strconv.Atoi(value)
+				if err != nil || code < 100 || code > 599 {
+					return 0, "", fmt.Errorf("invalid Prefer code %q", value)
+				}
+				status = code
+			case "example":
+				example = value
+			}
+		}
+	}
+	return status, example, nil
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+	"github.com/kosha/passthrough-connector/pkg/httpclient"
+)
+
+// expiryMargin refreshes access tokens slightly before they expire
+const expiryMargin = 30 * time.Second
+
+// tokenCache holds the access tokens refreshed from OAuth2 token endpoints, by endpoint and
+// configured refresh token, so every request does not refresh the token again
+type tokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]httpclient.OAuth2Token
+	// refreshes are the refreshes in flight by key, requests needing the same token wait
+	// for the one in flight rather than refreshing it again
+	refreshes map[string]*tokenRefresh
+}
+
+type tokenRefresh struct {
+	done  chan struct{}
+	token httpclient.OAuth2Token
+	err   error
+}
+
+// get returns a valid access token for auth: the configured one until it expires, then one
+// refreshed with the refresh token, or else requested with the client credentials. The lock
+// is not held during the refresh, so tokens of other endpoints stay available meanwhile.
+func (c *tokenCache) get(ctx context.Context, auth config.Auth) (string, error) {
+	key := strings.Join([]string{auth.TokenURL, auth.RefreshURL, auth.ClientID, auth.RefreshToken}, " ")
+	c.mu.Lock()
+	token, ok := c.tokens[key]
+	if !ok {
+		token = httpclient.OAuth2Token{AccessToken: auth.AccessToken, RefreshToken: auth.RefreshToken, Expiry: parseExpiry(auth.ExpiresAt)}
+	}
+	if token.AccessToken != "" && (token.Expiry.IsZero() || time.Now().Add(expiryMargin).Before(token.Expiry)) {
+		c.mu.Unlock()
+		return token.AccessToken, nil
+	}
+	refresh, inFlight := c.refreshes[key]
+	if !inFlight {
+		refresh = &tokenRefresh{done: make(chan struct{})}
+		if c.refreshes == nil {
+			c.refreshes = make(map[string]*tokenRefresh)
+		}
+		c.refreshes[key] = refresh
+	}
+	c.mu.Unlock()
+
+	if inFlight {
+		select {
+		case <-refresh.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	} else {
+		refresh.token, refresh.err = requestToken(ctx, auth, token.RefreshToken)
+		c.mu.Lock()
+		if refresh.err == nil {
+			if c.tokens == nil {
+				c.tokens = make(map[string]httpclient.OAuth2Token)
+			}
+			c.tokens[key] = refresh.token
+		}
+		delete(c.refreshes, key)
+		c.mu.Unlock()
+		close(refresh.done)
+	}
+	if refresh.err != nil {
+		return "", refresh.err
+	}
+	return refresh.token.AccessToken, nil
+}
+
+// requestToken requests an access token for auth with the refresh token when there is one,
+// falling back to the client credentials grant
+func requestToken(ctx context.Context, auth config.Auth, refreshToken string) (httpclient.OAuth2Token, error) {
+	client := httpclient.OAuth2Client{ID: auth.ClientID, Secret: auth.ClientSecret}
+	err := errors.New("no refresh token or client credentials to request an access token with")
+	if refreshToken != "" && auth.GetRefreshURL() != "" {
+		var token httpclient.OAuth2Token
+		if token, err = httpclient.RefreshOAuth2Token(ctx, auth.GetRefreshURL(), client, refreshToken); err == nil {
+			// endpoints that do not rotate refresh tokens keep accepting the previous one
+			if token.RefreshToken == "" {
+				token.RefreshToken = refreshToken
+			}
+			return token, nil
+		}
+	}
+	if auth.ClientID != "" && auth.TokenURL != "" {
+		return httpclient.ClientCredentialsToken(ctx, auth.TokenURL, client)
+	}
+	return httpclient.OAuth2Token{}, err
+}
+
+// parseExpiry reads EXPIRES_AT, an RFC 3339 time or a number of seconds since the epoch
+func parseExpiry(expiresAt string) time.Time {
+	if t, err := time.Parse(time.RFC3339, expiresAt); err == nil {
+		return t
+	}
+	if seconds, err := strconv.ParseInt(expiresAt, 10, 64); err == nil {
+		return time.Unix(seconds, 0)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,596 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+	"github.com/kosha/passthrough-connector/pkg/parser"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestOpenAPIAuth(t *testing.T) {
+	var refreshes int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/token" {
+			refreshes++
+			r.ParseForm()
+			if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("refresh_token") != "refresh" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"access_token": "fresh", "expires_in": 3600}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"path": r.URL.RequestURI(), "headers": r.Header})
+	}))
+	defer upstream.Close()
+
+	api := filepath.Join(t.TempDir(), "tickets.yaml")
+	err := os.WriteFile(api, []byte(`openapi: 3.0.3
+info: {title: Tickets, version: "2"}
+servers: [{url: "`+upstream.URL+`/api/v2"}]
+components:
+  securitySchemes:
+    key: {type: apiKey, in: query, name: api_key}
+    session: {type: apiKey, in: cookie, name: session}
+    oauth:
+      type: oauth2
+      flows:
+        clientCredentials: {tokenUrl: "`+upstream.URL+`/token", scopes: {}}
+security: [{key: []}]
+paths:
+  /tickets:
+    post:
+      security: [{oauth: []}]
+      responses: {"201": {description: created}}
+  /tickets/{id}/notes:
+    get:
+      security: [{session: []}]
+      responses: {"200": {description: notes}}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("ROUTES", `[{"prefix": "/tickets", "openapi": "`+api+`", "auth": {"apiKey": "key"},
+		"credentials": {
+			"session": {"apiKey": "cookie-key"},
+			"oauth": {"accessToken": "stale", "refreshToken": "refresh", "expiresAt": "`+time.Now().Add(-time.Hour).Format(time.RFC3339)+`"}
+		}}]`)
+	cfg := config.Get()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a := App{Router: r, Log: logging, Cfg: cfg}
+
+	call := func(method, uri string) map[string]interface{} {
+		req := httptest.NewRequest(method, uri, nil)
+		req.Header.Set("Cookie", "theme=dark")
+		rr := httptest.NewRecorder()
+		a.commonMiddleware().ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+		}
+		var body map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		return body
+	}
+	header := func(body map[string]interface{}, name string) interface{} {
+		if values, ok := body["headers"].(map[string]interface{})[name].([]interface{}); ok {
+			return values[0]
+		}
+		return nil
+	}
+
+	// the document security puts the api key in the query
+	if body := call("GET", "/tickets/tickets?page=2"); body["path"] != "/api/v2/tickets?api_key=key&page=2" {
+		t.Errorf("expected the api key in the query, got %v", body["path"])
+	}
+	// operations override the security of the document
+	body := call("GET", "/tickets/tickets/7/notes")
+	if body["path"] != "/api/v2/tickets/7/notes" || header(body, "Cookie") != "theme=dark; session=cookie-key" {
+		t.Errorf("expected the api key in a cookie, got %v %v", body["path"], body["headers"])
+	}
+	for i := 0; i < 2; i++ {
+		if body := call("POST", "/tickets/tickets"); header(body, "Authorization") != "Bearer fresh" {
+			t.Errorf("expected the refreshed access token, got %v", body["headers"])
+		}
+	}
+	if refreshes != 1 {
+		t.Errorf("expected the refreshed token to be cached, got %d refreshes", refreshes)
+	}
+}
+
+func TestTokenRefreshDeduplicated(t *testing.T) {
+	var refreshes int32
+	release := make(chan struct{})
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&refreshes, 1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "fresh", "expires_in": 3600}`)
+	}))
+	defer endpoint.Close()
+
+	var cache tokenCache
+	expired := config.Auth{TokenURL: endpoint.URL, RefreshToken: "refresh", AccessToken: "stale", ExpiresAt: "1"}
+	other := config.Auth{AccessToken: "valid"}
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 5)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], _ = cache.get(context.Background(), expired)
+		}(i)
+	}
+	// tokens of other endpoints are served while the refresh is in flight
+	for atomic.LoadInt32(&refreshes) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if token, err := cache.get(context.Background(), other); err != nil || token != "valid" {
+		t.Errorf("unexpected token %q %v", token, err)
+	}
+	close(release)
+	wg.Wait()
+	if refreshes != 1 {
+		t.Errorf("expected a single refresh, got %d", refreshes)
+	}
+	for _, token := range tokens {
+		if token != "fresh" {
+			t.Errorf("expected the refreshed token, got %v", tokens)
+		}
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	var calls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ok": true}`)
+	}))
+	defer upstream.Close()
+
+	api := filepath.Join(t.TempDir(), "tickets.yaml")
+	err := os.WriteFile(api, []byte(`openapi: 3.0.3
+info: {title: Tickets, version: "2"}
+paths:
+  /tickets:
+    post:
+      parameters:
+        - {name: api-version, in: query, required: true, schema: {type: string}}
+        - {name: notify, in: query, schema: {type: boolean}}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [subject]
+              properties:
+                subject: {type: string}
+                priority: {type: integer, minimum: 1, maximum: 4}
+      responses: {"201": {description: created}}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("REQUEST_VALIDATION", "enforce")
+	t.Setenv("ROUTES", `[
+		{"prefix": "/enforce", "serverUrl": "`+upstream.URL+`", "openapi": "`+api+`", "auth": {"type": "NONE"},
+		 "query": {"default": {"api-version": "2"}}},
+		{"prefix": "/warn", "serverUrl": "`+upstream.URL+`", "openapi": "`+api+`", "auth": {"type": "NONE"},
+		 "requestValidation": "warn"}
+	]`)
+	a := App{Router: r, Log: logging, Cfg: config.Get()}
+
+	call := func(uri, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("POST", uri, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		a.commonMiddleware().ServeHTTP(rr, req)
+		var response map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr.Code, response
+	}
+
+	status, response := call("/enforce/tickets?notify=maybe", `{"priority": 9}`)
+	if status != http.StatusBadRequest || calls != 0 {
+		t.Fatalf("expected the request to be rejected before reaching the upstream, got %d %v", status, response)
+	}
+	var got []string
+	for _, v := range response["violations"].([]interface{}) {
+		violation := v.(map[string]interface{})
+		got = append(got, fmt.Sprint(violation["in"], " ", violation["name"], " ", violation["message"]))
+	}
+	expected := []string{
+		`query notify must be a boolean, got "maybe"`,
+		"body /subject is required",
+		"body /priority must be at most 4",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected violations %q, got %q", expected, got)
+	}
+
+	// injected query parameters satisfy required ones
+	if status, _ := call("/enforce/tickets", `{"subject": "Printer on fire"}`); status != http.StatusOK || calls != 1 {
+		t.Errorf("expected a valid request to be forwarded, got %d", status)
+	}
+	if status, response := call("/enforce/tickets", `{"subject": `); status != http.StatusBadRequest || !strings.Contains(fmt.Sprint(response["violations"]), "must be valid JSON") {
+		t.Errorf("expected invalid JSON to be rejected, got %d %v", status, response)
+	}
+	if status, _ := call("/warn/tickets", `{}`); status != http.StatusOK || calls != 2 {
+		t.Errorf("expected invalid requests to be forwarded in warn mode, got %d", status)
+	}
+}
+
+func TestResponseDrift(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": "7", "subject": "Printer on fire", "tags": [{"name": "hw", "color": "red"}], "assignee": null}`)
+	}))
+	defer upstream.Close()
+
+	api := filepath.Join(t.TempDir(), "tickets.yaml")
+	err := os.WriteFile(api, []byte(`openapi: 3.0.3
+info: {title: Tickets, version: "2"}
+paths:
+  /tickets/{id}:
+    get:
+      operationId: getTicket
+      responses:
+        "200":
+          description: a ticket
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [id, status]
+                properties:
+                  id: {type: integer}
+                  subject: {type: string}
+                  status: {type: string}
+                  priority: {type: integer}
+                  assignee: {type: string, nullable: true}
+                  tags:
+                    type: array
+                    items:
+                      type: object
+                      properties:
+                        name: {type: string}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("ROUTES", `[{"name": "tickets", "prefix": "/tickets", "serverUrl": "`+upstream.URL+`", "openapi": "`+api+`", "auth": {"type": "NONE"}}]`)
+	t.Setenv("RESPONSE_VALIDATION_RATE", "100")
+	t.Setenv("DRIFT_REPORT", "true")
+	a := App{Router: router(), Log: logging, Cfg: config.Get()}
+	a.InitializeRoutes()
+
+	mismatches := testutil.ToFloat64(responseMismatches.WithLabelValues("tickets", "getTicket"))
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, httptest.NewRequest("GET", "/tickets/tickets/7", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+	if got := testutil.ToFloat64(responseMismatches.WithLabelValues("tickets", "getTicket")) - mismatches; got != 2 {
+		t.Errorf("expected 2 mismatches, got %v", got)
+	}
+
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, httptest.NewRequest("GET", "/_connector/drift", nil))
+	var reports []DriftReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &reports); err != nil || len(reports) != 1 {
+		t.Fatalf("expected a drift report, got %s", rr.Body.String())
+	}
+	report := reports[0]
+	if report.Operation != "getTicket" || report.Status != 200 || report.Samples != 2 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if fmt.Sprint(report.Appeared) != "[/tags/*/color]" || fmt.Sprint(report.Disappeared) != "[/status]" {
+		t.Errorf("unexpected appeared %v or disappeared %v fields", report.Appeared, report.Disappeared)
+	}
+	if len(report.Changed) != 1 || report.Changed[0].Field != "/id" || report.Changed[0].Got != "string" {
+		t.Errorf("unexpected type changes %+v", report.Changed)
+	}
+}
+
+func TestAPIDocs(t *testing.T) {
+	api := filepath.Join(t.TempDir(), "tickets.yaml")
+	err := os.WriteFile(api, []byte(`openapi: 3.0.3
+info: {title: Tickets, version: "2"}
+servers: [{url: "https://tickets.example.com/api/v2"}]
+components:
+  securitySchemes:
+    key: {type: apiKey, in: header, name: X-Api-Key}
+security: [{key: []}]
+paths:
+  /tickets:
+    get:
+      operationId: listTickets
+      security: [{key: []}]
+      responses: {"200": {description: tickets}}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("ROUTES", `[{"prefix": "/tickets", "openapi": "`+api+`", "auth": {"apiKey": "key"}}]`)
+	t.Setenv("TRUSTED_PROXIES", "192.0.2.1")
+	cfg := config.Get()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a := App{Router: router(), Log: logging, Cfg: cfg}
+	a.InitializeRoutes()
+
+	// requests come through a trusted proxy
+	get := func(uri string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", uri, nil)
+		req.Host = "connector.example.com"
+		req.Header.Set("X-Forwarded-Proto", "https")
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/_connector/docs/openapi.json?route=tickets")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+	}
+	var doc map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &doc)
+	if servers := doc["servers"].([]interface{}); len(servers) != 1 || servers[0].(map[string]interface{})["url"] != "https://connector.example.com/tickets" {
+		t.Errorf("expected the connector as the only server, got %v", doc["servers"])
+	}
+	// the headers of other clients do not choose the server
+	req := httptest.NewRequest("GET", "/_connector/docs/openapi.json?route=tickets", nil)
+	req.RemoteAddr = "203.0.113.9:1234"
+	req.Host = "attacker.example.com"
+	req.Header.Set("X-Forwarded-Host", "attacker.example.com")
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	var direct map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &direct)
+	if servers := direct["servers"].([]interface{}); len(servers) != 1 || servers[0].(map[string]interface{})["url"] != "/tickets" {
+		t.Errorf("expected a server relative to the document, got %v", direct["servers"])
+	}
+	if doc["security"] != nil || doc["components"] != nil {
+		t.Errorf("expected the security to be removed, got %v %v", doc["security"], doc["components"])
+	}
+	op := doc["paths"].(map[string]interface{})["/tickets"].(map[string]interface{})["get"].(map[string]interface{})
+	if op["operationId"] != "listTickets" || op["security"] != nil {
+		t.Errorf("unexpected operation %v", op)
+	}
+
+	if rr := get("/_connector/docs/openapi.json?route=unknown"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected unknown routes not to be found, got %d", rr.Code)
+	}
+	if rr := get("/_connector/docs"); rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/_connector/docs/" {
+		t.Errorf("expected a redirect to the docs directory, got %d %v", rr.Code, rr.Header())
+	}
+	if rr := get("/_connector/docs/index.html"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "/_connector/docs/openapi.json?route=tickets") {
+		t.Errorf("expected the UI to list the route document, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestOperationLabels(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ok": true}`)
+	}))
+	defer upstream.Close()
+
+	api := filepath.Join(t.TempDir(), "tickets.yaml")
+	err := os.WriteFile(api, []byte(`openapi: 3.0.3
+info: {title: Tickets, version: "2"}
+paths:
+  /tickets/{id}:
+    get:
+      operationId: getTicket
+      responses: {"200": {description: ticket}}
+  /tickets/{id}/notes:
+    get:
+      responses: {"200": {description: notes}}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("ROUTES", `[{"prefix": "/tickets", "serverUrl": "`+upstream.URL+`", "openapi": "`+api+`", "auth": {"type": "NONE"}}]`)
+	cfg := config.Get()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a := App{Router: r, Log: logging, Cfg: cfg}
+
+	for uri, expected := range map[string]Operation{
+		"/tickets/tickets/7":       {Route: "tickets", Name: "getTicket"},
+		"/tickets/tickets/8":       {Route: "tickets", Name: "getTicket"},
+		"/tickets/tickets/7/notes": {Route: "tickets", Name: "GET /tickets/{id}/notes"},
+		"/tickets/users/7":         {Route: "tickets", Name: Unmatched},
+		"/elsewhere":               {Route: Unmatched, Name: Unmatched},
+	} {
+		req, op := TrackOperation(httptest.NewRequest("GET", uri, nil))
+		a.commonMiddleware().ServeHTTP(httptest.NewRecorder(), req)
+		if *op != expected {
+			t.Errorf("%s: expected %+v, got %+v", uri, expected, *op)
+		}
+	}
+}
+
+func TestMock(t *testing.T) {
+	var calls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer upstream.Close()
+
+	api := filepath.Join(t.TempDir(), "tickets.yaml")
+	err := os.WriteFile(api, []byte(`openapi: 3.0.3
+info: {title: Tickets, version: "2"}
+servers: [{url: "`+upstream.URL+`"}]
+components:
+  securitySchemes:
+    key: {type: apiKey, in: header, name: X-Api-Key}
+security: [{key: []}]
+paths:
+  /tickets/{id}:
+    get:
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: integer}}
+      responses:
+        "200":
+          description: ticket
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  id: {type: integer}
+                  subject: {type: string}
+        "404":
+          description: not found
+          content:
+            application/json:
+              examples:
+                missing: {value: {error: no such ticket}}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("ROUTES", `[{"prefix": "/tickets", "openapi": "`+api+`", "mock": true}]`)
+	cfg := config.Get()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a := App{Router: r, Log: logging, Cfg: cfg}
+
+	call := func(uri, prefer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", uri, nil)
+		if prefer != "" {
+			req.Header.Set("Prefer", prefer)
+		}
+		rr := httptest.NewRecorder()
+		a.commonMiddleware().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := call("/tickets/tickets/7", "")
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != `{"id":0,"subject":"string"}` {
+		t.Errorf("expected a sample of the schema, got %d %s", rr.Code, rr.Body.String())
+	}
+	rr = call("/tickets/tickets/7", "code=404")
+	if rr.Code != http.StatusNotFound || strings.TrimSpace(rr.Body.String()) != `{"error":"no such ticket"}` {
+		t.Errorf("expected the example of the preferred response, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := call("/tickets/tickets/7", "code=500"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected an undescribed status to be rejected, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := call("/tickets/tickets/seven", ""); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "violations") {
+		t.Errorf("expected the request to be validated, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := call("/tickets/users", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected unknown operations not to be found, got %d %s", rr.Code, rr.Body.String())
+	}
+	if calls != 0 {
+		t.Errorf("expected the upstream not to be called, got %d calls", calls)
+	}
+}
+
+func TestResponseDriftBounded(t *testing.T) {
+	schema := &parser.Schema{Type: "object", Properties: map[string]*parser.Schema{
+		"id":     {Type: "integer"},
+		"labels": {Type: "object", AdditionalProperties: &parser.Schema{Type: "string"}},
+	}}
+	labels := make(map[string]interface{})
+	for i := 0; i < 10; i++ {
+		labels[fmt.Sprint("label", i)] = "x"
+	}
+	body := map[string]interface{}{"id": 1.0, "labels": labels, "extra": map[string]interface{}{"nested": true}}
+	var d driftTracker
+	d.record("tickets", "getTicket", 200, schema, body)
+	seen := d.entries[driftKey{"tickets", "getTicket", 200}].seen
+	for field := range seen {
+		if strings.HasPrefix(field, "/labels/") || strings.HasPrefix(field, "/extra/") {
+			t.Errorf("expected the keys of maps and undeclared objects not to be tracked, got %s", field)
+		}
+	}
+	if seen["/labels"] == nil || seen["/extra"] == nil {
+		t.Errorf("expected the fields themselves to be tracked, got %v", seen)
+	}
+
+	wide := make(map[string]interface{})
+	for i := 0; i < 2*maxDriftFields; i++ {
+		wide[fmt.Sprint("field", i)] = i
+	}
+	d.record("tickets", "getTicket", 200, schema, wide)
+	if len(seen) > maxDriftFields {
+		t.Errorf("expected at most %d fields, got %d", maxDriftFields, len(seen))
+	}
+}
+
+func TestTokenRequests(t *testing.T) {
+	var requests []string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		id, secret, _ := r.BasicAuth()
+		requests = append(requests, r.URL.Path+" "+r.PostForm.Get("grant_type")+" "+id+":"+secret+" "+r.PostForm.Get("client_id"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("refresh_token") == "revoked" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "`+r.PostForm.Get("grant_type")+`", "expires_in": 3600}`)
+	}))
+	defer endpoint.Close()
+
+	tests := []struct {
+		name    string
+		auth    config.Auth
+		token   string
+		request string
+	}{
+		{"client credentials", config.Auth{TokenURL: endpoint.URL + "/token", ClientID: "connector", ClientSecret: "s3cret"},
+			"client_credentials", "/token client_credentials connector:s3cret "},
+		{"public client", config.Auth{TokenURL: endpoint.URL + "/token", ClientID: "connector"},
+			"client_credentials", "/token client_credentials : connector"},
+		{"refresh url", config.Auth{TokenURL: endpoint.URL + "/token", RefreshURL: endpoint.URL + "/refresh", RefreshToken: "refresh", ClientID: "connector", ClientSecret: "s3cret"},
+			"refresh_token", "/refresh refresh_token connector:s3cret "},
+		{"revoked refresh token", config.Auth{TokenURL: endpoint.URL + "/token", RefreshToken: "revoked", ClientID: "connector", ClientSecret: "s3cret"},
+			"client_credentials", "/token client_credentials connector:s3cret "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			var cache tokenCache
+			token, err := cache.get(context.Background(), tt.auth)
+			if err != nil || token != tt.token {
+				t.Fatalf("expected a %s token, got %q %v", tt.token, token, err)
+			}
+			if last := requests[len(requests)-1]; last != tt.request {
+				t.Errorf("expected the request %q, got %q", tt.request, last)
+			}
+		})
+	}
+}
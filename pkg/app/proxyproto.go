@@ -0,0 +1,144 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyProtocolV2Signature starts every PROXY protocol version 2 header
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyProtoListener accepts connections that start with a PROXY protocol (v1 or v2)
+// header. Headers are only read from connections of trusted proxies, any other
+// connection is served with its own remote address. The trusted proxies are looked up for
+// every connection so they follow configuration reloads.
+type proxyProtoListener struct {
+	net.Listener
+	trusted func() []*net.IPNet
+}
+
+func newProxyProtoListener(l net.Listener, trusted func() []*net.IPNet) net.Listener {
+	return &proxyProtoListener{Listener: l, trusted: trusted}
+}
+
+func (l *proxyProtoListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok || !contains(l.trusted(), addr.IP) {
+		return conn, nil
+	}
+	return &proxyProtoConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// proxyProtoConn lazily reads the PROXY header so a slow proxy cannot block Accept
+type proxyProtoConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+func (c *proxyProtoConn) init() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		c.remoteAddr, c.err = readProxyHeader(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+		if c.err != nil {
+			rejectedClients.WithLabelValues("invalid_proxy_header").Inc()
+		}
+	})
+}
+
+func (c *proxyProtoConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *proxyProtoConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader parses a PROXY protocol header. A nil address is returned for
+// connections the proxy made on its own behalf (v1 UNKNOWN or v2 LOCAL).
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	peek, err := r.Peek(len(proxyProtocolV2Signature))
+	if err == nil && bytes.Equal(peek, proxyProtocolV2Signature) {
+		return readProxyHeaderV2(r)
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) > 107 || !strings.HasSuffix(line, "\r\n") {
+		return nil, errors.New("invalid PROXY protocol header")
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, errors.New("missing PROXY protocol header")
+	}
+	if fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid PROXY protocol header %q", strings.TrimSpace(line))
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil {
+		return nil, fmt.Errorf("invalid PROXY protocol source %s:%s", fields[2], fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, errors.New("unsupported PROXY protocol version")
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	// LOCAL command, the proxy is talking on its own behalf
+	if header[12]&0x0f == 0 {
+		return nil, nil
+	}
+	switch header[13] >> 4 {
+	case 1: // AF_INET
+		if len(payload) < 12 {
+			return nil, errors.New("short PROXY protocol v2 address block")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 2: // AF_INET6
+		if len(payload) < 36 {
+			return nil, errors.New("short PROXY protocol v2 address block")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// quotaMiddleware rejects callers that exceeded their quota with a 429 and adds the
+// quota headers to every response
+func (a *App) quotaMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter := a.stateOf(r).quota
+		if limiter == nil || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		res := limiter.Allow(getRequestInfo(r).Caller)
+		if res.Limit > 0 {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+		}
+		if !res.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+			respondWithError(w, http.StatusTooManyRequests, "quota exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// listUsage godoc
+// @Summary Get quota consumption per caller
+// @Description Get the number of requests made, rejected and counted against the current window for every caller
+// @Tags usage
+// @Produce  json
+// @Success 200 {object} object
+// @Router /_connector/usage [get]
+func (a *App) listUsage(w http.ResponseWriter, r *http.Request) {
+	limiter := a.current().quota
+	if limiter == nil {
+		respondWithError(w, http.StatusNotFound, "quotas are not enabled")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, limiter.Usage())
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kosha/passthrough-connector/pkg/config"
+)
+
+// recordingSaveInterval is how often the recorded traffic is saved to RECORDING_FILE
+const recordingSaveInterval = 30 * time.Second
+
+// followRecording saves the recorded traffic to the RECORDING_FILE of cfg, starting the
+// periodic saves the first time a file is configured, at startup or by a reload
+func (a *App) followRecording(cfg *config.Config) {
+	if a.recorder == nil {
+		return
+	}
+	_, file := cfg.GetRecording()
+	if err := a.recorder.SetPath(file); err != nil {
+		a.Log.Errorf("Unable to open the recorded traffic %s: %v", file, err)
+		return
+	}
+	if file != "" {
+		a.saving.Do(func() { go a.saveRecording() })
+	}
+}
+
+// saveRecording periodically saves the recorded traffic to its file
+func (a *App) saveRecording() {
+	for range time.Tick(recordingSaveInterval) {
+		if err := a.recorder.Save(); err != nil {
+			a.Log.Errorf("Unable to save the recorded traffic: %v", err)
+		}
+	}
+}
+
+// listRecordings godoc
+// @Summary List the recorded traffic
+// @Description Summarize, per route, the operations observed while RECORD_TRAFFIC is set. Served by the admin API.
+// @Tags recording
+// @Produce  json
+// @Success 200 {array} object
+// @Failure 401 {object} string "invalid admin token"
+// @Router /_connector/admin/recording [get]
+func (a *App) listRecordings(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, a.recorder.Routes())
+}
+
+// getRecording godoc
+// @Summary Get the OpenAPI document inferred from the traffic recorded on a route
+// @Description Describe the operations observed on a route as an OpenAPI 3 document. Served by the admin API.
+// @Tags recording
+// @Produce  json
+// @Param route path string true "Route name"
+// @Success 200 {object} object
+// @Failure 401 {object} string "invalid admin token"
+// @Failure 404 {object} string "no traffic recorded for the route"
+// @Router /_connector/admin/recording/{route} [get]
+func (a *App) getRecording(w http.ResponseWriter, r *http.Request) {
+	doc, err := a.recorder.Document(mux.Vars(r)["route"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, doc.OpenAPI())
+}
+
+// deleteRecording godoc
+// @Summary Delete the traffic recorded on a route
+// @Description Forget the operations observed on a route. Served by the admin API.
+// @Tags recording
+// @Param route path string true "Route name"
+// @Success 204
+// @Failure 401 {object} string "invalid admin token"
+// @Failure 404 {object} string "no traffic recorded for the route"
+// @Router /_connector/admin/recording/{route} [delete]
+func (a *App) deleteRecording(w http.ResponseWriter, r *http.Request) {
+	route := mux.Vars(r)["route"]
+	if err := a.recorder.Forget(route); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	a.Log.Infow("Recorded traffic deleted", "route", route)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"crypto/sha256"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// auditCloseDelay gives requests still using a replaced audit sink time to finish
+const auditCloseDelay = time.Minute
+
+// Reload reads the configuration again, validates it and makes it active. Requests in
+// flight finish with the configuration they started with. An invalid configuration is
+// rejected and the active one is kept.
+func (a *App) Reload(source string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	old := a.current()
+	cfg, err := old.cfg.Reload()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	var st *state
+	if err == nil {
+		st, err = a.newState(cfg, old)
+	}
+	if err != nil {
+		configReloads.WithLabelValues("failure").Inc()
+		a.Log.Errorf("Configuration reload from %s failed, keeping the active configuration: %v", source, err)
+		return err
+	}
+
+	if cfg.GetPort() != old.cfg.GetPort() || cfg.GetProxyProtocol() != old.cfg.GetProxyProtocol() {
+		a.Log.Warnf("PORT and PROXY_PROTOCOL changes take effect after a restart")
+	}
+	a.apply(st)
+	a.followRecording(cfg)
+	if old.audit != nil && old.audit != st.audit {
+		time.AfterFunc(auditCloseDelay, func() { _ = old.audit.Close() })
+	}
+
+	configReloads.WithLabelValues("success").Inc()
+	configLastReload.SetToCurrentTime()
+	a.Log.Infow("Configuration reloaded", "source", source, "routes", len(st.routes))
+	return nil
+}
+
+// WatchConfig reloads the configuration on SIGHUP and, when a configuration file is used,
+// whenever its content changes
+func (a *App) WatchConfig(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			_ = a.Reload("SIGHUP")
+		}
+	}()
+
+	path := a.current().cfg.GetConfigFile()
+	if path == "" || interval <= 0 {
+		return
+	}
+	go func() {
+		// compare contents rather than modification times, mounted config maps are
+		// replaced through symlinks
+		last := fileDigest(path)
+		for range time.Tick(interval) {
+			if digest := fileDigest(path); digest != last {
+				last = digest
+				_ = a.Reload(path)
+			}
+		}
+	}()
+}
+
+func fileDigest(path string) [sha256.Size]byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}
+	}
+	return sha256.Sum256(data)
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+	"github.com/kosha/passthrough-connector/pkg/recorder"
+)
+
+func TestReload(t *testing.T) {
+	first := echoServer()
+	defer first.Close()
+	second := echoServer()
+	defer second.Close()
+
+	path := filepath.Join(t.TempDir(), "connector.yaml")
+	write := func(serverUrl string) {
+		content := "upstream:\n  serverUrl: " + serverUrl + "\n  auth:\n    type: NONE\n"
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(first.URL + "/first")
+
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("AUTH_TYPE", "")
+	cfg, err := config.Load([]string{"--config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := App{Router: r, Log: logging, Cfg: cfg}
+	handler := a.requestInfoMiddleware(a.commonMiddleware())
+
+	call := func() string {
+		req := httptest.NewRequest("GET", "/items", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		var body map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		path, _ := body["path"].(string)
+		return path
+	}
+
+	if got := call(); got != "/first/items" {
+		t.Fatalf("expected /first/items before reload, got %q", got)
+	}
+
+	write(second.URL + "/second")
+	if err := a.Reload("test"); err != nil {
+		t.Fatalf("unexpected reload error: %v", err)
+	}
+	if got := call(); got != "/second/items" {
+		t.Errorf("expected /second/items after reload, got %q", got)
+	}
+
+	// an invalid configuration is rejected and the active one is kept
+	write("ftp://" + second.Listener.Addr().String())
+	if err := a.Reload("test"); err == nil {
+		t.Error("expected an invalid configuration to be rejected")
+	}
+	if got := call(); got != "/second/items" {
+		t.Errorf("expected the previous configuration to be kept, got %q", got)
+	}
+}
+
+func TestReloadRecordingFile(t *testing.T) {
+	upstream := echoServer()
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "connector.yaml")
+	recording := filepath.Join(dir, "recording.json")
+	write := func(recorder string) {
+		content := "upstream:\n  serverUrl: " + upstream.URL + "\n  auth:\n    type: NONE\nrecorder:\n  enabled: true\n" + recorder
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("")
+
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("AUTH_TYPE", "")
+	cfg, err := config.Load([]string{"--config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := App{Log: logging, Cfg: cfg}
+	a.Initialize(logging)
+	a.requestInfoMiddleware(a.commonMiddleware()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tickets/7", nil))
+
+	// a file configured by a reload receives the traffic recorded so far once the app is closed
+	write("  file: " + recording + "\n")
+	if err := a.Reload("test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := recorder.Open(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if routes := saved.Routes(); len(routes) != 1 || routes[0].Samples != 1 {
+		t.Errorf("expected the recorded call to be saved, got %+v", routes)
+	}
+}
@@ -6,7 +6,7 @@ import (
 
 // listConnectorSpecification godoc
 // @Summary Get details of the connector specification
-// @Description Get all environment variables that need to be supplied
+// @Description Get a JSON Schema of every setting, keyed by env var, with its type, default, secrecy, whether it is set and the credentials each auth type requires
 // @Tags specification
 // @Accept  json
 // @Produce  json
@@ -15,7 +15,7 @@ import (
 // @Failure      403  {object}  string "permission denied"
 // @Failure      404  {object}  string "not found"
 // @Failure      500  {object}  string "internal server error"
-// @Router /api/v2/specification/list [get]
+// @Router /_connector/specification [get]
 func (a *App) listConnectorSpecification(w http.ResponseWriter, r *http.Request) {
 
 	//Allow CORS here By * or specific origin
@@ -23,8 +23,15 @@ func (a *App) listConnectorSpecification(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Access-Control-Allow-Headers", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "*")
 
-	respondWithJSON(w, http.StatusOK, map[string]string{
-		"API_KEY":     "Freshservice API Key",
-		"DOMAIN_NAME": "Freshservice Domain Name",
-	})
+	respondWithJSON(w, http.StatusOK, a.current().cfg.Specification())
+}
+
+// health godoc
+// @Summary Check that the connector is running
+// @Tags health
+// @Produce  json
+// @Success 200 {object} object
+// @Router /_connector/health [get]
+func (a *App) health(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
@@ -1,13 +1,17 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strings"
-	"time"
 
+	"github.com/kosha/passthrough-connector/pkg/config"
 	"github.com/kosha/passthrough-connector/pkg/httpclient"
-	httpSwagger "github.com/swaggo/http-swagger"
+	"github.com/kosha/passthrough-connector/pkg/parser"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -19,6 +23,18 @@ const (
 	None        = "NONE"
 )
 
+// proxiedMethods are the methods of the requests the proxy forwards
+var proxiedMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+
+func isProxiedMethod(method string) bool {
+	for _, proxied := range proxiedMethods {
+		if method == proxied {
+			return true
+		}
+	}
+	return false
+}
+
 func (a *App) commonMiddleware() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -32,24 +48,97 @@ func (a *App) commonMiddleware() http.Handler {
 			return
 		}
 
-		serverUrl := a.Cfg.GetServerURL()
 		requestUri := r.RequestURI
+		if requestUri == "" {
+			requestUri = r.URL.RequestURI()
+		}
 		method := r.Method
 		queryParams := r.URL.Query().Encode()
 		var contentTypeHeaderFound bool
 
-		serverUrl += requestUri
+		st := a.stateOf(r)
+		tenant, tenantId, requestUri, err := resolveTenant(st, r, requestUri)
+		getRequestInfo(r).Tenant = tenantId
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		route, upstreamUri, err := a.matchRoute(st, requestUri)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if route == nil {
+			respondWithError(w, http.StatusNotFound, "no upstream route for "+requestUri)
+			return
+		}
+		routeName := route.Name
+		setOperation(r, routeName, nil)
+		if tenant != nil {
+			if !tenant.Serves(routeName) {
+				respondWithError(w, http.StatusNotFound, "tenant "+tenantId+" has no upstream for route "+routeName)
+				return
+			}
+			tenantRoute := tenant.Apply(*route)
+			route = &tenantRoute
+		}
+		mock := route.Mock || st.cfg.GetMock()
+
+		if !mock && (route.IsTemplate() || len(route.AllowedHosts) > 0) {
+			resolved := *route
+			if resolved.ServerURL, err = route.ResolveServerURL(r.Header.Get); err != nil {
+				a.Log.Errorf("Unable to resolve the upstream of route %s: %v", route.Name, err)
+				respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			route = &resolved
+		}
+
+		upstreamPath := upstreamUri
+		if !hasUpstreamPath(r) {
+			upstreamPath = route.RewritePath(upstreamUri)
+		}
+		api := st.apis[route.OpenAPI]
+		var op *parser.Operation
+		var pathParams map[string]string
+		if api != nil {
+			path, _, _ := strings.Cut(upstreamPath, "?")
+			op, pathParams = api.Match(method, path)
+		}
+		operation := setOperation(r, routeName, op)
+		if mock {
+			a.serveMock(w, r, st, route, api, op, pathParams, upstreamPath)
+			return
+		}
+		if api != nil && route.Auth.Type == "" {
+			derived := *route
+			if derived.Auth, err = operationAuth(api, op, route); err != nil {
+				a.Log.Errorf("Unable to authenticate %s %s on route %s: %v", method, upstreamPath, route.Name, err)
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			route = &derived
+		}
+
+		serverUrl, err := httpclient.JoinURL(route.GetServerURL(), upstreamPath)
+		if err != nil {
+			a.Log.Errorf("Rejected request uri %s: %v", requestUri, err)
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
 		if queryParams != "" && !strings.Contains(requestUri, "?") {
 			serverUrl += "?" + queryParams
 		}
-
-		var c interface{}
-		if r.Body != nil {
-			decoder := json.NewDecoder(r.Body)
-			_ = decoder.Decode(&c)
-			defer r.Body.Close()
+		// injected parameters are added before the request is signed so signatures cover them
+		if serverUrl, err = applyQueryPolicy(serverUrl, route.Query); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
 		}
+		getRequestInfo(r).UpstreamURL = serverUrl
+
+		c, hasBody, bodyErr := decodeBody(r)
 
 		headers := make(map[string]string)
 		// Loop over header names
@@ -72,112 +161,106 @@ func (a *App) commonMiddleware() http.Handler {
 		if !contentTypeHeaderFound {
 			headers["Content-Type"] = "application/json; charset=utf-8"
 		}
+		applyHeaderPolicy(headers, route.Headers)
 
-		authType := a.Cfg.GetAuthType()
-		switch authType {
-		default:
-			res, statusCode, err := httpclient.MakeHttpNoAuthCall(headers, method, serverUrl, c, a.Log)
-			if err != nil {
-				a.Log.Errorf("Encountered an error while making a call: %v\n", err)
-				respondWithError(w, statusCode, err.Error())
-				return
-			}
-			if (statusCode != 200) && (statusCode != 201) && res != nil {
-				a.Log.Errorf("Http response has a non-successful status code of %v with body %v", statusCode, res)
-			}
-			respondWithJSON(w, statusCode, res)
-			return
-		case ApiKey:
-			apiKeyHeaderName := a.Cfg.GetApiKeyHeaderName()
-			apiKey := a.Cfg.GetApiKey()
-
-			res, statusCode, err := httpclient.MakeHttpApiKeyCall(headers, apiKeyHeaderName, apiKey, method, serverUrl, c, a.Log)
-			if err != nil {
-				a.Log.Errorf("Encountered an error while making a call: %v\n", err)
-				respondWithError(w, statusCode, err.Error())
-				return
-			}
-			if (statusCode != 200) && (statusCode != 201) && res != nil {
-				a.Log.Errorf("Http response has a non-successful status code of %v with body %v", statusCode, res)
+		if mode := route.GetRequestValidation(st.cfg.GetRequestValidation()); op != nil && mode != config.ValidationOff {
+			if violations := validateRequest(op, pathParams, serverUrl, headers, c, hasBody, bodyErr); len(violations) > 0 {
+				if mode == config.ValidationEnforce {
+					respondWithJSON(w, http.StatusBadRequest, map[string]interface{}{
+						"error":      "the request does not match operation " + op.Name(),
+						"violations": violations,
+					})
+					return
+				}
+				a.Log.Warnf("Request %s %s does not match operation %s: %v", method, upstreamPath, op.Name(), violations)
 			}
-			respondWithJSON(w, statusCode, res)
-			return
-		case BearerToken:
-			bearerToken := a.Cfg.GetBearerToken()
+		}
 
-			res, statusCode, err := httpclient.MakeHttpBearerTokenCall(headers, bearerToken, method, serverUrl, c, a.Log)
-			if err != nil {
-				a.Log.Errorf("Encountered an error while making a call: %v\n", err)
-				respondWithError(w, statusCode, err.Error())
-				return
-			}
-			if (statusCode != 200) && (statusCode != 201) && res != nil {
-				a.Log.Errorf("Http response has a non-successful status code of %v with body %v", statusCode, res)
-			}
-			respondWithJSON(w, statusCode, res)
-			return
-		case BasicAuth:
-			username, password := a.Cfg.GetUsernameAndPassword()
+		ctx := r.Context()
+		if route.Timeout.Duration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, route.Timeout.Duration)
+			defer cancel()
+		}
 
-			res, statusCode, err := httpclient.MakeHttpBasicAuthCall(headers, username, password, method, serverUrl, c, a.Log)
-			if err != nil {
-				a.Log.Errorf("Encountered an error while making a call: %v\n", err)
-				respondWithError(w, statusCode, err.Error())
-				return
-			}
-			if (statusCode != 200) && (statusCode != 201) && res != nil {
-				a.Log.Errorf("Http response has a non-successful status code of %v with body %v", statusCode, res)
-			}
-			respondWithJSON(w, statusCode, res)
-			return
-		case HMAC:
-			ikey, skey := a.Cfg.GetDuoIKeyAndSKey()
-			currentTime := time.Now().UTC().Format(time.RFC1123Z)
-			headers := make(map[string]string)
-			headers["Authorization"] = sign(ikey, skey, method, a.Cfg.GetServerHost(), r.URL.Path, currentTime, r.URL.Query())
-			headers["Date"] = currentTime
-			res, statusCode, err := httpclient.MakeSignedHttpDuoCall(headers, method, a.Cfg.GetServerURL(), r.RequestURI, c, a.Log)
-			if err != nil {
-				a.Log.Errorf("Encountered an error while making a call: %v\n", err)
-				respondWithError(w, statusCode, err.Error())
-				return
-			}
-			if (statusCode != 200) && (statusCode != 201) && res != nil {
-				a.Log.Errorf("Http response has a non-successful status code of %v with body %v", statusCode, res)
+		res, statusCode, err := a.callUpstream(ctx, route, headers, method, serverUrl, c)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				statusCode = http.StatusGatewayTimeout
 			}
-			respondWithJSON(w, statusCode, res)
-			return
-		case Oauth:
-			accessToken := a.Cfg.GetAccessToken()
-			refreshToken := a.Cfg.GetRefreshToken()
-			expiresAt := a.Cfg.GetExpiresAt()
-
-			tokenMap := make(map[string]string)
-			tokenMap["access_token"] = accessToken
-			tokenMap["refresh_token"] = refreshToken
-			tokenMap["expires_at"] = expiresAt
-
-			res, statusCode, err := httpclient.MakeOAuth2ApiRequest(headers, serverUrl, method, c, tokenMap, a.Log)
-			if err != nil {
-				a.Log.Errorf("Encountered an error while making a call: %v\n", err)
-				respondWithError(w, statusCode, err.Error())
-				return
-			}
-			if (statusCode != 200) && (statusCode != 201) && res != nil {
-				a.Log.Errorf("Http response has a non-successful status code of %v with body %v", statusCode, res)
-			}
-			respondWithJSON(w, statusCode, res)
+			a.Log.Errorf("Encountered an error while calling operation %s of route %s: %v", operation, routeName, err)
+			respondWithError(w, statusCode, err.Error())
 			return
 		}
-
-		// Call the next handler, which can be another middleware in the chain, or the final handler.
-		// respondWithJSON(w, http.StatusOK, "Hello world")
+		if op != nil {
+			a.checkResponse(st, route.Name, op, statusCode, res)
+		}
+		// the path is recorded before injected query parameters, which may hold credentials
+		if recording, _ := st.cfg.GetRecording(); recording && a.recorder != nil {
+			a.recorder.Observe(routeName, route.GetServerURL(), method, upstreamPath, c, statusCode, res)
+		}
+		if (statusCode != 200) && (statusCode != 201) && res != nil {
+			a.Log.Errorf("Http response of operation %s of route %s has a non-successful status code of %v with body %v", operation, routeName, statusCode, res)
+		}
+		if st.cfg.GetRedactResponses() {
+			res = st.redactor.Value(res)
+		}
+		respondWithJSON(w, statusCode, res)
 	})
 }
 
+// decodeBody decodes the JSON body of the request, reporting whether there was one and
+// why it could not be decoded
+func decodeBody(r *http.Request) (interface{}, bool, error) {
+	if r.Body == nil {
+		return nil, false, nil
+	}
+	defer r.Body.Close()
+	var body interface{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if errors.Is(err, io.EOF) {
+		return nil, false, nil
+	}
+	return body, err == nil, err
+}
+
+// InitializeRoutes registers the connector's own endpoints under the reserved prefix, which
+// take precedence over the proxy, and then the proxy for every other path
 func (a *App) InitializeRoutes() {
-	a.Router.PathPrefix("/").Handler(a.commonMiddleware()).Methods("GET", "POST", "PUT", "DELETE", "OPTIONS")
+	prefix := a.current().cfg.GetReservedPrefix()
+	// unknown reserved paths are not found rather than proxied
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondWithError(w, http.StatusNotFound, "not found")
+	})
+	// a path prefix matches any string prefix, the slash keeps sibling paths such as
+	// /_connectors proxied
+	reserved := a.Router.PathPrefix(prefix + "/").Subrouter()
+	// the connector endpoints are restricted to the clients allowed to use the proxy
+	reserved.Use(a.requestInfoMiddleware, a.ipFilterMiddleware)
+	reserved.NotFoundHandler = notFound
+	// other methods are not allowed rather than proxied
+	reserved.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+	})
+	a.Router.Path(prefix).Handler(notFound)
+	reserved.HandleFunc("/health", a.health).Methods("GET")
+	reserved.HandleFunc("/specification", a.listConnectorSpecification).Methods("GET")
+	reserved.HandleFunc("/usage", a.listUsage).Methods("GET")
+	reserved.HandleFunc("/drift", a.listDrift).Methods("GET")
+	reserved.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	reserved.HandleFunc("/docs/openapi.json", a.apiDocument).Methods("GET")
+	// Swagger UI over the routes' OpenAPI documents
+	reserved.PathPrefix("/docs/").HandlerFunc(a.docs).Methods("GET")
+	reserved.Path("/docs").HandlerFunc(a.docs).Methods("GET")
+	// Model Context Protocol tools over the routes' OpenAPI documents, see MCP. Clients are
+	// audited and counted as they are for proxied requests.
+	reserved.Handle("/mcp", a.auditMiddleware(a.quotaMiddleware(http.HandlerFunc(a.serveMCP))))
+
+	a.Router.PathPrefix("/").Handler(a.proxyMiddleware(a.commonMiddleware())).Methods(proxiedMethods...)
+}
 
-	// Swagger
-	a.Router.PathPrefix("/docs").Handler(httpSwagger.WrapHandler)
+// proxyMiddleware wraps next with the middlewares of the requests of clients: request info,
+// audit, client filter and quota
+func (a *App) proxyMiddleware(next http.Handler) http.Handler {
+	return a.requestInfoMiddleware(a.auditMiddleware(a.ipFilterMiddleware(a.quotaMiddleware(next))))
 }
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"fmt"
+	"github.com/kosha/passthrough-connector/pkg/audit"
+	"github.com/kosha/passthrough-connector/pkg/config"
+	"github.com/kosha/passthrough-connector/pkg/httpclient"
+	"github.com/kosha/passthrough-connector/pkg/parser"
+	"github.com/kosha/passthrough-connector/pkg/quota"
+	"github.com/kosha/passthrough-connector/pkg/redact"
+	"net/http"
+	"strings"
+)
+
+// state is the configuration and everything derived from it. It is swapped as a whole
+// when the configuration is reloaded and every request keeps the state it started with.
+type state struct {
+	cfg      *config.Config
+	routes   []config.Route
+	redactor *redact.Redactor
+	audit    audit.Sink
+	quota    *quota.Limiter
+	ipFilter *ipFilter
+	egress   httpclient.Policy
+	tenants  map[string]config.Tenant
+	// apis are the OpenAPI documents of the routes, by file
+	apis map[string]*parser.Document
+	// tools are the operations of apis served over MCP
+	tools []*mcpTool
+}
+
+// newState builds the state for cfg and the routes managed through the admin API, reusing
+// the audit sink and quota counters of the previous state when their settings did not change
+func (a *App) newState(cfg *config.Config, previous *state) (*state, error) {
+	st := &state{cfg: cfg}
+
+	var err error
+	if st.routes, err = cfg.GetRoutes(); err != nil {
+		return nil, err
+	}
+	redactHeaders := cfg.GetRedactHeaders()
+	redactFields := cfg.GetRedactFields()
+	if source, _ := cfg.GetTenantResolver(); source != "" {
+		if st.tenants, err = cfg.GetTenants(); err != nil {
+			return nil, err
+		}
+		for _, tenant := range st.tenants {
+			if tenant.Auth.ApiKeyHeaderName != "" {
+				redactHeaders = append(redactHeaders, tenant.Auth.ApiKeyHeaderName)
+			}
+		}
+	}
+	if a.store != nil {
+		for _, route := range a.store.List() {
+			st.routes = append(st.routes, route)
+			if route.Auth.ApiKeyHeaderName != "" {
+				redactHeaders = append(redactHeaders, route.Auth.ApiKeyHeaderName)
+			}
+		}
+		config.SortRoutes(st.routes)
+	}
+	for i, route := range st.routes {
+		if route.OpenAPI == "" {
+			continue
+		}
+		api, ok := st.apis[route.OpenAPI]
+		if !ok {
+			if api, err = route.LoadAPI(); err != nil {
+				return nil, fmt.Errorf("route %s: %w", route.Name, err)
+			}
+			if st.apis == nil {
+				st.apis = make(map[string]*parser.Document)
+			}
+			st.apis[route.OpenAPI] = api
+		}
+		st.routes[i] = route.WithAPI(api)
+		for _, scheme := range api.SecuritySchemes {
+			if scheme.Type != parser.SchemeAPIKey {
+				continue
+			}
+			if scheme.In == parser.InHeader {
+				redactHeaders = append(redactHeaders, scheme.Name)
+			} else {
+				redactFields = append(redactFields, scheme.Name)
+			}
+		}
+	}
+	st.tools = mcpTools(st)
+	// keys sent in the query or a cookie are redacted wherever the URL or cookie is printed
+	for _, route := range st.routes {
+		if in := strings.ToLower(route.Auth.ApiKeyIn); (in == parser.InQuery || in == parser.InCookie) && route.Auth.ApiKeyHeaderName != "" {
+			redactFields = append(redactFields, route.Auth.ApiKeyHeaderName)
+		}
+	}
+	st.redactor = redact.New(redactFields, redactHeaders)
+
+	if dest := cfg.GetAuditLog(); dest != "" {
+		maxSizeMB, maxBackups := cfg.GetAuditRotation()
+		if previous != nil && previous.audit != nil && sameAudit(previous.cfg, cfg) {
+			st.audit = previous.audit
+		} else if st.audit, err = audit.New(dest, maxSizeMB, maxBackups); err != nil {
+			return nil, err
+		}
+	}
+
+	blocked := cfg.GetBlockedCIDRs()
+	if cfg.GetBlockPrivateIPs() {
+		blocked = append(blocked, httpclient.PrivateNetworks...)
+	}
+	networks, err := httpclient.ParseNetworks(blocked)
+	if err != nil {
+		return nil, err
+	}
+	redirects, redirectHosts := cfg.GetRedirectPolicy()
+	st.egress = httpclient.Policy{
+		Redirects:        redirects,
+		RedirectHosts:    redirectHosts,
+		BlockedNetworks:  networks,
+		SensitiveHeaders: redactHeaders,
+	}
+
+	allowed, denied := cfg.GetClientCIDRs()
+	if st.ipFilter, err = newIPFilter(allowed, denied, cfg.GetTrustedProxies()); err != nil {
+		return nil, err
+	}
+
+	if limit, window := cfg.GetQuota(); limit > 0 || len(cfg.GetQuotaOverrides()) > 0 {
+		if previous != nil && previous.quota != nil {
+			st.quota = previous.quota
+			st.quota.Update(limit, window, cfg.GetQuotaOverrides())
+		} else {
+			st.quota = quota.New(limit, window, cfg.GetQuotaOverrides())
+		}
+	}
+	return st, nil
+}
+
+func sameAudit(a, b *config.Config) bool {
+	aSize, aBackups := a.GetAuditRotation()
+	bSize, bBackups := b.GetAuditRotation()
+	return a.GetAuditLog() == b.GetAuditLog() && aSize == bSize && aBackups == bBackups
+}
+
+// current returns the active state, building it from Cfg when the app was assembled
+// without Initialize (e.g. in tests)
+func (a *App) current() *state {
+	if st, ok := a.state.Load().(*state); ok {
+		return st
+	}
+	st, err := a.newState(a.Cfg, nil)
+	if err != nil {
+		a.Log.Errorf("invalid configuration: %v", err)
+		st = &state{cfg: a.Cfg}
+	}
+	a.state.CompareAndSwap(nil, st)
+	return a.state.Load().(*state)
+}
+
+// stateOf returns the state the request started with
+func (a *App) stateOf(r *http.Request) *state {
+	if st := getRequestInfo(r).state; st != nil {
+		return st
+	}
+	return a.current()
+}
+
+// apply makes st the active state
+func (a *App) apply(st *state) {
+	httpclient.Configure(st.egress)
+	a.state.Store(st)
+}
+
+// stateRedactor redacts log lines with the redactor of the active state, so the logger
+// does not need to be rebuilt on reload
+type stateRedactor struct {
+	a *App
+}
+
+func (r stateRedactor) Value(v interface{}) interface{} {
+	return r.a.current().redactor.Value(v)
+}
+
+func (r stateRedactor) String(s string) string {
+	return r.a.current().redactor.String(s)
+}
+
+func (r stateRedactor) IsSensitiveKey(key string) bool {
+	return r.a.current().redactor.IsSensitiveKey(key)
+}
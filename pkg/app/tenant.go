@@ -0,0 +1,56 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+)
+
+var errUnknownTenant = errors.New("unknown tenant")
+
+// resolveTenant returns the tenant of the request and the request uri to route, without the
+// tenant path segment when the tenant is read from the path. It returns nil when requests
+// are not tenant aware and errUnknownTenant when the tenant is missing or not configured,
+// there is no fallback to the default upstream.
+func resolveTenant(st *state, r *http.Request, requestUri string) (*config.Tenant, string, string, error) {
+	source, key := st.cfg.GetTenantResolver()
+	if source == "" {
+		return nil, "", requestUri, nil
+	}
+
+	var id string
+	switch source {
+	case config.TenantFromHeader:
+		id = r.Header.Get(key)
+	case config.TenantFromPath:
+		id, requestUri = splitTenantSegment(requestUri)
+	case config.TenantFromClaim:
+		// claims are only trusted from tokens signed with JWT_SECRET, a forged token
+		// could otherwise name any tenant
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			id, _ = verifiedClaims(strings.TrimPrefix(auth, "Bearer "), st.cfg.GetJWTSecret())[key].(string)
+		}
+	}
+
+	tenant, ok := st.tenants[id]
+	if id == "" || !ok {
+		return nil, id, requestUri, errUnknownTenant
+	}
+	return &tenant, id, requestUri, nil
+}
+
+// splitTenantSegment returns the first path segment of requestUri and the uri without it
+func splitTenantSegment(requestUri string) (string, string) {
+	rest := strings.TrimPrefix(requestUri, "/")
+	end := strings.IndexAny(rest, "/?")
+	if end < 0 {
+		return rest, "/"
+	}
+	id, rest := rest[:end], rest[end:]
+	if !strings.HasPrefix(rest, "/") {
+		rest = "/" + rest
+	}
+	return id, rest
+}
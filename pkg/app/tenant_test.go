@@ -0,0 +1,159 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+)
+
+func TestTenants(t *testing.T) {
+	acme := echoServer()
+	defer acme.Close()
+	globex := echoServer()
+	defer globex.Close()
+
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	tenants := `{
+		"acme": {"serverUrl": "` + acme.URL + `/acme", "routes": ["default"], "auth": {"type": "API_KEY", "apiKey": "acme-key", "apiKeyHeaderName": "X-Key"}},
+		"globex": {"serverUrl": "` + globex.URL + `/globex", "routes": ["default"], "auth": {"type": "BEARER_TOKEN", "bearerToken": "globex-token"}},
+		"initech": {"serverUrl": "` + globex.URL + `/initech", "routes": ["billing"], "auth": {"type": "NONE"}}
+	}`
+	if err := os.WriteFile(path, []byte(tenants), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER_URL", "default.example.com")
+	t.Setenv("AUTH_TYPE", "NONE")
+	t.Setenv("TENANTS_FILE", path)
+	t.Setenv("JWT_SECRET", "secret")
+
+	sign := func(secret string, claims map[string]string) string {
+		payload, _ := json.Marshal(claims)
+		unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(unsigned))
+		return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+	jwt := sign("secret", map[string]string{"sub": "user", "org": "globex"})
+	forged := sign("guess", map[string]string{"sub": "user", "org": "globex"})
+	unsigned := "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"org":"globex"}`)) + "."
+
+	tests := []struct {
+		name       string
+		source     string
+		key        string
+		uri        string
+		header     map[string]string
+		statusCode int
+		path       string
+		credential string
+	}{
+		{"header", "header", "", "/tickets", map[string]string{"X-Tenant-Id": "acme"}, http.StatusOK, "/acme/tickets", "X-Key"},
+		{"custom header", "header", "X-Org", "/tickets", map[string]string{"X-Org": "globex"}, http.StatusOK, "/globex/tickets", "Authorization"},
+		{"path", "path", "", "/acme/tickets?page=2", nil, http.StatusOK, "/acme/tickets?page=2", "X-Key"},
+		{"claim", "claim", "org", "/tickets", map[string]string{"Authorization": "Bearer " + jwt}, http.StatusOK, "/globex/tickets", "Authorization"},
+		{"forged claim", "claim", "org", "/tickets", map[string]string{"Authorization": "Bearer " + forged}, http.StatusNotFound, "", ""},
+		{"unsigned claim", "claim", "org", "/tickets", map[string]string{"Authorization": "Bearer " + unsigned}, http.StatusNotFound, "", ""},
+		{"tenant of other routes", "header", "", "/tickets", map[string]string{"X-Tenant-Id": "initech"}, http.StatusNotFound, "", ""},
+		{"unknown tenant", "header", "", "/tickets", map[string]string{"X-Tenant-Id": "umbrella"}, http.StatusNotFound, "", ""},
+		{"missing tenant", "header", "", "/tickets", nil, http.StatusNotFound, "", ""},
+		{"unknown path tenant", "path", "", "/tickets", nil, http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TENANT_SOURCE", tt.source)
+			t.Setenv("TENANT_KEY", tt.key)
+			a := App{Router: r, Log: logging, Cfg: config.Get()}
+
+			req := httptest.NewRequest("GET", tt.uri, nil)
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			rr := httptest.NewRecorder()
+			a.requestInfoMiddleware(a.commonMiddleware()).ServeHTTP(rr, req)
+			if rr.Code != tt.statusCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.statusCode, rr.Code, rr.Body.String())
+			}
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+			var body struct {
+				Path    string
+				Headers map[string][]string
+			}
+			json.Unmarshal(rr.Body.Bytes(), &body)
+			if body.Path != tt.path {
+				t.Errorf("expected upstream path %s, got %s", tt.path, body.Path)
+			}
+			if len(body.Headers[tt.credential]) == 0 {
+				t.Errorf("expected the tenant credential in %s, got %v", tt.credential, body.Headers)
+			}
+		})
+	}
+}
+
+func TestTenantOpenAPICredentials(t *testing.T) {
+	upstream := echoServer()
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	api := filepath.Join(dir, "tickets.yaml")
+	err := os.WriteFile(api, []byte(`openapi: 3.0.3
+info: {title: Tickets, version: "2"}
+components:
+  securitySchemes:
+    key: {type: apiKey, in: header, name: X-Api-Key}
+security: [{key: []}]
+paths:
+  /tickets:
+    get:
+      responses: {"200": {description: tickets}}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenants := filepath.Join(dir, "tenants.json")
+	err = os.WriteFile(tenants, []byte(`{
+		"acme": {"serverUrl": "`+upstream.URL+`/acme", "routes": ["helpdesk"], "auth": {"apiKey": "acme-key"}},
+		"globex": {"serverUrl": "`+upstream.URL+`/globex", "routes": ["helpdesk"], "credentials": {"key": {"apiKey": "globex-key"}}}
+	}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("ROUTES", `[{"name": "helpdesk", "prefix": "/helpdesk", "serverUrl": "shared.example.com", "openapi": "`+api+`", "credentials": {"key": {"apiKey": "shared-key"}}}]`)
+	t.Setenv("TENANTS_FILE", tenants)
+	t.Setenv("TENANT_SOURCE", "header")
+	t.Setenv("TENANT_KEY", "")
+	cfg := config.Get()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a := App{Router: router(), Log: logging, Cfg: cfg}
+	a.InitializeRoutes()
+
+	for tenant, key := range map[string]string{"acme": "acme-key", "globex": "globex-key"} {
+		req := httptest.NewRequest("GET", "/helpdesk/tickets", nil)
+		req.Header.Set("X-Tenant-Id", tenant)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		var body struct {
+			Path    string
+			Headers map[string][]string
+		}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		if rr.Code != http.StatusOK || body.Path != "/"+tenant+"/tickets" {
+			t.Fatalf("expected the tenant's upstream, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if got := body.Headers["X-Api-Key"]; len(got) != 1 || got[0] != key {
+			t.Errorf("expected tenant %s to send %s and never the route's key, got %v", tenant, key, got)
+		}
+	}
+}
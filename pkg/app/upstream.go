@@ -0,0 +1,157 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+	"github.com/kosha/passthrough-connector/pkg/httpclient"
+	"github.com/kosha/passthrough-connector/pkg/parser"
+)
+
+// matchRoute returns the most specific route for the request uri along with the uri
+// relative to the route prefix, or a nil route if no route matches
+func (a *App) matchRoute(st *state, requestUri string) (*config.Route, string, error) {
+	u, err := url.ParseRequestURI(requestUri)
+	if err != nil {
+		return nil, "", err
+	}
+	routes := st.routes
+	for i := range routes {
+		if path, ok := routes[i].Match(u.EscapedPath()); ok {
+			if u.RawQuery != "" {
+				path += "?" + u.RawQuery
+			}
+			return &routes[i], path, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// operationAuth returns the auth required by op, the operation of the route's OpenAPI
+// document matching the request, or by the document when no operation matches
+func operationAuth(api *parser.Document, op *parser.Operation, route *config.Route) (config.Auth, error) {
+	requirements := api.Security
+	if op != nil {
+		requirements = api.SecurityOf(op)
+	}
+	return route.SecurityAuth(api, requirements)
+}
+
+// applyHeaderPolicy removes, defaults and sets the headers configured for a route
+func applyHeaderPolicy(headers map[string]string, policy config.ParamPolicy) {
+	find := func(name string) (string, bool) {
+		for header := range headers {
+			if http.CanonicalHeaderKey(header) == http.CanonicalHeaderKey(name) {
+				return header, true
+			}
+		}
+		return "", false
+	}
+	for _, name := range policy.Remove {
+		for header, ok := find(name); ok; header, ok = find(name) {
+			delete(headers, header)
+		}
+	}
+	for name, value := range policy.Default {
+		if _, ok := find(name); !ok {
+			headers[name] = value
+		}
+	}
+	for name, value := range policy.Set {
+		if header, ok := find(name); ok {
+			delete(headers, header)
+		}
+		headers[name] = value
+	}
+}
+
+// applyQueryPolicy removes, defaults and sets the query parameters configured for a route
+func applyQueryPolicy(serverUrl string, policy config.ParamPolicy) (string, error) {
+	if len(policy.Remove) == 0 && len(policy.Default) == 0 && len(policy.Set) == 0 {
+		return serverUrl, nil
+	}
+	u, err := url.Parse(serverUrl)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	for _, name := range policy.Remove {
+		query.Del(name)
+	}
+	for name, value := range policy.Default {
+		if !query.Has(name) {
+			query.Set(name, value)
+		}
+	}
+	for name, value := range policy.Set {
+		query.Set(name, value)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// callUpstream forwards the request to the upstream server using the route's auth type
+func (a *App) callUpstream(ctx context.Context, route *config.Route, headers map[string]string, method, serverUrl string, body interface{}) (interface{}, int, error) {
+	auth := route.Auth
+	switch auth.GetType() {
+	case None, "":
+		return httpclient.MakeHttpNoAuthCall(ctx, headers, method, serverUrl, body, a.Log)
+	case ApiKey:
+		switch strings.ToLower(auth.ApiKeyIn) {
+		case "query":
+			serverUrl, err := applyQueryPolicy(serverUrl, config.ParamPolicy{Set: map[string]string{auth.ApiKeyHeaderName: auth.ApiKey}})
+			if err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+			return httpclient.MakeHttpNoAuthCall(ctx, headers, method, serverUrl, body, a.Log)
+		case "cookie":
+			cookie := (&http.Cookie{Name: auth.ApiKeyHeaderName, Value: auth.ApiKey}).String()
+			for name, value := range headers {
+				if http.CanonicalHeaderKey(name) == "Cookie" {
+					delete(headers, name)
+					cookie = value + "; " + cookie
+				}
+			}
+			headers["Cookie"] = cookie
+			return httpclient.MakeHttpNoAuthCall(ctx, headers, method, serverUrl, body, a.Log)
+		}
+		return httpclient.MakeHttpApiKeyCall(ctx, headers, auth.ApiKeyHeaderName, auth.ApiKey, method, serverUrl, body, a.Log)
+	case BearerToken:
+		return httpclient.MakeHttpBearerTokenCall(ctx, headers, auth.BearerToken, method, serverUrl, body, a.Log)
+	case BasicAuth:
+		return httpclient.MakeHttpBasicAuthCall(ctx, headers, auth.Username, auth.Password, method, serverUrl, body, a.Log)
+	case HMAC:
+		upstream, err := url.Parse(serverUrl)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		currentTime := time.Now().UTC().Format(time.RFC1123Z)
+		// caller headers are not forwarded to HMAC upstreams, only those injected by the route
+		headers := make(map[string]string)
+		applyHeaderPolicy(headers, route.Headers)
+		headers["Authorization"] = sign(auth.IKey, auth.SKey, method, route.GetServerHost(), upstream.Path, currentTime, upstream.Query())
+		headers["Date"] = currentTime
+		return httpclient.MakeSignedHttpDuoCall(ctx, headers, method, serverUrl, body, a.Log)
+	case Oauth:
+		tokenMap := make(map[string]string)
+		tokenMap["access_token"] = auth.AccessToken
+		if auth.CanRefresh() {
+			accessToken, err := a.tokens.get(ctx, auth)
+			if err != nil {
+				return nil, http.StatusBadGateway, err
+			}
+			tokenMap["access_token"] = accessToken
+		}
+		tokenMap["refresh_token"] = auth.RefreshToken
+		tokenMap["expires_at"] = auth.ExpiresAt
+
+		return httpclient.MakeOAuth2ApiRequest(ctx, headers, serverUrl, method, body, tokenMap, a.Log)
+	default:
+		return nil, http.StatusInternalServerError, fmt.Errorf("unsupported auth type %q", auth.Type)
+	}
+}
@@ -0,0 +1,246 @@
+package app
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+)
+
+// echoServer responds with the path and headers of the requests it receives
+func echoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"path":    r.URL.RequestURI(),
+			"headers": r.Header,
+		})
+	}))
+}
+
+func TestMultiUpstreamRouting(t *testing.T) {
+	freshservice := echoServer()
+	defer freshservice.Close()
+	duo := echoServer()
+	defer duo.Close()
+
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("ROUTES", `[
+		{"prefix": "/freshservice/", "serverUrl": "`+freshservice.URL+`/api/v2",
+		 "auth": {"type": "API_KEY", "apiKey": "fs-key", "apiKeyHeaderName": "X-Fs-Key"},
+		 "headers": {"set": {"X-Team": "it"}, "remove": ["X-Internal"]}},
+		{"name": "duo", "prefix": "/duo", "serverUrl": "`+duo.URL+`", "timeout": "5s",
+		 "auth": {"type": "bearer token", "bearerToken": "duo-token"}}
+	]`)
+	a := App{Router: r, Log: logging, Cfg: config.Get()}
+
+	call := func(uri string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("GET", uri, nil)
+		req.Header.Set("X-Internal", "1")
+		rr := httptest.NewRecorder()
+		a.commonMiddleware().ServeHTTP(rr, req)
+		var body map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr.Code, body
+	}
+
+	status, body := call("/freshservice/tickets?page=2")
+	if status != http.StatusOK || body["path"] != "/api/v2/tickets?page=2" {
+		t.Fatalf("unexpected freshservice response %d %v", status, body)
+	}
+	headers := body["headers"].(map[string]interface{})
+	if headers["X-Fs-Key"] == nil || headers["X-Team"] == nil || headers["X-Internal"] != nil {
+		t.Errorf("route auth or header policy not applied: %v", headers)
+	}
+
+	status, body = call("/duo/admin/v1/users")
+	if status != http.StatusOK || body["path"] != "/admin/v1/users" {
+		t.Fatalf("unexpected duo response %d %v", status, body)
+	}
+	if auth := body["headers"].(map[string]interface{})["Authorization"].([]interface{}); auth[0] != "Bearer duo-token" {
+		t.Errorf("expected duo bearer token, got %v", auth)
+	}
+
+	// without SERVER_URL there is no catch-all route
+	if status, _ := call("/duologin"); status != http.StatusNotFound {
+		t.Errorf("expected 404 for a path outside every route, got %d", status)
+	}
+}
+
+func TestTemplatedRoute(t *testing.T) {
+	upstream := echoServer()
+	defer upstream.Close()
+	host, port, _ := net.SplitHostPort(upstream.Listener.Addr().String())
+
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("ROUTES", `[{"prefix": "/fs", "serverUrl": "http://{host}:`+port+`/api/{version}",
+		"variables": {"host": {"header": "X-Upstream-Host"}, "version": {"default": "v2"}},
+		"allowedHosts": ["`+host+`"],
+		"rewrite": [{"match": "^/users/([0-9]+)$", "replace": "/requesters/$1"}]}]`)
+	a := App{Router: r, Log: logging, Cfg: config.Get()}
+
+	call := func(uri, upstreamHost string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("GET", uri, nil)
+		req.Header.Set("X-Upstream-Host", upstreamHost)
+		rr := httptest.NewRecorder()
+		a.commonMiddleware().ServeHTTP(rr, req)
+		var body map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr.Code, body
+	}
+
+	status, body := call("/fs/users/42", host)
+	if status != http.StatusOK || body["path"] != "/api/v2/requesters/42" {
+		t.Fatalf("unexpected response %d %v", status, body)
+	}
+	if status, _ = call("/fs/users/42", "169.254.169.254"); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for a host outside the allowlist, got %d", status)
+	}
+	if status, _ = call("/fs/users/42", ""); status != http.StatusBadRequest {
+		t.Errorf("expected 400 without the host header, got %d", status)
+	}
+}
+
+func TestParamInjection(t *testing.T) {
+	upstream := echoServer()
+	defer upstream.Close()
+
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("ROUTES", `[
+		{"prefix": "/azure", "serverUrl": "`+upstream.URL+`",
+		 "query": {"default": {"api-version": "2023-01-01"}, "set": {"expand": "fields"}, "remove": ["debug"]},
+		 "headers": {"default": {"Accept": "application/json"}, "set": {"X-Client": "connector"}, "remove": ["X-Debug"]}},
+		{"prefix": "/duo", "serverUrl": "`+upstream.URL+`",
+		 "auth": {"type": "HMAC", "ikey": "ikey", "skey": "skey"},
+		 "query": {"set": {"limit": "100"}}, "headers": {"set": {"X-Client": "connector"}}}
+	]`)
+	a := App{Router: r, Log: logging, Cfg: config.Get()}
+
+	call := func(uri string, headers map[string]string) map[string]interface{} {
+		req := httptest.NewRequest("GET", uri, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		a.commonMiddleware().ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+		}
+		var body map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		return body
+	}
+	header := func(body map[string]interface{}, name string) interface{} {
+		if values, ok := body["headers"].(map[string]interface{})[name].([]interface{}); ok {
+			return values[0]
+		}
+		return nil
+	}
+
+	body := call("/azure/items?expand=none&debug=1", map[string]string{"X-Debug": "1", "X-Client": "caller"})
+	if body["path"] != "/items?api-version=2023-01-01&expand=fields" {
+		t.Errorf("unexpected upstream query %v", body["path"])
+	}
+	if header(body, "Accept") != "application/json" || header(body, "X-Client") != "connector" || header(body, "X-Debug") != nil {
+		t.Errorf("unexpected upstream headers %v", body["headers"])
+	}
+
+	// defaults do not override values sent by the caller
+	body = call("/azure/items?api-version=2024-01-01", map[string]string{"Accept": "text/csv"})
+	if body["path"] != "/items?api-version=2024-01-01&expand=fields" || header(body, "Accept") != "text/csv" {
+		t.Errorf("expected caller values to be kept, got %v %v", body["path"], body["headers"])
+	}
+
+	// the HMAC signature covers the injected query parameters
+	body = call("/duo/admin/v1/users", nil)
+	u, _ := url.Parse(body["path"].(string))
+	if u.Query().Get("limit") != "100" || header(body, "X-Client") != "connector" {
+		t.Fatalf("expected injected parameters upstream, got %v %v", body["path"], body["headers"])
+	}
+	expected := sign("ikey", "skey", "GET", strings.TrimPrefix(upstream.URL, "http://"), u.Path, header(body, "Date").(string), u.Query())
+	if header(body, "Authorization") != expected {
+		t.Errorf("expected the signature to cover the injected query, got %v", header(body, "Authorization"))
+	}
+}
+
+func TestReservedPrefix(t *testing.T) {
+	upstream := echoServer()
+	defer upstream.Close()
+
+	t.Setenv("SERVER_URL", upstream.URL)
+	t.Setenv("AUTH_TYPE", "NONE")
+	t.Setenv("ROUTES", "")
+	a := App{Router: router(), Log: logging, Cfg: config.Get()}
+	a.InitializeRoutes()
+
+	call := func(uri string) (int, map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, httptest.NewRequest("GET", uri, nil))
+		var body map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr.Code, body
+	}
+
+	if status, body := call("/_connector/health"); status != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("expected the health endpoint, got %d %v", status, body)
+	}
+	if status, body := call("/_connector/specification"); status != http.StatusOK || body["properties"] == nil {
+		t.Errorf("expected the specification endpoint, got %d", status)
+	}
+	if status, body := call("/_connector/unknown"); status != http.StatusNotFound || body["path"] != nil {
+		t.Errorf("expected unknown reserved paths not to be proxied, got %d %v", status, body)
+	}
+	if status, _ := call("/_connector"); status != http.StatusNotFound {
+		t.Errorf("expected the reserved prefix not to be proxied, got %d", status)
+	}
+	for _, method := range []string{"POST", "PUT", "DELETE", "OPTIONS"} {
+		for _, uri := range []string{"/_connector/health", "/_connector/metrics", "/_connector/docs/index.html", "/_connector/unknown"} {
+			rr := httptest.NewRecorder()
+			a.Router.ServeHTTP(rr, httptest.NewRequest(method, uri, nil))
+			var body map[string]interface{}
+			json.Unmarshal(rr.Body.Bytes(), &body)
+			expected := http.StatusMethodNotAllowed
+			if uri == "/_connector/unknown" {
+				expected = http.StatusNotFound
+			}
+			if rr.Code != expected || body["path"] != nil {
+				t.Errorf("expected %s %s not to be proxied, got %d %v", method, uri, rr.Code, body)
+			}
+		}
+	}
+	// connector endpoints no longer hide upstream paths, nor do they hide sibling prefixes
+	for _, uri := range []string{"/docs/index.html", "/metrics", "/api/v2/usage", "/_connectors/x", "/_connectorfoo", "/_connector_health"} {
+		if status, body := call(uri); status != http.StatusOK || body["path"] != uri {
+			t.Errorf("expected %s to be proxied, got %d %v", uri, status, body)
+		}
+	}
+}
+
+func TestQueryKeyNotLeaked(t *testing.T) {
+	// a closed port makes the transport fail with the upstream URL in its error
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("ROUTES", `[{"name": "tickets", "prefix": "/", "serverUrl": "http://`+address+`",
+		"auth": {"type": "API_KEY", "apiKey": "SUPERSECRET123", "apiKeyHeaderName": "api_key", "apiKeyIn": "query"}}]`)
+	a := App{Log: logging, Cfg: config.Get()}
+
+	rr := httptest.NewRecorder()
+	a.commonMiddleware().ServeHTTP(rr, httptest.NewRequest("GET", "/tickets?page=2", nil))
+	if rr.Code < http.StatusInternalServerError || strings.Contains(rr.Body.String(), "SUPERSECRET123") {
+		t.Errorf("expected the error without the key, got %d %s", rr.Code, rr.Body.String())
+	}
+	if got := a.current().redactor.String("GET /tickets?api_key=SUPERSECRET123"); strings.Contains(got, "SUPERSECRET123") {
+		t.Errorf("expected query keys to be redacted from logs, got %s", got)
+	}
+}
@@ -0,0 +1,29 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/kosha/passthrough-connector/pkg/parser"
+)
+
+// validateRequest returns the violations of the request about to be sent upstream against
+// op. The query and headers are validated after the route policies are applied, so values
+// injected by the connector satisfy required parameters.
+func validateRequest(op *parser.Operation, pathParams map[string]string, serverUrl string, headers map[string]string, body interface{}, hasBody bool, bodyErr error) []parser.Violation {
+	req := parser.Request{PathParams: pathParams, Header: make(http.Header), Body: body, HasBody: hasBody}
+	if u, err := url.Parse(serverUrl); err == nil {
+		req.Query = u.Query()
+	}
+	for name, value := range headers {
+		req.Header.Add(name, value)
+	}
+	if bodyErr != nil && op.RequestBody != nil {
+		// a body that cannot be decoded is reported as such rather than as missing
+		withoutBody := *op
+		withoutBody.RequestBody = nil
+		violations := withoutBody.ValidateRequest(req)
+		return append(violations, parser.Violation{In: "body", Message: "must be valid JSON: " + bodyErr.Error()})
+	}
+	return op.ValidateRequest(req)
+}
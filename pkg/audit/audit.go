@@ -0,0 +1,174 @@
+// Package audit writes a durable, one JSON line per request record of every call
+// proxied through the connector. It is deliberately separate from the operational
+// logger so audit records are never sampled, filtered by level or mixed with debug output.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stdout is the destination value that writes audit records to standard output
+const Stdout = "stdout"
+
+// Entry is a single audit record
+type Entry struct {
+	Timestamp  time.Time `json:"timestamp"`
+	RequestID  string    `json:"request_id"`
+	Caller     string    `json:"caller"`
+	Tenant     string    `json:"tenant,omitempty"`
+	Method     string    `json:"method"`
+	Operation  string    `json:"operation,omitempty"`
+	URL        string    `json:"url"`
+	Status     int       `json:"status"`
+	LatencyMs  float64   `json:"latency_ms"`
+	BytesIn    int64     `json:"bytes_in"`
+	BytesOut   int64     `json:"bytes_out"`
+	BodySHA256 string    `json:"body_sha256,omitempty"`
+}
+
+// Sink receives audit entries
+type Sink interface {
+	Write(e *Entry) error
+	Close() error
+}
+
+// New creates a Sink writing to stdout or to the file at dest. Files are rotated once
+// they grow beyond maxSizeMB, keeping at most maxBackups rotated files.
+func New(dest string, maxSizeMB, maxBackups int) (Sink, error) {
+	if dest == Stdout {
+		return &writerSink{w: os.Stdout}, nil
+	}
+	s := &fileSink{
+		path:       dest,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type writerSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *writerSink) Write(e *Entry) error {
+	line, err := marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(line)
+	return err
+}
+
+func (s *writerSink) Close() error {
+	return nil
+}
+
+type fileSink struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// rotate shifts path.N to path.N+1, dropping the oldest, and moves the current file to path.1
+func (s *fileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if s.maxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxBackups))
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+		}
+		if err := os.Rename(s.path, s.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *fileSink) Write(e *Entry) error {
+	line, err := marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func marshal(e *Entry) ([]byte, error) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+var sensitiveParams = []string{"key", "token", "secret", "password", "signature", "sig", "auth", "credential"}
+
+// StripURL removes userinfo and masks the values of query parameters that look like credentials
+func StripURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	query := u.Query()
+	for name := range query {
+		lower := strings.ToLower(name)
+		for _, sensitive := range sensitiveParams {
+			if strings.Contains(lower, sensitive) {
+				query.Set(name, "REDACTED")
+				break
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -2,49 +2,136 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	apiKey           string
-	apiKeyHeaderName string
-	bearerToken      string
-	serverUrl        string
-	username         string
-	password         string
-	authType         string
-	ikey             string
-	sKey             string
-	accessToken      string
-	refreshToken     string
-	expiresAt        string
+	apiKey            string
+	apiKeyHeaderName  string
+	apiKeyIn          string
+	bearerToken       string
+	serverUrl         string
+	username          string
+	password          string
+	authType          string
+	ikey              string
+	sKey              string
+	accessToken       string
+	refreshToken      string
+	expiresAt         string
+	tokenUrl          string
+	refreshUrl        string
+	clientId          string
+	clientSecret      string
+	openapi           string
+	requestValidation string
+	responseRate      int
+	driftReport       bool
+	mock              bool
+	recordTraffic     bool
+	recordingFile     string
+	mcp               bool
+	redactFields      string
+	redactHeaders     string
+	redactResponses   bool
+	callerIdHeader    string
+	callerKeyHeader   string
+	callerKeys        string
+	auditLog          string
+	auditMaxSizeMB    int
+	auditMaxBackups   int
+	auditHashBodies   bool
+	quotaLimit        int
+	quotaWindow       time.Duration
+	quotaOverrides    string
+	redirectPolicy    string
+	redirectHosts     string
+	blockPrivateIPs   bool
+	blockedCIDRs      string
+	allowedClients    string
+	deniedClients     string
+	trustedProxies    string
+	proxyProtocol     bool
+	routes            string
+	port              int
+
+	reservedPrefix      string
+	configWatchInterval time.Duration
+	adminPort           int
+	adminToken          string
+	adminStore          string
+	tenantSource        string
+	tenantKey           string
+	tenantsFile         string
+	jwtSecret           string
+
+	args       []string
+	set        map[string]bool
+	file       string
+	fileRoutes []Route
 }
 
+// Get returns the configuration read from the environment and, when CONFIG_FILE is set,
+// from the configuration file. It panics if the configuration cannot be read.
 func Get() *Config {
-	conf := &Config{}
-	// creating a new flagset everytime the get function is called allows for different flagsets to exist
+	conf, err := Load(nil)
+	if err != nil {
+		panic(err)
+	}
+	return conf
+}
+
+// Load returns the configuration read from the command line arguments, the environment and
+// the configuration file given by --config or CONFIG_FILE. Command line flags take precedence
+// over env vars, which take precedence over the configuration file and then the defaults.
+func Load(args []string) (*Config, error) {
+	conf := &Config{args: args}
+	// creating a new flagset everytime the load function is called allows for different flagsets to exist
 	// rather than a conflict to be created when generating a new config object (such as for tests)
-	flags := flag.NewFlagSet("Passthrough Flag Set", flag.PanicOnError)
-	flags.StringVar(&conf.username, "username", os.Getenv("USERNAME"), "Basic Auth username")
-	flags.StringVar(&conf.password, "password", os.Getenv("PASSWORD"), "Basic Auth password")
-	flags.StringVar(&conf.authType, "authType", os.Getenv("AUTH_TYPE"), "Auth Type")
-	flags.StringVar(&conf.apiKeyHeaderName, "apiKeyHeaderName", os.Getenv("API_KEY_HEADER_NAME"), "API Key Header Name")
-	flags.StringVar(&conf.apiKey, "apiKey", os.Getenv("API_KEY"), "API Key")
-	flags.StringVar(&conf.bearerToken, "bearerToken", os.Getenv("BEARER_TOKEN"), "Bearer Token")
-	flags.StringVar(&conf.ikey, "ikey", os.Getenv("IKEY"), "Duo Security IKey")
-	flags.StringVar(&conf.sKey, "skey", os.Getenv("SKEY"), "Duo Security SKey")
-	flags.StringVar(&conf.serverUrl, "serverUrl", os.Getenv("SERVER_URL"), "Server Url")
-	flags.StringVar(&conf.accessToken, "accessToken", os.Getenv("ACCESS_TOKEN"), "Oauth2 Access Token")
-	flags.StringVar(&conf.refreshToken, "refreshToken", os.Getenv("REFRESH_TOKEN"), "Oauth2 Refresh Token")
-	flags.StringVar(&conf.expiresAt, "expiresAt", os.Getenv("EXPIRES_AT"), "Oauth2 Expires At")
-
-	var arguments []string
-	arguments = append(arguments, "os.Environ")
-	flags.Parse(arguments)
+	flags := flag.NewFlagSet("passthrough-connector", flag.ContinueOnError)
 
-	return conf
+	conf.file = configFilePath(args)
+	file, err := readFile(conf.file)
+	if err != nil {
+		return nil, err
+	}
+	flags.String("config", conf.file, "Path of the YAML or JSON configuration file")
+
+	var errs []string
+	for _, b := range conf.bindings() {
+		if err := b.register(flags, file); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %s", strings.Join(errs, "; "))
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// remember which settings were supplied, by env var name
+	conf.set = make(map[string]bool)
+	flagged := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { flagged[f.Name] = true })
+	for _, b := range conf.bindings() {
+		_, inFile := file.get(b.Key)
+		conf.set[b.Env] = flagged[b.Flag] || os.Getenv(b.Env) != "" || inFile
+	}
+	if file != nil && len(file.Routes) > 0 {
+		conf.set["ROUTES"] = true
+	}
+
+	// structured routes only exist in the file, ROUTES or -routes replace them entirely
+	if conf.routes == "" && file != nil {
+		conf.fileRoutes = file.Routes
+	}
+	return conf, nil
 }
 
 func (c *Config) GetApiKey() string {
@@ -62,8 +149,12 @@ func (c *Config) GetBearerToken() string {
 // GetAuthType returns the auth type accepted by the server
 // Possible values include: API_KEY, BASIC_AUTH, HMAC
 func (c *Config) GetAuthType() string {
+	return normalizeAuthType(c.authType)
+}
+
+func normalizeAuthType(authType string) string {
 	// convert all characters to upper case
-	authType := strings.ToUpper(c.authType)
+	authType = strings.ToUpper(authType)
 	// replace space, hyphen with underscore
 	authType = strings.ReplaceAll(authType, " ", "_")
 	authType = strings.ReplaceAll(authType, "%20", "_")
@@ -91,9 +182,240 @@ func (c *Config) GetDuoIKeyAndSKey() (string, string) {
 	return c.ikey, c.sKey
 }
 
+// GetRedactFields returns the JSON path patterns whose values are masked
+func (c *Config) GetRedactFields() []string {
+	return splitList(c.redactFields)
+}
+
+// GetRedactHeaders returns the header names whose values are masked, in addition to the
+// standard credential headers and the configured API key header
+func (c *Config) GetRedactHeaders() []string {
+	headers := splitList(c.redactHeaders)
+	if c.apiKeyHeaderName != "" {
+		headers = append(headers, c.apiKeyHeaderName)
+	}
+	routes, _ := parseRoutes(c.routes)
+	for _, route := range append(routes, c.fileRoutes...) {
+		if route.Auth.ApiKeyHeaderName != "" {
+			headers = append(headers, route.Auth.ApiKeyHeaderName)
+		}
+	}
+	return headers
+}
+
+// GetRedactResponses returns true if redaction also applies to responses returned to callers
+func (c *Config) GetRedactResponses() bool {
+	return c.redactResponses
+}
+
+// GetCallerIdHeader returns the request header used to identify callers
+func (c *Config) GetCallerIdHeader() string {
+	return c.callerIdHeader
+}
+
+// GetAuditLog returns the audit log destination, empty when auditing is disabled
+func (c *Config) GetAuditLog() string {
+	return c.auditLog
+}
+
+// GetAuditRotation returns the maximum audit log size in megabytes and the number of rotated files to keep
+func (c *Config) GetAuditRotation() (int, int) {
+	return c.auditMaxSizeMB, c.auditMaxBackups
+}
+
+func (c *Config) GetAuditHashBodies() bool {
+	return c.auditHashBodies
+}
+
+// GetQuota returns the default per caller quota and its window, a limit of 0 disables quotas
+func (c *Config) GetQuota() (int, time.Duration) {
+	return c.quotaLimit, c.quotaWindow
+}
+
+// GetQuotaOverrides returns per caller quota limits, parsed from caller=limit pairs
+func (c *Config) GetQuotaOverrides() map[string]int {
+	overrides := make(map[string]int)
+	for _, pair := range splitList(c.quotaOverrides) {
+		caller, limit, found := strings.Cut(pair, "=")
+		if !found {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(limit)); err == nil {
+			overrides[strings.TrimSpace(caller)] = n
+		}
+	}
+	return overrides
+}
+
+// GetCallerKeys returns the request header callers send their key in and the callers
+// named by the hex encoded SHA-256 hash of their key, parsed from caller=hash pairs
+func (c *Config) GetCallerKeys() (string, map[string]string) {
+	keys := make(map[string]string)
+	for _, pair := range splitList(c.callerKeys) {
+		caller, hash, found := strings.Cut(pair, "=")
+		if found {
+			keys[strings.ToLower(strings.TrimSpace(hash))] = strings.TrimSpace(caller)
+		}
+	}
+	return c.callerKeyHeader, keys
+}
+
+// GetRedirectPolicy returns the upstream redirect policy and the hosts allowed by the allowlist policy
+func (c *Config) GetRedirectPolicy() (string, []string) {
+	return strings.ToLower(c.redirectPolicy), splitList(c.redirectHosts)
+}
+
+// GetBlockedCIDRs returns the networks upstream connections are refused to
+func (c *Config) GetBlockedCIDRs() []string {
+	return splitList(c.blockedCIDRs)
+}
+
+// GetBlockPrivateIPs returns true if private, loopback and link-local upstream addresses are refused
+func (c *Config) GetBlockPrivateIPs() bool {
+	return c.blockPrivateIPs
+}
+
+// GetClientCIDRs returns the networks clients are allowed and denied from
+func (c *Config) GetClientCIDRs() ([]string, []string) {
+	return splitList(c.allowedClients), splitList(c.deniedClients)
+}
+
+// GetTrustedProxies returns the networks of proxies trusted to report the client address
+func (c *Config) GetTrustedProxies() []string {
+	return splitList(c.trustedProxies)
+}
+
+func (c *Config) GetProxyProtocol() bool {
+	return c.proxyProtocol
+}
+
+// GetRoutes returns the upstream routes, most specific prefix first. Without configured
+// routes, every request goes to the single upstream described by SERVER_URL and AUTH_TYPE,
+// or by OPENAPI_FILE, which otherwise remains the fallback for paths not matched by any route.
+func (c *Config) GetRoutes() ([]Route, error) {
+	routes, err := parseRoutes(c.routes)
+	if err != nil {
+		return nil, err
+	}
+	routes = append(routes, c.fileRoutes...)
+	if len(routes) == 0 || c.serverUrl != "" || c.openapi != "" {
+		routes = append(routes, c.defaultRoute())
+	}
+	SortRoutes(routes)
+	return routes, nil
+}
+
+// defaultRoute builds the catch-all route from the single upstream settings
+func (c *Config) defaultRoute() Route {
+	return Route{
+		Name:      DefaultRouteName,
+		Prefix:    "/",
+		ServerURL: c.serverUrl,
+		OpenAPI:   c.openapi,
+		Auth: Auth{
+			Type:             c.authType,
+			ApiKey:           c.apiKey,
+			ApiKeyHeaderName: c.apiKeyHeaderName,
+			ApiKeyIn:         c.apiKeyIn,
+			BearerToken:      c.bearerToken,
+			Username:         c.username,
+			Password:         c.password,
+			IKey:             c.ikey,
+			SKey:             c.sKey,
+			AccessToken:      c.accessToken,
+			RefreshToken:     c.refreshToken,
+			ExpiresAt:        c.expiresAt,
+			TokenURL:         c.tokenUrl,
+			RefreshURL:       c.refreshUrl,
+			ClientID:         c.clientId,
+			ClientSecret:     c.clientSecret,
+		},
+	}
+}
+
+// GetRequestValidation returns how requests are validated against the OpenAPI document of
+// routes that do not set their own mode: enforce, warn or off
+func (c *Config) GetRequestValidation() string {
+	return strings.ToLower(c.requestValidation)
+}
+
+// GetResponseValidation returns the percentage of upstream responses validated against the
+// OpenAPI document, 0 when responses are not validated, and whether the fields of validated
+// responses are tracked for the drift report
+func (c *Config) GetResponseValidation() (int, bool) {
+	return c.responseRate, c.driftReport
+}
+
+// GetMock returns whether every route answers from the examples of its OpenAPI document
+// instead of calling its upstream
+func (c *Config) GetMock() bool {
+	return c.mock
+}
+
+// GetRecording returns whether the structure of proxied calls is recorded to learn the API
+// of the upstreams, and the file the recording is kept in, empty to keep it in memory
+func (c *Config) GetRecording() (bool, string) {
+	return c.recordTraffic, c.recordingFile
+}
+
+// GetMCP returns whether the operations of the OpenAPI documents are served as Model Context
+// Protocol tools over HTTP
+func (c *Config) GetMCP() bool {
+	return c.mcp
+}
+
+// GetPort returns the port the connector listens on
+func (c *Config) GetPort() int {
+	return c.port
+}
+
+// GetReservedPrefix returns the path prefix of the connector's own endpoints, without a trailing slash
+func (c *Config) GetReservedPrefix() string {
+	return "/" + strings.Trim(c.reservedPrefix, "/")
+}
+
+// Reserves returns true if paths under the route prefix belong to the connector's own
+// endpoints and could never be proxied
+func (c *Config) Reserves(prefix string) bool {
+	_, ok := Route{Prefix: c.GetReservedPrefix()}.Match("/" + strings.Trim(prefix, "/"))
+	return ok
+}
+
+// Reload reads the configuration again from the same command line arguments, the
+// environment and the configuration file
+func (c *Config) Reload() (*Config, error) {
+	return Load(c.args)
+}
+
+// GetConfigWatchInterval returns how often the configuration file is checked for changes
+func (c *Config) GetConfigWatchInterval() time.Duration {
+	return c.configWatchInterval
+}
+
+// GetAdmin returns the port of the admin API, 0 when it is disabled, its token and the
+// file connectors managed through it are stored in
+func (c *Config) GetAdmin() (int, string, string) {
+	return c.adminPort, c.adminToken, c.adminStore
+}
+
+// IsSet returns true if the setting with the given env var name was supplied by a flag, an
+// env var or the configuration file rather than left to its default
+func (c *Config) IsSet(env string) bool {
+	return c.set[env]
+}
+
+// GetConfigFile returns the path of the configuration file, empty when none is used
+func (c *Config) GetConfigFile() string {
+	return c.file
+}
+
 func (c *Config) GetServerURL() string {
 	c.serverUrl = strings.TrimSuffix(c.serverUrl, "/")
-	u, _ := url.Parse(c.serverUrl)
+	u, err := url.Parse(c.serverUrl)
+	if err != nil {
+		// reported by Validate
+		return c.serverUrl
+	}
 	if u.Scheme == "" {
 		return "https://" + c.serverUrl
 	} else {
@@ -103,7 +425,10 @@ func (c *Config) GetServerURL() string {
 
 func (c *Config) GetServerHost() string {
 	c.serverUrl = strings.TrimSuffix(c.serverUrl, "/")
-	u, _ := url.Parse(c.serverUrl)
+	u, err := url.Parse(c.serverUrl)
+	if err != nil {
+		return ""
+	}
 	if u.Scheme == "" {
 		return u.Host
 	} else {
@@ -113,10 +438,24 @@ func (c *Config) GetServerHost() string {
 
 func (c *Config) GetServerPath() string {
 	c.serverUrl = strings.TrimSuffix(c.serverUrl, "/")
-	u, _ := url.Parse(c.serverUrl)
+	u, err := url.Parse(c.serverUrl)
+	if err != nil {
+		return ""
+	}
 	if u.Scheme == "" {
 		return u.Path
 	} else {
 		return u.Path
 	}
-}
\ No newline at end of file
+}
+
+// splitList splits a comma separated value, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
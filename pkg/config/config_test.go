@@ -0,0 +1,121 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "connector.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  port: 9000
+upstream:
+  serverUrl: https://${DOMAIN}.freshservice.com/api/v2
+  auth:
+    type: API_KEY
+    apiKey: ${FS_API_KEY:-fallback}
+redaction:
+  fields: [password, $.user.ssn]
+quota:
+  limit: 10
+  window: 1h
+  overrides:
+    team-a: 100
+routes:
+  - prefix: /duo/
+    serverUrl: https://api-1234.duosecurity.com
+    auth:
+      type: HMAC
+      ikey: ikey
+    timeout: 10s
+`)
+	t.Setenv("DOMAIN", "acme")
+	t.Setenv("FS_API_KEY", "")
+	t.Setenv("QUOTA_LIMIT", "20")
+	t.Setenv("SERVER_URL", "")
+
+	cfg, err := Load([]string{"--config", path, "-quotaWindow", "5m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.GetPort() != 9000 {
+		t.Errorf("expected port from the file, got %d", cfg.GetPort())
+	}
+	if cfg.GetServerURL() != "https://acme.freshservice.com/api/v2" {
+		t.Errorf("expected interpolated server url, got %s", cfg.GetServerURL())
+	}
+	if cfg.GetApiKey() != "fallback" {
+		t.Errorf("expected interpolation default, got %s", cfg.GetApiKey())
+	}
+	if limit, window := cfg.GetQuota(); limit != 20 || window != 5*time.Minute {
+		t.Errorf("expected env to override the file and flags to override both, got %d %v", limit, window)
+	}
+	if overrides := cfg.GetQuotaOverrides(); overrides["team-a"] != 100 {
+		t.Errorf("unexpected quota overrides %v", overrides)
+	}
+	if fields := cfg.GetRedactFields(); len(fields) != 2 || fields[1] != "$.user.ssn" {
+		t.Errorf("unexpected redact fields %v", fields)
+	}
+
+	routes, err := cfg.GetRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 || routes[0].Name != "duo" || routes[0].Timeout.Duration != 10*time.Second || routes[1].Name != DefaultRouteName {
+		t.Errorf("unexpected routes %+v", routes)
+	}
+}
+
+func TestLoadUnknownKey(t *testing.T) {
+	path := writeConfig(t, "upstream:\n  serverUrl: https://example.com\n  authType: API_KEY\n")
+
+	_, err := Load([]string{"-config=" + path})
+	if err == nil || !strings.Contains(err.Error(), "upstream.authType") {
+		t.Errorf("expected an unknown key error, got %v", err)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetPort() != 8010 || cfg.GetCallerIdHeader() != "X-Caller-Id" {
+		t.Errorf("unexpected defaults %d %s", cfg.GetPort(), cfg.GetCallerIdHeader())
+	}
+}
+
+func TestLoadInterpolatesValues(t *testing.T) {
+	path := writeConfig(t, `
+upstream:
+  serverUrl: https://example.com
+  auth:
+    type: API_KEY
+    apiKey: ${FS_API_KEY}
+`)
+	secret := "a#b: \"c'\n  injected: true"
+	t.Setenv("FS_API_KEY", secret)
+	t.Setenv("SERVER_URL", "")
+
+	cfg, err := Load([]string{"--config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetApiKey() != secret {
+		t.Errorf("expected the secret to be kept as is, got %q", cfg.GetApiKey())
+	}
+}
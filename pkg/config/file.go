@@ -0,0 +1,161 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig holds the values read from the configuration file
+type fileConfig struct {
+	values map[string]interface{}
+	Routes []Route
+}
+
+// configFilePath returns the --config flag from args, or CONFIG_FILE
+func configFilePath(args []string) string {
+	for i, arg := range args {
+		for _, name := range []string{"-config", "--config"} {
+			if arg == name && i+1 < len(args) {
+				return args[i+1]
+			}
+			if strings.HasPrefix(arg, name+"=") {
+				return strings.TrimPrefix(arg, name+"=")
+			}
+		}
+	}
+	return os.Getenv("CONFIG_FILE")
+}
+
+var interpolation = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolate replaces ${VAR} and ${VAR:-default} with env var values, $$ escapes a dollar sign
+func interpolate(content string) string {
+	return interpolation.ReplaceAllStringFunc(content, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+		groups := interpolation.FindStringSubmatch(match)
+		if value, ok := os.LookupEnv(groups[1]); ok && value != "" {
+			return value
+		}
+		return groups[2]
+	})
+}
+
+// interpolateValues interpolates every string of a parsed document. Values are replaced after
+// parsing so they are never read as YAML, a secret holding a quote, a # or a newline stays
+// a single string.
+func interpolateValues(v interface{}) interface{} {
+	switch value := v.(type) {
+	case string:
+		return interpolate(value)
+	case map[string]interface{}:
+		for k, child := range value {
+			value[k] = interpolateValues(child)
+		}
+	case []interface{}:
+		for i, child := range value {
+			value[i] = interpolateValues(child)
+		}
+	}
+	return v
+}
+
+// readFile reads a YAML or JSON configuration file, returning nil when path is empty
+func readFile(path string) (*fileConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read configuration file: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("unable to parse configuration file %s: %w", path, err)
+	}
+	interpolateValues(raw)
+
+	file := &fileConfig{values: make(map[string]interface{})}
+	if routes, ok := raw["routes"]; ok {
+		// routes share their JSON schema with the ROUTES env var
+		encoded, err := json.Marshal(routes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid routes in %s: %w", path, err)
+		}
+		if file.Routes, err = parseRoutes(string(encoded)); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		delete(raw, "routes")
+	}
+
+	known := make(map[string]bool)
+	for _, setting := range Settings() {
+		known[setting.Key] = true
+	}
+	flatten(raw, "", known, file.values)
+
+	var unknown []string
+	for key := range file.values {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown keys in configuration file %s: %s", path, strings.Join(unknown, ", "))
+	}
+	return file, nil
+}
+
+// flatten stores nested values under their dotted keys, stopping at known keys so map
+// valued settings such as quota.overrides are kept whole
+func flatten(m map[string]interface{}, prefix string, known map[string]bool, out map[string]interface{}) {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]interface{}); ok && !known[key] {
+			flatten(nested, key, known, out)
+			continue
+		}
+		out[key] = v
+	}
+}
+
+// get returns the file value for a dotted key as the string a flag would receive
+func (f *fileConfig) get(key string) (string, bool) {
+	if f == nil || key == "" {
+		return "", false
+	}
+	v, ok := f.values[key]
+	if !ok || v == nil {
+		return "", false
+	}
+	switch value := v.(type) {
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ","), true
+	case map[string]interface{}:
+		pairs := make([]string, 0, len(value))
+		for k, item := range value {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", k, item))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ","), true
+	default:
+		return fmt.Sprint(value), true
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/kosha/passthrough-connector/pkg/parser"
+)
+
+// Validation modes of requests checked against the OpenAPI document
+const (
+	ValidationEnforce = "enforce"
+	ValidationWarn    = "warn"
+	ValidationOff     = "off"
+)
+
+// ValidationModes are the accepted validation modes
+var ValidationModes = []string{ValidationEnforce, ValidationWarn, ValidationOff}
+
+// tokenFlows are the OAuth2 flows with a token endpoint, in order of preference
+var tokenFlows = []string{"clientCredentials", "authorizationCode", "password"}
+
+// LoadAPI reads the OpenAPI document of the route, nil when it has none
+func (r Route) LoadAPI() (*parser.Document, error) {
+	if r.OpenAPI == "" {
+		return nil, nil
+	}
+	return parser.Load(r.OpenAPI)
+}
+
+// WithAPI returns the route calling the first server of doc when it has no server url
+func (r Route) WithAPI(doc *parser.Document) Route {
+	if r.ServerURL != "" || len(doc.Servers) == 0 {
+		return r
+	}
+	// relative servers are relative to the document location, which is a local file
+	if u, err := url.Parse(doc.Servers[0]); err == nil && u.Host != "" {
+		r.ServerURL = doc.Servers[0]
+	}
+	return r
+}
+
+// GetRequestValidation returns the request validation mode of the route, fallback when the
+// route does not set one
+func (r Route) GetRequestValidation(fallback string) string {
+	if r.RequestValidation == "" {
+		return fallback
+	}
+	return strings.ToLower(r.RequestValidation)
+}
+
+// SecurityAuth returns the auth of the first security requirement the route has credentials
+// for. Credentials are looked up by scheme name in Credentials, then in Auth. No requirement,
+// or an empty one, means the upstream is called without auth.
+func (r Route) SecurityAuth(doc *parser.Document, requirements []parser.SecurityRequirement) (Auth, error) {
+	if len(requirements) == 0 {
+		return Auth{Type: "NONE"}, nil
+	}
+	var names []string
+	for _, requirement := range requirements {
+		switch len(requirement) {
+		case 0:
+			return Auth{Type: "NONE"}, nil
+		case 1:
+			for name := range requirement {
+				names = append(names, name)
+				scheme, ok := doc.SecuritySchemes[name]
+				if !ok {
+					continue
+				}
+				credentials, ok := r.Credentials[name]
+				if !ok {
+					credentials = r.Auth
+				}
+				if auth, ok := SchemeAuth(scheme, credentials); ok {
+					return auth, nil
+				}
+			}
+		default:
+			// requirements combining several schemes cannot be met with a single auth
+			var combined []string
+			for name := range requirement {
+				combined = append(combined, name)
+			}
+			sort.Strings(combined)
+			names = append(names, strings.Join(combined, "+"))
+		}
+	}
+	return Auth{}, fmt.Errorf("no credentials for security scheme %s", strings.Join(names, " or "))
+}
+
+// SchemeAuth returns the auth calling an upstream through scheme with the given credentials,
+// their type and placement being those of the scheme. It returns false when the scheme is
+// not supported or the credentials it requires are missing.
+func SchemeAuth(scheme *parser.SecurityScheme, credentials Auth) (Auth, bool) {
+	auth := credentials
+	switch scheme.Type {
+	case parser.SchemeAPIKey:
+		auth.Type, auth.ApiKeyHeaderName, auth.ApiKeyIn = "API_KEY", scheme.Name, scheme.In
+	case parser.SchemeHTTP:
+		switch scheme.Scheme {
+		case "basic":
+			auth.Type = "BASIC_AUTH"
+		case "bearer":
+			auth.Type = "BEARER_TOKEN"
+		default:
+			return Auth{}, false
+		}
+	case parser.SchemeOAuth2, parser.SchemeOpenIDConnect:
+		auth.Type = "OAUTH2"
+		for _, name := range tokenFlows {
+			if flow, ok := scheme.Flows[name]; ok && auth.TokenURL == "" {
+				auth.TokenURL = flow.TokenURL
+				if auth.RefreshURL == "" {
+					auth.RefreshURL = flow.RefreshURL
+				}
+			}
+		}
+	default:
+		return Auth{}, false
+	}
+	for _, env := range AuthCredentials[auth.Type].Required {
+		if authFields[env].value(auth) == "" {
+			return Auth{}, false
+		}
+	}
+	if auth.Type == "OAUTH2" && auth.AccessToken == "" && !auth.CanRefresh() {
+		return Auth{}, false
+	}
+	return auth, true
+}
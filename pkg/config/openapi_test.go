@@ -0,0 +1,131 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kosha/passthrough-connector/pkg/parser"
+)
+
+const ticketsAPI = `openapi: 3.0.3
+info: {title: Tickets, version: "2"}
+servers: [{url: "https://acme.example.com/api/v2"}]
+components:
+  securitySchemes:
+    key: {type: apiKey, in: query, name: api_key}
+    token: {type: http, scheme: bearer}
+    oauth:
+      type: oauth2
+      flows:
+        clientCredentials: {tokenUrl: "https://auth.example.com/token", refreshUrl: "https://auth.example.com/refresh", scopes: {}}
+security: [{key: []}]
+paths:
+  /tickets:
+    post:
+      security: [{oauth: []}, {token: []}]
+      responses: {"201": {description: created}}
+  /health:
+    get:
+      security: [{}]
+      responses: {"200": {description: ok}}
+`
+
+func writeAPI(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "tickets.yaml")
+	if err := os.WriteFile(path, []byte(ticketsAPI), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSecurityAuth(t *testing.T) {
+	route := Route{
+		OpenAPI:     writeAPI(t),
+		Auth:        Auth{ApiKey: "key"},
+		Credentials: map[string]Auth{"token": {BearerToken: "token"}},
+	}
+	api, err := route.LoadAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route = route.WithAPI(api); route.ServerURL != "https://acme.example.com/api/v2" {
+		t.Errorf("expected the server of the document, got %q", route.ServerURL)
+	}
+
+	auth, err := route.SecurityAuth(api, api.Security)
+	if err != nil || auth.Type != "API_KEY" || auth.ApiKeyIn != parser.InQuery || auth.ApiKeyHeaderName != "api_key" || auth.ApiKey != "key" {
+		t.Errorf("unexpected document auth %+v %v", auth, err)
+	}
+
+	// without an access token, the oauth requirement is skipped for the bearer one
+	create, _ := api.Match("POST", "/tickets")
+	if auth, err = route.SecurityAuth(api, api.SecurityOf(create)); err != nil || auth.Type != "BEARER_TOKEN" || auth.BearerToken != "token" {
+		t.Errorf("unexpected operation auth %+v %v", auth, err)
+	}
+	route.Credentials["oauth"] = Auth{AccessToken: "access"}
+	if auth, _ = route.SecurityAuth(api, api.SecurityOf(create)); auth.Type != "OAUTH2" || auth.TokenURL != "https://auth.example.com/token" || auth.GetRefreshURL() != "https://auth.example.com/refresh" {
+		t.Errorf("unexpected operation auth %+v", auth)
+	}
+	// client credentials request their access token from the token endpoint
+	route.Credentials["oauth"] = Auth{ClientID: "connector", ClientSecret: "secret"}
+	if auth, _ = route.SecurityAuth(api, api.SecurityOf(create)); auth.Type != "OAUTH2" || !auth.CanRefresh() {
+		t.Errorf("expected client credentials to satisfy the oauth requirement, got %+v", auth)
+	}
+
+	health, _ := api.Match("GET", "/health")
+	if auth, _ = route.SecurityAuth(api, api.SecurityOf(health)); auth.Type != "NONE" {
+		t.Errorf("expected no auth, got %+v", auth)
+	}
+
+	route.Auth.ApiKey = ""
+	if _, err = route.SecurityAuth(api, api.Security); err == nil || !strings.Contains(err.Error(), "key") {
+		t.Errorf("expected missing credentials to be reported, got %v", err)
+	}
+}
+
+func TestValidateOpenAPI(t *testing.T) {
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("AUTH_TYPE", "")
+	t.Setenv("OPENAPI_FILE", writeAPI(t))
+
+	err := Get().Validate()
+	if err == nil || !strings.Contains(err.Error(), "OPENAPI_FILE: no credentials for security scheme key") {
+		t.Errorf("expected the api key to be required, got %v", err)
+	}
+
+	t.Setenv("API_KEY", "key")
+	if err := Get().Validate(); err != nil {
+		t.Errorf("expected a valid configuration, got %v", err)
+	}
+
+	t.Setenv("OPENAPI_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	if err := Get().Validate(); err == nil || !strings.Contains(err.Error(), "OPENAPI_FILE is invalid") {
+		t.Errorf("expected a missing document to be reported, got %v", err)
+	}
+}
+
+func TestValidateMock(t *testing.T) {
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("AUTH_TYPE", "")
+	t.Setenv("API_KEY", "")
+	t.Setenv("MOCK", "true")
+
+	// mocked routes need neither a server nor credentials, only a document
+	err := Get().Validate()
+	if err == nil || !strings.Contains(err.Error(), "OPENAPI_FILE is required to mock the route") {
+		t.Errorf("expected the document to be required, got %v", err)
+	}
+	t.Setenv("OPENAPI_FILE", writeAPI(t))
+	if err := Get().Validate(); err != nil {
+		t.Errorf("expected a valid configuration, got %v", err)
+	}
+
+	t.Setenv("MOCK", "false")
+	route := Route{Name: "tickets", Prefix: "/tickets", Mock: true, RequestValidation: "strict"}
+	err = ValidateRoute(route)
+	if err == nil || !strings.Contains(err.Error(), "routes[tickets].openapi is required") || !strings.Contains(err.Error(), "routes[tickets].requestValidation must be one of") {
+		t.Errorf("expected the route problems, got %v", err)
+	}
+}
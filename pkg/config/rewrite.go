@@ -0,0 +1,170 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RewriteRule changes the path sent upstream. StripPrefix removes a leading path prefix,
+// AddPrefix prepends one and Match replaces every match of a regular expression with
+// Replace, in which $1 or ${name} expand to capture groups. Set fields apply in that order.
+type RewriteRule struct {
+	StripPrefix string `json:"stripPrefix,omitempty"`
+	AddPrefix   string `json:"addPrefix,omitempty"`
+	Match       string `json:"match,omitempty"`
+	Replace     string `json:"replace,omitempty"`
+
+	re *regexp.Regexp
+}
+
+func (rule *RewriteRule) UnmarshalJSON(b []byte) error {
+	type plain RewriteRule
+	if err := json.Unmarshal(b, (*plain)(rule)); err != nil {
+		return err
+	}
+	_, err := rule.regexp()
+	return err
+}
+
+// regexp returns the compiled Match expression, nil when the rule has none
+func (rule *RewriteRule) regexp() (*regexp.Regexp, error) {
+	if rule.re != nil || rule.Match == "" {
+		return rule.re, nil
+	}
+	re, err := regexp.Compile(rule.Match)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rewrite match %q: %w", rule.Match, err)
+	}
+	rule.re = re
+	return re, nil
+}
+
+func (rule RewriteRule) apply(path string) string {
+	if prefix := strings.TrimSuffix(rule.StripPrefix, "/"); prefix != "" {
+		if path == prefix {
+			path = "/"
+		} else if strings.HasPrefix(path, prefix+"/") {
+			path = strings.TrimPrefix(path, prefix)
+		}
+	}
+	if prefix := strings.Trim(rule.AddPrefix, "/"); prefix != "" {
+		path = "/" + prefix + path
+	}
+	if re, err := rule.regexp(); re != nil && err == nil {
+		path = re.ReplaceAllString(path, rule.Replace)
+	}
+	return path
+}
+
+// RewritePath applies the rewrite rules of the route to the path of requestUri, keeping its query
+func (r Route) RewritePath(requestUri string) string {
+	if len(r.Rewrite) == 0 {
+		return requestUri
+	}
+	path, query, hasQuery := strings.Cut(requestUri, "?")
+	for _, rule := range r.Rewrite {
+		path = rule.apply(path)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if hasQuery {
+		return path + "?" + query
+	}
+	return path
+}
+
+// Variable fills a {name} placeholder of a templated ServerURL such as
+// https://{domain}.freshservice.com/api/v2, from the Header request header when it is
+// present and from Default otherwise
+type Variable struct {
+	Default string `json:"default,omitempty"`
+	Header  string `json:"header,omitempty"`
+}
+
+// ErrHostNotAllowed is returned when an upstream URL resolves to a host outside the
+// route's allowed hosts
+var ErrHostNotAllowed = errors.New("upstream host is not allowed")
+
+var (
+	placeholder = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	// variable values may only form host labels or path segments, they can never add a
+	// port, credentials, path separators or a query
+	variableValue = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+// IsTemplate returns true when the ServerURL has placeholders
+func (r Route) IsTemplate() bool {
+	return placeholder.MatchString(r.ServerURL)
+}
+
+// ResolveServerURL returns the upstream base URL with its placeholders filled in, header
+// returns the value of a request header. The resulting host must be one of AllowedHosts
+// when they are set.
+func (r Route) ResolveServerURL(header func(name string) string) (string, error) {
+	var errs []string
+	expanded := placeholder.ReplaceAllStringFunc(r.ServerURL, func(match string) string {
+		name := match[1 : len(match)-1]
+		variable, ok := r.Variables[name]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("no variable for placeholder %s", match))
+			return match
+		}
+		value := ""
+		if variable.Header != "" {
+			value = header(variable.Header)
+		}
+		if value == "" {
+			value = variable.Default
+		}
+		if value == "" {
+			errs = append(errs, fmt.Sprintf("missing value for %s, set the %s header", match, variable.Header))
+		} else if !variableValue.MatchString(value) {
+			errs = append(errs, fmt.Sprintf("invalid value %q for %s", value, match))
+		}
+		return value
+	})
+	if len(errs) > 0 {
+		return "", errors.New(strings.Join(errs, ", "))
+	}
+
+	serverUrl := Route{ServerURL: expanded}.GetServerURL()
+	if len(r.AllowedHosts) > 0 {
+		u, err := url.Parse(serverUrl)
+		if err != nil {
+			return "", err
+		}
+		if !r.HostAllowed(u.Hostname()) {
+			return "", fmt.Errorf("%w: %s", ErrHostNotAllowed, u.Hostname())
+		}
+	}
+	return serverUrl, nil
+}
+
+// HostAllowed returns true when host is one of AllowedHosts, *.example.com allows every
+// subdomain of example.com
+func (r Route) HostAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range r.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if allowed == host || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleServerURL fills every placeholder with its default, or a sample host label, so
+// templated URLs can be validated
+func (r Route) sampleServerURL() string {
+	return Route{ServerURL: placeholder.ReplaceAllStringFunc(r.ServerURL, func(match string) string {
+		if variable := r.Variables[match[1:len(match)-1]]; variable.Default != "" {
+			return variable.Default
+		}
+		return "sample"
+	})}.GetServerURL()
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRewritePath(t *testing.T) {
+	var route Route
+	err := json.Unmarshal([]byte(`{"rewrite": [
+		{"stripPrefix": "/v1"},
+		{"addPrefix": "/api/v2/"},
+		{"match": "^/api/v2/users/([^/]+)/tickets$", "replace": "/api/v2/tickets/filter/requester/$1"}
+	]}`), &route)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"/v1/agents?page=2":  "/api/v2/agents?page=2",
+		"/v1":                "/api/v2/",
+		"/v10/agents":        "/api/v2/v10/agents",
+		"/users/42/tickets":  "/api/v2/tickets/filter/requester/42",
+		"/users/42/requests": "/api/v2/users/42/requests",
+	}
+	for uri, expected := range tests {
+		if got := route.RewritePath(uri); got != expected {
+			t.Errorf("RewritePath(%q) = %q, expected %q", uri, got, expected)
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`{"rewrite": [{"match": "("}]}`), &route); err == nil {
+		t.Error("expected an invalid expression to be rejected")
+	}
+}
+
+func TestResolveServerURL(t *testing.T) {
+	route := Route{
+		ServerURL:    "https://{domain}.freshservice.com/api/{version}",
+		Variables:    map[string]Variable{"domain": {Header: "X-Domain"}, "version": {Default: "v2"}},
+		AllowedHosts: []string{"*.freshservice.com"},
+	}
+
+	tests := []struct {
+		domain   string
+		expected string
+		fails    bool
+	}{
+		{"acme", "https://acme.freshservice.com/api/v2", false},
+		{"", "", true},
+		{"evil.com/x", "", true},
+		{"evil.com#", "", true},
+		{"user@evil", "", true},
+	}
+	for _, tt := range tests {
+		got, err := route.ResolveServerURL(func(name string) string {
+			if name == "X-Domain" {
+				return tt.domain
+			}
+			return ""
+		})
+		if (err != nil) != tt.fails || got != tt.expected {
+			t.Errorf("domain %q: got %q, %v", tt.domain, got, err)
+		}
+	}
+
+	route.ServerURL = "https://{domain}/api/v2"
+	if _, err := route.ResolveServerURL(func(string) string { return "evil.com" }); !errors.Is(err, ErrHostNotAllowed) {
+		t.Errorf("expected ErrHostNotAllowed, got %v", err)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("ROUTES", `[{"name": "fs", "prefix": "/fs", "serverUrl": "https://{domain}.freshservice.com/api/v2/{missing}",
+		"variables": {"domain": {"header": "X-Domain"}}}]`)
+
+	err := Get().Validate()
+	for _, problem := range []string{
+		"routes[fs].serverUrl has no variable for placeholder {missing}",
+		"routes[fs].variables.domain is filled from the X-Domain header and requires allowedHosts",
+	} {
+		if err == nil || !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q in %v", problem, err)
+		}
+	}
+}
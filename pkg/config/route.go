@@ -0,0 +1,183 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultRouteName is the name of the route built from the single upstream env vars
+const DefaultRouteName = "default"
+
+// Auth holds the auth type and credentials used to call an upstream
+type Auth struct {
+	Type             string `json:"type"`
+	ApiKey           string `json:"apiKey,omitempty"`
+	ApiKeyHeaderName string `json:"apiKeyHeaderName,omitempty"`
+	ApiKeyIn         string `json:"apiKeyIn,omitempty"`
+	BearerToken      string `json:"bearerToken,omitempty"`
+	Username         string `json:"username,omitempty"`
+	Password         string `json:"password,omitempty"`
+	IKey             string `json:"ikey,omitempty"`
+	SKey             string `json:"skey,omitempty"`
+	AccessToken      string `json:"accessToken,omitempty"`
+	RefreshToken     string `json:"refreshToken,omitempty"`
+	ExpiresAt        string `json:"expiresAt,omitempty"`
+	TokenURL         string `json:"tokenUrl,omitempty"`
+	// RefreshURL is where refresh tokens are exchanged, TokenURL when empty
+	RefreshURL   string `json:"refreshUrl,omitempty"`
+	ClientID     string `json:"clientId,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+}
+
+// GetRefreshURL returns the endpoint refresh tokens are exchanged at
+func (a Auth) GetRefreshURL() string {
+	if a.RefreshURL != "" {
+		return a.RefreshURL
+	}
+	return a.TokenURL
+}
+
+// CanRefresh returns true if new OAuth2 access tokens can be requested, with the refresh
+// token or with the client credentials
+func (a Auth) CanRefresh() bool {
+	return a.RefreshToken != "" && a.GetRefreshURL() != "" || a.ClientID != "" && a.TokenURL != ""
+}
+
+// GetType returns the normalized auth type, see Config.GetAuthType
+func (a Auth) GetType() string {
+	return normalizeAuthType(a.Type)
+}
+
+// ParamPolicy describes headers or query parameters injected into every request sent
+// upstream. Remove is applied first, then Default sets values the request does not
+// already have and Set overrides whatever the request sent.
+type ParamPolicy struct {
+	Default map[string]string `json:"default,omitempty"`
+	Set     map[string]string `json:"set,omitempty"`
+	Remove  []string          `json:"remove,omitempty"`
+}
+
+// Route sends requests whose path starts with Prefix to an upstream. The prefix is
+// stripped from the path, which is then rewritten and appended to the upstream ServerURL.
+type Route struct {
+	Name         string              `json:"name"`
+	Prefix       string              `json:"prefix"`
+	ServerURL    string              `json:"serverUrl"`
+	Auth         Auth                `json:"auth"`
+	Timeout      Duration            `json:"timeout,omitempty"`
+	Headers      ParamPolicy         `json:"headers,omitempty"`
+	Query        ParamPolicy         `json:"query,omitempty"`
+	Rewrite      []RewriteRule       `json:"rewrite,omitempty"`
+	Variables    map[string]Variable `json:"variables,omitempty"`
+	AllowedHosts []string            `json:"allowedHosts,omitempty"`
+	// OpenAPI is the OpenAPI 3 or Swagger 2.0 document, or the Postman v2.1 collection, of
+	// the upstream. When Auth has no type, the auth of every request is derived from the
+	// security of its operation.
+	OpenAPI string `json:"openapi,omitempty"`
+	// Credentials holds the credentials of the document's security schemes, by scheme
+	// name, for schemes that do not use those of Auth
+	Credentials map[string]Auth `json:"credentials,omitempty"`
+	// RequestValidation overrides REQUEST_VALIDATION for the route
+	RequestValidation string `json:"requestValidation,omitempty"`
+	// Mock answers requests from the examples of the OpenAPI document instead of calling
+	// the upstream, see MOCK
+	Mock bool `json:"mock,omitempty"`
+}
+
+// GetServerURL returns the upstream base URL, defaulting to https when no scheme is given
+func (r Route) GetServerURL() string {
+	serverUrl := strings.TrimSuffix(r.ServerURL, "/")
+	u, err := url.Parse(serverUrl)
+	if err == nil && u.Scheme == "" {
+		return "https://" + serverUrl
+	}
+	return serverUrl
+}
+
+// GetServerHost returns the host of the upstream base URL
+func (r Route) GetServerHost() string {
+	u, err := url.Parse(r.GetServerURL())
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// Match returns the request path relative to the route prefix and whether the path
+// belongs to the route. Prefixes only match whole path segments.
+func (r Route) Match(path string) (string, bool) {
+	prefix := strings.TrimSuffix(r.Prefix, "/")
+	if prefix == "" {
+		return path, true
+	}
+	if path == prefix {
+		return "/", true
+	}
+	if strings.HasPrefix(path, prefix+"/") {
+		return strings.TrimPrefix(path, prefix), true
+	}
+	return "", false
+}
+
+// Duration is a time.Duration read from strings such as "30s" or from a number of seconds
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return d.set(v)
+}
+
+func (d *Duration) set(v interface{}) error {
+	switch value := v.(type) {
+	case float64:
+		d.Duration = time.Duration(value * float64(time.Second))
+	case int:
+		d.Duration = time.Duration(value) * time.Second
+	case string:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		d.Duration = duration
+	default:
+		return fmt.Errorf("invalid duration %v", v)
+	}
+	return nil
+}
+
+// parseRoutes decodes a JSON array of routes
+func parseRoutes(raw string) ([]Route, error) {
+	var routes []Route
+	if strings.TrimSpace(raw) == "" {
+		return routes, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &routes); err != nil {
+		return nil, fmt.Errorf("invalid routes: %w", err)
+	}
+	for i := range routes {
+		if routes[i].Name == "" {
+			routes[i].Name = strings.Trim(routes[i].Prefix, "/")
+		}
+	}
+	return routes, nil
+}
+
+// SortRoutes orders routes from the longest to the shortest prefix so the most specific route matches first
+func SortRoutes(routes []Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(strings.TrimSuffix(routes[i].Prefix, "/")) > len(strings.TrimSuffix(routes[j].Prefix, "/"))
+	})
+}
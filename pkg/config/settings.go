@@ -0,0 +1,170 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Setting describes a single configuration value and every way of supplying it
+type Setting struct {
+	// Flag is the command line flag name
+	Flag string
+	// Env is the environment variable name
+	Env string
+	// Key is the dotted path of the value in the configuration file
+	Key string
+	// Usage is the description shown in the flag usage and documentation
+	Usage string
+	// Default is the value used when the setting is not supplied
+	Default string
+}
+
+// secretSettings hold credentials, their values are write-only and never reported
+var secretSettings = map[string]bool{
+	"API_KEY":       true,
+	"BEARER_TOKEN":  true,
+	"PASSWORD":      true,
+	"SKEY":          true,
+	"ACCESS_TOKEN":  true,
+	"REFRESH_TOKEN": true,
+	"CLIENT_SECRET": true,
+	"ROUTES":        true,
+	"ADMIN_TOKEN":   true,
+	"JWT_SECRET":    true,
+	"CALLER_KEYS":   true,
+}
+
+// Secret returns true if the setting holds credentials
+func (s Setting) Secret() bool {
+	return secretSettings[s.Env]
+}
+
+// binding ties a setting to the Config field it populates
+type binding struct {
+	Setting
+	target interface{}
+}
+
+// bindings lists every scalar setting of the connector
+func (c *Config) bindings() []binding {
+	return []binding{
+		{Setting{"port", "PORT", "server.port", "Port the connector listens on", "8010"}, &c.port},
+		{Setting{"reservedPrefix", "RESERVED_PREFIX", "server.reservedPrefix", "Path prefix of the connector's own endpoints, which are never proxied", "/_connector"}, &c.reservedPrefix},
+		{Setting{"configWatchInterval", "CONFIG_WATCH_INTERVAL", "server.configWatchInterval", "How often the configuration file is checked for changes, 0 disables the check", "5s"}, &c.configWatchInterval},
+
+		{Setting{"serverUrl", "SERVER_URL", "upstream.serverUrl", "Server Url", ""}, &c.serverUrl},
+		{Setting{"authType", "AUTH_TYPE", "upstream.auth.type", "Auth Type", ""}, &c.authType},
+		{Setting{"apiKey", "API_KEY", "upstream.auth.apiKey", "API Key", ""}, &c.apiKey},
+		{Setting{"apiKeyHeaderName", "API_KEY_HEADER_NAME", "upstream.auth.apiKeyHeaderName", "API Key Header Name", ""}, &c.apiKeyHeaderName},
+		{Setting{"apiKeyIn", "API_KEY_IN", "upstream.auth.apiKeyIn", "Where the API key is sent: header, query or cookie", ""}, &c.apiKeyIn},
+		{Setting{"bearerToken", "BEARER_TOKEN", "upstream.auth.bearerToken", "Bearer Token", ""}, &c.bearerToken},
+		{Setting{"username", "USERNAME", "upstream.auth.username", "Basic Auth username", ""}, &c.username},
+		{Setting{"password", "PASSWORD", "upstream.auth.password", "Basic Auth password", ""}, &c.password},
+		{Setting{"ikey", "IKEY", "upstream.auth.ikey", "Duo Security IKey", ""}, &c.ikey},
+		{Setting{"skey", "SKEY", "upstream.auth.skey", "Duo Security SKey", ""}, &c.sKey},
+		{Setting{"accessToken", "ACCESS_TOKEN", "upstream.auth.accessToken", "Oauth2 Access Token", ""}, &c.accessToken},
+		{Setting{"refreshToken", "REFRESH_TOKEN", "upstream.auth.refreshToken", "Oauth2 Refresh Token", ""}, &c.refreshToken},
+		{Setting{"expiresAt", "EXPIRES_AT", "upstream.auth.expiresAt", "Oauth2 Expires At", ""}, &c.expiresAt},
+		{Setting{"tokenUrl", "TOKEN_URL", "upstream.auth.tokenUrl", "Oauth2 token endpoint used to refresh the access token", ""}, &c.tokenUrl},
+		{Setting{"refreshUrl", "REFRESH_URL", "upstream.auth.refreshUrl", "Oauth2 endpoint refresh tokens are exchanged at, TOKEN_URL when empty", ""}, &c.refreshUrl},
+		{Setting{"clientId", "CLIENT_ID", "upstream.auth.clientId", "Oauth2 client id, sent to the token endpoint and used for the client credentials grant", ""}, &c.clientId},
+		{Setting{"clientSecret", "CLIENT_SECRET", "upstream.auth.clientSecret", "Oauth2 client secret, sent to the token endpoint with basic auth", ""}, &c.clientSecret},
+		{Setting{"requestValidation", "REQUEST_VALIDATION", "validation.requests", "How requests are validated against the OpenAPI document: enforce, warn or off", "warn"}, &c.requestValidation},
+		{Setting{"responseValidationRate", "RESPONSE_VALIDATION_RATE", "validation.responseRate", "Percentage of upstream responses validated against the OpenAPI document, 0 disables it and 100 validates every response", "0"}, &c.responseRate},
+		{Setting{"driftReport", "DRIFT_REPORT", "validation.driftReport", "Track fields of validated responses that differ from the OpenAPI document and report them", "false"}, &c.driftReport},
+		{Setting{"mock", "MOCK", "upstream.mock", "Answer requests from the examples of the OpenAPI documents instead of calling the upstreams", "false"}, &c.mock},
+		{Setting{"recordTraffic", "RECORD_TRAFFIC", "recorder.enabled", "Record the structure of proxied calls to infer an OpenAPI document of the upstreams", "false"}, &c.recordTraffic},
+		{Setting{"recordingFile", "RECORDING_FILE", "recorder.file", "File the recorded traffic is saved to and merged with across restarts, empty keeps it in memory", ""}, &c.recordingFile},
+		{Setting{"mcp", "MCP", "mcp.enabled", "Serve the operations of the OpenAPI documents as Model Context Protocol tools under the reserved prefix", "false"}, &c.mcp},
+		{Setting{"openapi", "OPENAPI_FILE", "upstream.openapi", "OpenAPI 3 or Swagger 2.0 document, or Postman v2.1 collection, of the upstream, its security schemes configure the auth", ""}, &c.openapi},
+		{Setting{"routes", "ROUTES", "", "JSON array of routes mapping path prefixes to upstreams", ""}, &c.routes},
+
+		{Setting{"adminPort", "ADMIN_PORT", "admin.port", "Port of the admin API, 0 disables it", "0"}, &c.adminPort},
+		{Setting{"adminToken", "ADMIN_TOKEN", "admin.token", "Bearer token required by the admin API", ""}, &c.adminToken},
+		{Setting{"adminStore", "ADMIN_STORE", "admin.store", "File connectors managed through the admin API are stored in", "connectors.json"}, &c.adminStore},
+
+		{Setting{"tenantSource", "TENANT_SOURCE", "tenants.source", "Where the tenant id is read from: header, path or claim, empty disables tenants", ""}, &c.tenantSource},
+		{Setting{"tenantKey", "TENANT_KEY", "tenants.key", "Header or JWT claim holding the tenant id, X-Tenant-Id or tenant by default", ""}, &c.tenantKey},
+		{Setting{"tenantsFile", "TENANTS_FILE", "tenants.file", "YAML or JSON file mapping tenant ids to their upstream", ""}, &c.tenantsFile},
+
+		{Setting{"redactFields", "REDACT_FIELDS", "redaction.fields", "Comma separated JSON paths to redact in logs", ""}, &c.redactFields},
+		{Setting{"redactHeaders", "REDACT_HEADERS", "redaction.headers", "Comma separated header names to redact in logs", ""}, &c.redactHeaders},
+		{Setting{"redactResponses", "REDACT_RESPONSES", "redaction.responses", "Also redact fields in responses returned to callers", "false"}, &c.redactResponses},
+
+		{Setting{"callerIdHeader", "CALLER_ID_HEADER", "callers.idHeader", "Header identifying the caller, only trusted from TRUSTED_PROXIES", "X-Caller-Id"}, &c.callerIdHeader},
+		{Setting{"callerKeyHeader", "CALLER_KEY_HEADER", "callers.keyHeader", "Header callers send the key CALLER_KEYS identifies them by in", "X-Caller-Key"}, &c.callerKeyHeader},
+		{Setting{"callerKeys", "CALLER_KEYS", "callers.keys", "Comma separated caller=sha256 pairs naming callers by the hex encoded SHA-256 hash of their key", ""}, &c.callerKeys},
+		{Setting{"jwtSecret", "JWT_SECRET", "callers.jwtSecret", "HMAC secret bearer JWTs must be signed with for their subject and tenant claim to be trusted", ""}, &c.jwtSecret},
+		{Setting{"auditLog", "AUDIT_LOG", "audit.log", "Audit log destination, a file path or stdout", ""}, &c.auditLog},
+		{Setting{"auditMaxSizeMB", "AUDIT_MAX_SIZE_MB", "audit.maxSizeMB", "Audit log size in megabytes before it is rotated", "100"}, &c.auditMaxSizeMB},
+		{Setting{"auditMaxBackups", "AUDIT_MAX_BACKUPS", "audit.maxBackups", "Number of rotated audit logs to keep", "5"}, &c.auditMaxBackups},
+		{Setting{"auditHashBodies", "AUDIT_HASH_BODIES", "audit.hashBodies", "Include a SHA-256 hash of request bodies of mutating calls in the audit log", "false"}, &c.auditHashBodies},
+
+		{Setting{"quotaLimit", "QUOTA_LIMIT", "quota.limit", "Requests allowed per caller per quota window, 0 disables quotas", "0"}, &c.quotaLimit},
+		{Setting{"quotaWindow", "QUOTA_WINDOW", "quota.window", "Quota sliding window", "1m"}, &c.quotaWindow},
+		{Setting{"quotaOverrides", "QUOTA_OVERRIDES", "quota.overrides", "Comma separated caller=limit quota overrides", ""}, &c.quotaOverrides},
+
+		{Setting{"upstreamRedirects", "UPSTREAM_REDIRECTS", "egress.redirects", "Upstream redirect policy: all, none, same-host or allowlist", "all"}, &c.redirectPolicy},
+		{Setting{"upstreamRedirectHosts", "UPSTREAM_REDIRECT_HOSTS", "egress.redirectHosts", "Comma separated hosts upstream redirects may go to with the allowlist policy", ""}, &c.redirectHosts},
+		{Setting{"upstreamBlockPrivate", "UPSTREAM_BLOCK_PRIVATE", "egress.blockPrivate", "Refuse to connect to private, loopback and link-local upstream addresses", "false"}, &c.blockPrivateIPs},
+		{Setting{"upstreamBlockedCidrs", "UPSTREAM_BLOCKED_CIDRS", "egress.blockedCidrs", "Comma separated CIDRs upstream connections are refused to", ""}, &c.blockedCIDRs},
+
+		{Setting{"allowedCidrs", "ALLOWED_CIDRS", "clients.allowedCidrs", "Comma separated CIDRs clients must connect from", ""}, &c.allowedClients},
+		{Setting{"deniedCidrs", "DENIED_CIDRS", "clients.deniedCidrs", "Comma separated CIDRs clients are rejected from", ""}, &c.deniedClients},
+		{Setting{"trustedProxies", "TRUSTED_PROXIES", "clients.trustedProxies", "Comma separated CIDRs of proxies trusted to report the client address", ""}, &c.trustedProxies},
+		{Setting{"proxyProtocol", "PROXY_PROTOCOL", "clients.proxyProtocol", "Read the client address from PROXY protocol headers sent by trusted proxies", "false"}, &c.proxyProtocol},
+	}
+}
+
+// Settings returns the description of every scalar setting
+func Settings() []Setting {
+	var settings []Setting
+	for _, b := range (&Config{}).bindings() {
+		settings = append(settings, b.Setting)
+	}
+	return settings
+}
+
+// value returns the env var if set, then the file value, then the default
+func (b binding) value(file *fileConfig) string {
+	if value, ok := os.LookupEnv(b.Env); ok && value != "" {
+		return value
+	}
+	if value, ok := file.get(b.Key); ok {
+		return value
+	}
+	return b.Default
+}
+
+// register adds the flag for the binding, with the env or file value as its default
+func (b binding) register(flags *flag.FlagSet, file *fileConfig) error {
+	value := b.value(file)
+	switch target := b.target.(type) {
+	case *string:
+		flags.StringVar(target, b.Flag, value, b.Usage)
+	case *bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false, got %q", b.Env, value)
+		}
+		flags.BoolVar(target, b.Flag, v, b.Usage)
+	case *int:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a number, got %q", b.Env, value)
+		}
+		flags.IntVar(target, b.Flag, v, b.Usage)
+	case *time.Duration:
+		v, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a duration such as 30s or 5m, got %q", b.Env, value)
+		}
+		flags.DurationVar(target, b.Flag, v, b.Usage)
+	default:
+		return fmt.Errorf("unsupported setting type %T for %s", b.target, b.Flag)
+	}
+	return nil
+}
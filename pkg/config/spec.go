@@ -0,0 +1,106 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+// settingEnums lists the accepted values of settings restricted to a fixed set
+var settingEnums = map[string][]string{
+	"AUTH_TYPE":          SupportedAuthTypes,
+	"UPSTREAM_REDIRECTS": {"all", "none", "same-host", "allowlist"},
+	"TENANT_SOURCE":      {"", TenantFromHeader, TenantFromPath, TenantFromClaim},
+	"API_KEY_IN":         {"", "header", "query", "cookie"},
+	"REQUEST_VALIDATION": ValidationModes,
+}
+
+// Specification describes every setting of the connector as a JSON Schema object keyed by
+// env var, so forms can be rendered from it. Besides the standard keywords, each property
+// carries its flag (x-flag), configuration file key (x-file-key), whether it holds a
+// credential (x-secret) and whether it is currently set (x-set). Values are never included.
+// The credentials each auth type requires are expressed as if/then conditions on AUTH_TYPE
+// and listed under x-auth-types.
+func (c *Config) Specification() map[string]interface{} {
+	properties := make(map[string]interface{})
+	var order []string
+	for _, b := range c.bindings() {
+		properties[b.Env] = b.schema(c.IsSet(b.Env))
+		order = append(order, b.Env)
+	}
+
+	var conditions []interface{}
+	for _, authType := range SupportedAuthTypes {
+		required := AuthCredentials[authType].Required
+		if len(required) == 0 {
+			continue
+		}
+		conditions = append(conditions, map[string]interface{}{
+			"if": map[string]interface{}{
+				"properties": map[string]interface{}{"AUTH_TYPE": map[string]interface{}{"const": authType}},
+				"required":   []string{"AUTH_TYPE"},
+			},
+			"then": map[string]interface{}{"required": required},
+		})
+	}
+
+	return map[string]interface{}{
+		"$schema":      "http://json-schema.org/draft-07/schema#",
+		"title":        "Passthrough connector configuration",
+		"type":         "object",
+		"properties":   properties,
+		"allOf":        conditions,
+		"x-order":      order,
+		"x-auth-types": AuthCredentials,
+	}
+}
+
+// schema returns the JSON Schema of the setting
+func (b binding) schema(set bool) map[string]interface{} {
+	s := map[string]interface{}{
+		"title":      b.Usage,
+		"x-flag":     b.Flag,
+		"x-file-key": b.Key,
+		"x-secret":   b.Secret(),
+		"x-set":      set,
+	}
+	if b.Secret() {
+		s["writeOnly"] = true
+	}
+
+	switch b.target.(type) {
+	case *bool:
+		s["type"] = "boolean"
+		if v, err := strconv.ParseBool(b.Default); err == nil {
+			s["default"] = v
+		}
+	case *int:
+		s["type"] = "integer"
+		if v, err := strconv.Atoi(b.Default); err == nil {
+			s["default"] = v
+		}
+	case *time.Duration:
+		s["type"] = "string"
+		s["pattern"] = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+		if b.Default != "" {
+			s["default"] = b.Default
+		}
+	default:
+		s["type"] = "string"
+		if b.Default != "" {
+			s["default"] = b.Default
+		}
+	}
+
+	if values, ok := settingEnums[b.Env]; ok {
+		s["enum"] = values
+	}
+	switch b.Env {
+	case "PORT", "ADMIN_PORT":
+		s["minimum"], s["maximum"] = 0, 65535
+	case "RESPONSE_VALIDATION_RATE":
+		s["minimum"], s["maximum"] = 0, 100
+	case "SERVER_URL":
+		s["format"] = "uri"
+	}
+	return s
+}
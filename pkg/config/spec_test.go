@@ -0,0 +1,45 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSpecification(t *testing.T) {
+	t.Setenv("SERVER_URL", "acme.freshservice.com")
+	t.Setenv("AUTH_TYPE", "API_KEY")
+	t.Setenv("API_KEY", "very-secret-key")
+	t.Setenv("API_KEY_HEADER_NAME", "")
+
+	spec := Get().Specification()
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(encoded), "very-secret-key") || strings.Contains(string(encoded), "acme.freshservice.com") {
+		t.Fatalf("the specification must not include setting values: %s", encoded)
+	}
+
+	properties := spec["properties"].(map[string]interface{})
+	apiKey := properties["API_KEY"].(map[string]interface{})
+	if apiKey["type"] != "string" || apiKey["writeOnly"] != true || apiKey["x-secret"] != true || apiKey["x-set"] != true {
+		t.Errorf("unexpected API_KEY schema %v", apiKey)
+	}
+	if header := properties["API_KEY_HEADER_NAME"].(map[string]interface{}); header["x-set"] != false || header["x-secret"] != false {
+		t.Errorf("unexpected API_KEY_HEADER_NAME schema %v", header)
+	}
+	if port := properties["PORT"].(map[string]interface{}); port["type"] != "integer" || port["default"] != 8010 {
+		t.Errorf("unexpected PORT schema %v", port)
+	}
+	if redact := properties["REDACT_RESPONSES"].(map[string]interface{}); redact["type"] != "boolean" || redact["default"] != false {
+		t.Errorf("unexpected REDACT_RESPONSES schema %v", redact)
+	}
+	if authType := properties["AUTH_TYPE"].(map[string]interface{}); len(authType["enum"].([]string)) != len(SupportedAuthTypes) {
+		t.Errorf("expected AUTH_TYPE to list every auth type, got %v", authType)
+	}
+
+	if !strings.Contains(string(encoded), `{"if":{"properties":{"AUTH_TYPE":{"const":"HMAC"}},"required":["AUTH_TYPE"]},"then":{"required":["IKEY","SKEY"]}}`) {
+		t.Errorf("expected HMAC to require IKEY and SKEY: %s", encoded)
+	}
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Tenant sources, the part of a request the tenant id is read from
+const (
+	TenantFromHeader = "header"
+	TenantFromPath   = "path"
+	TenantFromClaim  = "claim"
+)
+
+// Tenant holds the upstream of one customer. It replaces the base URL and credentials of
+// the routes it lists, which keep their prefix, headers and timeout.
+type Tenant struct {
+	ServerURL string `json:"serverUrl"`
+	Auth      Auth   `json:"auth"`
+	// Credentials holds the credentials of the security schemes of the routes' OpenAPI
+	// documents, by scheme name, as for routes
+	Credentials map[string]Auth `json:"credentials"`
+	// Routes are the names of the routes the tenant's upstream applies to
+	Routes []string `json:"routes"`
+}
+
+// Serves returns true if the tenant's upstream applies to the route
+func (t Tenant) Serves(route string) bool {
+	for _, name := range t.Routes {
+		if name == route {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns route with the upstream of the tenant. None of the route's credentials are
+// kept, they would be sent to the tenant's server.
+func (t Tenant) Apply(route Route) Route {
+	route.ServerURL = t.ServerURL
+	route.Auth = t.Auth
+	route.Credentials = t.Credentials
+	return route
+}
+
+// GetTenantResolver returns where the tenant id is read from, empty when requests are not
+// tenant aware, and the header or JWT claim name holding it
+func (c *Config) GetTenantResolver() (string, string) {
+	source, key := strings.ToLower(c.tenantSource), c.tenantKey
+	if key == "" {
+		switch source {
+		case TenantFromHeader:
+			key = "X-Tenant-Id"
+		case TenantFromClaim:
+			key = "tenant"
+		}
+	}
+	return source, key
+}
+
+// GetJWTSecret returns the HMAC secret bearer JWTs must be signed with for their claims to be trusted
+func (c *Config) GetJWTSecret() string {
+	return c.jwtSecret
+}
+
+// GetTenants reads the tenants file, a YAML or JSON object mapping tenant ids to their
+// upstream. Env vars are interpolated as in the configuration file.
+func (c *Config) GetTenants() (map[string]Tenant, error) {
+	if c.tenantsFile == "" {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(c.tenantsFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read tenants file: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("unable to parse tenants file %s: %w", c.tenantsFile, err)
+	}
+	interpolateValues(raw)
+	// tenants share their JSON schema with routes
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenants in %s: %w", c.tenantsFile, err)
+	}
+	tenants := make(map[string]Tenant)
+	if err := json.Unmarshal(encoded, &tenants); err != nil {
+		return nil, fmt.Errorf("invalid tenants in %s: %w", c.tenantsFile, err)
+	}
+	return tenants, nil
+}
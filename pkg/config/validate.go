@@ -0,0 +1,439 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kosha/passthrough-connector/pkg/parser"
+)
+
+// SupportedAuthTypes are the auth types the connector can use to call an upstream
+var SupportedAuthTypes = []string{"NONE", "API_KEY", "BEARER_TOKEN", "BASIC_AUTH", "HMAC", "OAUTH2"}
+
+// Credentials lists the settings, by env var, an auth type requires and accepts
+type Credentials struct {
+	Required []string `json:"required"`
+	Optional []string `json:"optional"`
+}
+
+// AuthCredentials holds the credentials of every supported auth type
+var AuthCredentials = map[string]Credentials{
+	"NONE":         {},
+	"API_KEY":      {Required: []string{"API_KEY"}, Optional: []string{"API_KEY_HEADER_NAME", "API_KEY_IN"}},
+	"BEARER_TOKEN": {Required: []string{"BEARER_TOKEN"}},
+	"BASIC_AUTH":   {Required: []string{"USERNAME"}, Optional: []string{"PASSWORD"}},
+	"HMAC":         {Required: []string{"IKEY", "SKEY"}},
+	"OAUTH2":       {Optional: []string{"ACCESS_TOKEN", "REFRESH_TOKEN", "EXPIRES_AT", "TOKEN_URL", "REFRESH_URL", "CLIENT_ID", "CLIENT_SECRET"}},
+}
+
+// authFields maps credential env vars to the Auth field of a route holding them
+var authFields = map[string]struct {
+	field string
+	value func(Auth) string
+}{
+	"API_KEY":             {"apiKey", func(a Auth) string { return a.ApiKey }},
+	"API_KEY_HEADER_NAME": {"apiKeyHeaderName", func(a Auth) string { return a.ApiKeyHeaderName }},
+	"API_KEY_IN":          {"apiKeyIn", func(a Auth) string { return a.ApiKeyIn }},
+	"BEARER_TOKEN":        {"bearerToken", func(a Auth) string { return a.BearerToken }},
+	"USERNAME":            {"username", func(a Auth) string { return a.Username }},
+	"PASSWORD":            {"password", func(a Auth) string { return a.Password }},
+	"IKEY":                {"ikey", func(a Auth) string { return a.IKey }},
+	"SKEY":                {"skey", func(a Auth) string { return a.SKey }},
+	"ACCESS_TOKEN":        {"accessToken", func(a Auth) string { return a.AccessToken }},
+	"REFRESH_TOKEN":       {"refreshToken", func(a Auth) string { return a.RefreshToken }},
+	"EXPIRES_AT":          {"expiresAt", func(a Auth) string { return a.ExpiresAt }},
+	"TOKEN_URL":           {"tokenUrl", func(a Auth) string { return a.TokenURL }},
+	"REFRESH_URL":         {"refreshUrl", func(a Auth) string { return a.RefreshURL }},
+	"CLIENT_ID":           {"clientId", func(a Auth) string { return a.ClientID }},
+	"CLIENT_SECRET":       {"clientSecret", func(a Auth) string { return a.ClientSecret }},
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d configuration problem(s):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+// Validate checks the configuration and returns a *ValidationError listing every problem found
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	if c.port < 1 || c.port > 65535 {
+		v.addf("PORT must be between 1 and 65535, got %d", c.port)
+	}
+
+	routes, err := c.GetRoutes()
+	if err != nil {
+		v.addf("ROUTES is invalid: %v", err)
+	}
+	if c.serverUrl == "" && c.authType != "" && len(routes) > 0 && routes[len(routes)-1].Name != DefaultRouteName {
+		v.addf("AUTH_TYPE is set but SERVER_URL is not, set SERVER_URL to add a catch-all upstream or configure auth on the routes")
+	}
+	if !validationMode(c.GetRequestValidation()) {
+		v.addf("REQUEST_VALIDATION must be one of %s, got %q", strings.Join(ValidationModes, ", "), c.requestValidation)
+	}
+	if c.responseRate < 0 || c.responseRate > 100 {
+		v.addf("RESPONSE_VALIDATION_RATE must be between 0 and 100, got %d", c.responseRate)
+	}
+	if c.driftReport && c.responseRate == 0 {
+		v.addf("DRIFT_REPORT requires RESPONSE_VALIDATION_RATE, only validated responses are tracked")
+	}
+	if c.recordingFile != "" {
+		if !c.recordTraffic {
+			v.addf("RECORDING_FILE requires RECORD_TRAFFIC")
+		} else {
+			v.validateWritable("RECORDING_FILE", c.recordingFile)
+		}
+	}
+	if c.GetReservedPrefix() == "/" {
+		v.addf("RESERVED_PREFIX must not be /, the connector endpoints would hide every upstream path")
+	}
+	prefixes := make(map[string]string)
+	names := make(map[string]bool)
+	for _, route := range routes {
+		if c.mock {
+			route.Mock = true
+		}
+		v.validateRoute(route)
+		if route.Name != DefaultRouteName && c.GetReservedPrefix() != "/" && c.Reserves(route.Prefix) {
+			v.addf("%s %q collides with RESERVED_PREFIX %q, requests to it would never be proxied", field(route, "", "prefix"), route.Prefix, c.GetReservedPrefix())
+		}
+
+		prefix := strings.TrimSuffix(route.Prefix, "/")
+		if other, ok := prefixes[prefix]; ok {
+			v.addf("routes %q and %q have the same prefix %q", other, route.Name, route.Prefix)
+		}
+		prefixes[prefix] = route.Name
+		if names[route.Name] {
+			v.addf("route name %q is used more than once", route.Name)
+		}
+		names[route.Name] = true
+	}
+
+	policy, hosts := c.GetRedirectPolicy()
+	switch policy {
+	case "all", "none", "same-host":
+		if len(hosts) > 0 {
+			v.addf("UPSTREAM_REDIRECT_HOSTS is only used with UPSTREAM_REDIRECTS=allowlist, got %q", policy)
+		}
+	case "allowlist":
+		if len(hosts) == 0 {
+			v.addf("UPSTREAM_REDIRECTS=allowlist requires UPSTREAM_REDIRECT_HOSTS")
+		}
+	default:
+		v.addf("UPSTREAM_REDIRECTS must be one of all, none, same-host or allowlist, got %q", policy)
+	}
+
+	allowed, denied := c.GetClientCIDRs()
+	v.validateCIDRs("UPSTREAM_BLOCKED_CIDRS", c.GetBlockedCIDRs())
+	v.validateCIDRs("ALLOWED_CIDRS", allowed)
+	v.validateCIDRs("DENIED_CIDRS", denied)
+	v.validateCIDRs("TRUSTED_PROXIES", c.GetTrustedProxies())
+	if c.proxyProtocol && len(c.GetTrustedProxies()) == 0 {
+		v.addf("PROXY_PROTOCOL requires TRUSTED_PROXIES, the proxies allowed to send PROXY headers")
+	}
+
+	if c.auditLog == "" && c.auditHashBodies {
+		v.addf("AUDIT_HASH_BODIES requires AUDIT_LOG")
+	}
+	if c.auditLog != "" && c.auditLog != "stdout" {
+		v.validateWritable("AUDIT_LOG", c.auditLog)
+	}
+	if c.auditMaxSizeMB < 0 || c.auditMaxBackups < 0 {
+		v.addf("AUDIT_MAX_SIZE_MB and AUDIT_MAX_BACKUPS must not be negative")
+	}
+
+	for _, pair := range splitList(c.callerKeys) {
+		caller, hash, found := strings.Cut(pair, "=")
+		if decoded, err := hex.DecodeString(strings.TrimSpace(hash)); !found || err != nil || len(decoded) != sha256.Size || strings.TrimSpace(caller) == "" {
+			v.addf("CALLER_KEYS entry %q must be caller=sha256, the hex encoded SHA-256 hash of the caller's key", pair)
+		}
+	}
+
+	if c.quotaLimit < 0 {
+		v.addf("QUOTA_LIMIT must not be negative, got %d", c.quotaLimit)
+	}
+	if c.quotaWindow <= 0 {
+		v.addf("QUOTA_WINDOW must be positive, got %v", c.quotaWindow)
+	}
+	for _, pair := range splitList(c.quotaOverrides) {
+		caller, limit, found := strings.Cut(pair, "=")
+		if _, err := strconv.Atoi(strings.TrimSpace(limit)); !found || err != nil || strings.TrimSpace(caller) == "" {
+			v.addf("QUOTA_OVERRIDES entry %q must be caller=limit", pair)
+		}
+	}
+
+	switch source, _ := c.GetTenantResolver(); source {
+	case "":
+		if c.tenantsFile != "" {
+			v.addf("TENANTS_FILE requires TENANT_SOURCE")
+		}
+	case TenantFromHeader, TenantFromPath, TenantFromClaim:
+		if c.tenantsFile == "" {
+			v.addf("TENANT_SOURCE requires TENANTS_FILE")
+			break
+		}
+		if source == TenantFromClaim && c.jwtSecret == "" {
+			v.addf("TENANT_SOURCE claim requires JWT_SECRET, unverified claims could name any tenant")
+		}
+		tenants, err := c.GetTenants()
+		if err != nil {
+			v.addf("TENANTS_FILE is invalid: %v", err)
+		}
+		ids := make([]string, 0, len(tenants))
+		for id := range tenants {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			// tenants are validated as routes and reported with their own path
+			if len(tenants[id].Routes) == 0 {
+				v.addf("tenants[%s].routes is required, name the routes the tenant's upstream applies to", id)
+			}
+			// against the documents of the routes it serves, whose auth it may derive from them
+			documents := map[string]bool{}
+			for _, route := range routes {
+				if tenants[id].Serves(route.Name) && route.OpenAPI != "" {
+					documents[route.OpenAPI] = true
+				}
+			}
+			if len(documents) == 0 {
+				documents[""] = true
+			}
+			seen := map[string]bool{}
+			var problems []string
+			for document := range documents {
+				sub := &validator{}
+				sub.validateRoute(tenants[id].Apply(Route{Name: id, Prefix: "/", OpenAPI: document}))
+				for _, problem := range sub.problems {
+					problem = strings.Replace(problem, "routes["+id+"]", "tenants["+id+"]", 1)
+					if !seen[problem] {
+						seen[problem] = true
+						problems = append(problems, problem)
+					}
+				}
+			}
+			sort.Strings(problems)
+			for _, problem := range problems {
+				v.addf("%s", problem)
+			}
+		}
+	default:
+		v.addf("TENANT_SOURCE must be one of header, path or claim, got %q", source)
+	}
+
+	if c.adminPort != 0 {
+		if c.adminPort < 0 || c.adminPort > 65535 {
+			v.addf("ADMIN_PORT must be between 1 and 65535, got %d", c.adminPort)
+		} else if c.adminPort == c.port {
+			v.addf("ADMIN_PORT must differ from PORT, the admin API has its own listener")
+		}
+		if c.adminToken == "" {
+			v.addf("ADMIN_PORT requires ADMIN_TOKEN")
+		}
+		if c.adminStore == "" {
+			v.addf("ADMIN_PORT requires ADMIN_STORE")
+		} else {
+			v.validateWritable("ADMIN_STORE", c.adminStore)
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// ValidateRoute checks a single route and returns a *ValidationError listing every problem found
+func ValidateRoute(route Route) error {
+	v := &validator{}
+	v.validateRoute(route)
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// field returns how a route field is supplied, env vars for the default route and
+// routes[name].path for the others
+func field(route Route, env, path string) string {
+	if route.Name == DefaultRouteName {
+		return env
+	}
+	return fmt.Sprintf("routes[%s].%s", route.Name, path)
+}
+
+func (v *validator) validateRoute(route Route) {
+	if route.Name != DefaultRouteName && !strings.HasPrefix(route.Prefix, "/") {
+		v.addf("%s must start with /, got %q", field(route, "", "prefix"), route.Prefix)
+	}
+
+	var api *parser.Document
+	if route.OpenAPI != "" {
+		var err error
+		if api, err = route.LoadAPI(); err != nil {
+			v.addf("%s is invalid: %v", field(route, "OPENAPI_FILE", "openapi"), err)
+		} else {
+			route = route.WithAPI(api)
+		}
+	}
+
+	if route.Mock {
+		// mocked routes never call their upstream, so they need no server or credentials
+		if route.OpenAPI == "" {
+			v.addf("%s is required to mock the route", field(route, "OPENAPI_FILE", "openapi"))
+		}
+		v.validateRouteOptions(route)
+		return
+	}
+
+	serverUrl := field(route, "SERVER_URL", "serverUrl")
+	sample := route.GetServerURL()
+	if route.IsTemplate() {
+		sample = route.sampleServerURL()
+		v.validateTemplate(route)
+	}
+	if route.ServerURL == "" {
+		v.addf("%s is required", serverUrl)
+	} else if u, err := url.Parse(sample); err != nil {
+		v.addf("%s is not a valid url: %v", serverUrl, err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		v.addf("%s must use http or https, got %q", serverUrl, u.Scheme)
+	} else if u.Host == "" {
+		v.addf("%s has no host: %q", serverUrl, route.ServerURL)
+	}
+
+	v.validateRouteOptions(route)
+
+	auth := route.Auth
+	authType := auth.GetType()
+	if authType == "" && api != nil {
+		// the auth is derived from the document, the default security must be satisfiable
+		if _, err := route.SecurityAuth(api, api.Security); err != nil {
+			v.addf("%s: %v", field(route, "OPENAPI_FILE", "openapi"), err)
+		}
+		return
+	}
+	if authType == "" {
+		authType = "NONE"
+	}
+	credentials, ok := AuthCredentials[authType]
+	if !ok {
+		v.addf("%s %q is not supported, use one of %s", field(route, "AUTH_TYPE", "auth.type"), auth.Type, strings.Join(SupportedAuthTypes, ", "))
+		return
+	}
+	for _, env := range credentials.Required {
+		if credential := authFields[env]; credential.value(auth) == "" {
+			v.addf("%s is required with auth type %s", field(route, env, "auth."+credential.field), authType)
+		}
+	}
+	if authType == "API_KEY" && !apiKeyLocation(auth.ApiKeyIn) {
+		v.addf("%s must be one of header, query or cookie, got %q", field(route, "API_KEY_IN", "auth.apiKeyIn"), auth.ApiKeyIn)
+	}
+	if authType == "OAUTH2" && auth.AccessToken == "" && !auth.CanRefresh() {
+		v.addf("%s, a %s or %s is required with auth type OAUTH2", field(route, "ACCESS_TOKEN", "auth.accessToken"),
+			field(route, "REFRESH_TOKEN", "auth.refreshToken"), field(route, "CLIENT_ID", "auth.clientId"))
+	}
+}
+
+// validateRouteOptions checks the settings of a route that do not depend on its upstream
+func (v *validator) validateRouteOptions(route Route) {
+	if route.RequestValidation != "" && !validationMode(route.GetRequestValidation("")) {
+		v.addf("%s must be one of %s, got %q", field(route, "", "requestValidation"), strings.Join(ValidationModes, ", "), route.RequestValidation)
+	}
+	if route.Timeout.Duration < 0 {
+		v.addf("%s must not be negative", field(route, "", "timeout"))
+	}
+	for i := range route.Rewrite {
+		if _, err := route.Rewrite[i].regexp(); err != nil {
+			v.addf("%s: %v", field(route, "", fmt.Sprintf("rewrite[%d].match", i)), err)
+		}
+	}
+}
+
+// apiKeyLocation reports whether an API key can be sent in, the header when empty
+func apiKeyLocation(in string) bool {
+	switch strings.ToLower(in) {
+	case "", "header", "query", "cookie":
+		return true
+	}
+	return false
+}
+
+func validationMode(mode string) bool {
+	for _, m := range ValidationModes {
+		if mode == m {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTemplate checks that every placeholder of a templated server url has a variable
+// and that hosts filled from request headers are restricted to an allowlist
+func (v *validator) validateTemplate(route Route) {
+	for _, match := range placeholder.FindAllStringSubmatch(route.ServerURL, -1) {
+		variable, ok := route.Variables[match[1]]
+		if !ok {
+			v.addf("%s has no variable for placeholder %s", field(route, "SERVER_URL", "serverUrl"), match[0])
+			continue
+		}
+		if variable.Header == "" && variable.Default == "" {
+			v.addf("%s needs a default or a header", field(route, "", "variables."+match[1]))
+		}
+		if variable.Header != "" && len(route.AllowedHosts) == 0 {
+			v.addf("%s is filled from the %s header and requires allowedHosts", field(route, "", "variables."+match[1]), variable.Header)
+		}
+	}
+}
+
+func (v *validator) validateCIDRs(name string, cidrs []string) {
+	for _, cidr := range cidrs {
+		if strings.Contains(cidr, "/") {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				v.addf("%s entry %q is not a valid CIDR", name, cidr)
+			}
+		} else if net.ParseIP(cidr) == nil {
+			v.addf("%s entry %q is not a valid IP address or CIDR", name, cidr)
+		}
+	}
+}
+
+// validateWritable checks that the file can be created or appended to
+func (v *validator) validateWritable(name, path string) {
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			v.addf("%s %s is a directory", name, path)
+			return
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			v.addf("%s %s is not writable: %v", name, path, err)
+			return
+		}
+		f.Close()
+		return
+	}
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		v.addf("%s directory %s does not exist", name, filepath.Dir(path))
+	}
+}
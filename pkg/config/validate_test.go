@@ -0,0 +1,118 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("AUTH_TYPE", "API-KY")
+	t.Setenv("ROUTES", `[{"name": "duo", "prefix": "/duo", "serverUrl": "ftp://duo", "auth": {"type": "HMAC", "ikey": "x"}},
+		{"name": "crm", "prefix": "/crm", "serverUrl": "https://crm", "auth": {"type": "API_KEY", "apiKey": "x", "apiKeyIn": "body"}}]`)
+	t.Setenv("UPSTREAM_REDIRECT_HOSTS", "example.com")
+	t.Setenv("PROXY_PROTOCOL", "true")
+	t.Setenv("ALLOWED_CIDRS", "10.0.0.0/33")
+	t.Setenv("CALLER_KEYS", "billing=billing-key")
+
+	err := Get().Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+
+	expected := []string{
+		"AUTH_TYPE is set but SERVER_URL is not",
+		"routes[duo].serverUrl must use http or https",
+		"routes[duo].auth.skey is required with auth type HMAC",
+		`routes[crm].auth.apiKeyIn must be one of header, query or cookie, got "body"`,
+		"UPSTREAM_REDIRECT_HOSTS is only used with UPSTREAM_REDIRECTS=allowlist",
+		"PROXY_PROTOCOL requires TRUSTED_PROXIES",
+		`ALLOWED_CIDRS entry "10.0.0.0/33" is not a valid CIDR`,
+		`CALLER_KEYS entry "billing=billing-key" must be caller=sha256`,
+	}
+	if len(validationErr.Problems) != len(expected) {
+		t.Errorf("expected %d problems, got %v", len(expected), validationErr.Problems)
+	}
+	for _, problem := range expected {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q in %v", problem, err)
+		}
+	}
+}
+
+func TestValidateMissingUpstream(t *testing.T) {
+	t.Setenv("SERVER_URL", "")
+	t.Setenv("ROUTES", "")
+	t.Setenv("AUTH_TYPE", "API-KY")
+
+	err := Get().Validate()
+	for _, problem := range []string{"SERVER_URL is required", `AUTH_TYPE "API-KY" is not supported`} {
+		if err == nil || !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q in %v", problem, err)
+		}
+	}
+}
+
+func TestValidateSingleUpstream(t *testing.T) {
+	t.Setenv("SERVER_URL", "acme.freshservice.com")
+	t.Setenv("AUTH_TYPE", "basic auth")
+	t.Setenv("USERNAME", "key")
+
+	if err := Get().Validate(); err != nil {
+		t.Errorf("expected a valid configuration, got %v", err)
+	}
+}
+
+func TestValidateTenants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.yaml")
+	content := "acme:\n  serverUrl: https://acme.freshservice.com/api/v2\n  auth:\n    type: API_KEY\n" +
+		"globex:\n  serverUrl: https://globex.freshservice.com/api/v2\n  routes: [default]\n  auth:\n    type: API_KEY\n    apiKey: ${GLOBEX_KEY}\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER_URL", "acme.freshservice.com")
+	t.Setenv("AUTH_TYPE", "")
+	t.Setenv("TENANT_SOURCE", "header")
+	t.Setenv("TENANTS_FILE", path)
+	t.Setenv("GLOBEX_KEY", "globex-key")
+
+	c := Get()
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "tenants[acme].auth.apiKey is required") {
+		t.Errorf("expected the acme api key to be required, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "tenants[acme].routes is required") || strings.Contains(err.Error(), "tenants[globex].routes") {
+		t.Errorf("expected the routes of acme to be required, got %v", err)
+	}
+	tenants, _ := c.GetTenants()
+	if tenants["globex"].Auth.ApiKey != "globex-key" {
+		t.Errorf("expected env vars to be interpolated in the tenants file, got %+v", tenants["globex"])
+	}
+	if source, key := c.GetTenantResolver(); source != TenantFromHeader || key != "X-Tenant-Id" {
+		t.Errorf("expected the X-Tenant-Id header by default, got %s %s", source, key)
+	}
+
+	t.Setenv("TENANT_SOURCE", "claim")
+	if err := Get().Validate(); err == nil || !strings.Contains(err.Error(), "requires JWT_SECRET") {
+		t.Errorf("expected claims to require a JWT secret, got %v", err)
+	}
+}
+
+func TestValidateReservedPrefix(t *testing.T) {
+	t.Setenv("SERVER_URL", "acme.freshservice.com")
+	t.Setenv("AUTH_TYPE", "NONE")
+	t.Setenv("RESERVED_PREFIX", "/_connector/")
+	t.Setenv("ROUTES", `[{"name": "internal", "prefix": "/_connector/internal", "serverUrl": "example.com"},
+		{"name": "lookalike", "prefix": "/_connectors", "serverUrl": "example.com"}]`)
+
+	err := Get().Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Problems) != 1 ||
+		!strings.Contains(err.Error(), `routes[internal].prefix "/_connector/internal" collides with RESERVED_PREFIX "/_connector"`) {
+		t.Errorf("expected only the internal route to collide, got %v", err)
+	}
+}
@@ -2,16 +2,40 @@ package httpclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/kosha/passthrough-connector/pkg/logger"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"time"
 )
 
+// do sends the request, leaving the query out of the URL of transport errors since it may
+// hold credentials, and the errors are logged and returned to callers
+func do(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return nil, &url.Error{Op: urlErr.Op, URL: withoutQuery(urlErr.URL), Err: urlErr.Err}
+	}
+	return resp, err
+}
+
+// withoutQuery returns rawUrl without its query, fragment and user info
+func withoutQuery(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "[invalid URL]"
+	}
+	u.RawQuery, u.Fragment, u.User = "", "", nil
+	return u.String()
+}
+
 func basicAuth(username, password string) string {
 	auth := username + ":" + password
 	return base64.StdEncoding.EncodeToString([]byte(auth))
@@ -20,9 +44,9 @@ func basicAuth(username, password string) string {
 func makeHttpNoAuthReq(req *http.Request, log logger.Logger) ([]byte, int, error) {
 	req.Header.Set("Accept-Encoding", "identity")
 
-	client := &http.Client{}
+	client := newClient(0)
 
-	resp, err := client.Do(req)
+	resp, err := do(client, req)
 
 	if err != nil {
 		log.Error(err)
@@ -41,9 +65,9 @@ func makeHttpBasicAuthReq(username, password string, req *http.Request, log logg
 
 	req.Header.Set("Accept-Encoding", "identity")
 
-	client := &http.Client{}
+	client := newClient(0)
 
-	resp, err := client.Do(req)
+	resp, err := do(client, req)
 
 	if err != nil {
 		log.Error(err)
@@ -67,9 +91,9 @@ func makeHttpApiKeyReq(apiKeyHeaderName, apiKey string, req *http.Request, log l
 
 	req.Header.Set("Accept-Encoding", "identity")
 
-	client := &http.Client{}
+	client := newClient(0)
 
-	resp, err := client.Do(req)
+	resp, err := do(client, req)
 
 	if err != nil {
 		log.Error(err)
@@ -88,9 +112,9 @@ func makeHttpBearerTokenReq(bearerToken string, req *http.Request, log logger.Lo
 
 	req.Header.Set("Accept-Encoding", "identity")
 
-	client := &http.Client{}
+	client := newClient(0)
 
-	resp, err := client.Do(req)
+	resp, err := do(client, req)
 
 	if err != nil {
 		log.Error(err)
@@ -105,8 +129,8 @@ func makeHttpBearerTokenReq(bearerToken string, req *http.Request, log logger.Lo
 }
 
 func makeSignedHttpDuoCall(req *http.Request, log logger.Logger) ([]byte, int, error) {
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	client := newClient(0)
+	resp, err := do(client, req)
 	if err != nil {
 		log.Error(err)
 		return nil, 500, err
@@ -130,10 +154,8 @@ func setOauth2Header(newReq *http.Request, tokenMap map[string]string) {
 	return
 }
 
-func Oauth2ApiRequest(headers map[string]string, method, url string, data interface{}, tokenMap map[string]string, log logger.Logger) ([]byte, int, error) {
-	var client = &http.Client{
-		Timeout: time.Second * 10,
-	}
+func Oauth2ApiRequest(ctx context.Context, headers map[string]string, method, url string, data interface{}, tokenMap map[string]string, log logger.Logger) ([]byte, int, error) {
+	var client = newClient(time.Second * 10)
 	var body io.Reader
 	if data == nil {
 		body = nil
@@ -147,7 +169,7 @@ func Oauth2ApiRequest(headers map[string]string, method, url string, data interf
 		body = bytes.NewBuffer(requestBody)
 	}
 
-	request, err := http.NewRequest(method, url, body)
+	request, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		log.Error(err)
 		return nil, 500, err
@@ -156,7 +178,7 @@ func Oauth2ApiRequest(headers map[string]string, method, url string, data interf
 		request.Header.Add(k, v)
 	}
 	setOauth2Header(request, tokenMap)
-	response, err := client.Do(request)
+	response, err := do(client, request)
 
 	if err != nil {
 		log.Error(err)
@@ -171,10 +193,10 @@ func Oauth2ApiRequest(headers map[string]string, method, url string, data interf
 	return respBody, response.StatusCode, err
 }
 
-func MakeOAuth2ApiRequest(headers map[string]string, url, method string, data interface{}, tokenMap map[string]string, log logger.Logger) (interface{}, int, error) {
+func MakeOAuth2ApiRequest(ctx context.Context, headers map[string]string, url, method string, data interface{}, tokenMap map[string]string, log logger.Logger) (interface{}, int, error) {
 	var response interface{}
 
-	res, statusCode, err := Oauth2ApiRequest(headers, method, url, data, tokenMap, log)
+	res, statusCode, err := Oauth2ApiRequest(ctx, headers, method, url, data, tokenMap, log)
 	if err != nil {
 		return nil, statusCode, err
 	}
@@ -192,13 +214,13 @@ func MakeOAuth2ApiRequest(headers map[string]string, url, method string, data in
 
 }
 
-func MakeHttpNoAuthCall(headers map[string]string, method, url string, body interface{}, log logger.Logger) (interface{}, int, error) {
+func MakeHttpNoAuthCall(ctx context.Context, headers map[string]string, method, url string, body interface{}, log logger.Logger) (interface{}, int, error) {
 	var req *http.Request
 	if body != nil {
 		jsonReq, _ := json.Marshal(body)
-		req, _ = http.NewRequest(method, url, bytes.NewBuffer(jsonReq))
+		req, _ = http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(jsonReq))
 	} else {
-		req, _ = http.NewRequest(method, url, nil)
+		req, _ = http.NewRequestWithContext(ctx, method, url, nil)
 	}
 	for k, v := range headers {
 		// remove user-agent header because discord doesn't like it?
@@ -226,14 +248,14 @@ func MakeHttpNoAuthCall(headers map[string]string, method, url string, body inte
 
 }
 
-func MakeHttpApiKeyCall(headers map[string]string, apiKeyHeaderName, apiKey, method, url string, body interface{}, log logger.Logger) (interface{}, int, error) {
+func MakeHttpApiKeyCall(ctx context.Context, headers map[string]string, apiKeyHeaderName, apiKey, method, url string, body interface{}, log logger.Logger) (interface{}, int, error) {
 
 	var req *http.Request
 	if body != nil {
 		jsonReq, _ := json.Marshal(body)
-		req, _ = http.NewRequest(method, url, bytes.NewBuffer(jsonReq))
+		req, _ = http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(jsonReq))
 	} else {
-		req, _ = http.NewRequest(method, url, nil)
+		req, _ = http.NewRequestWithContext(ctx, method, url, nil)
 	}
 	for k, v := range headers {
 		// remove user-agent header because discord doesn't like it?
@@ -261,14 +283,14 @@ func MakeHttpApiKeyCall(headers map[string]string, apiKeyHeaderName, apiKey, met
 	return response, statusCode, nil
 }
 
-func MakeHttpBearerTokenCall(headers map[string]string, bearerToken, method, url string, body interface{}, log logger.Logger) (interface{}, int, error) {
+func MakeHttpBearerTokenCall(ctx context.Context, headers map[string]string, bearerToken, method, url string, body interface{}, log logger.Logger) (interface{}, int, error) {
 
 	var req *http.Request
 	if body != nil {
 		jsonReq, _ := json.Marshal(body)
-		req, _ = http.NewRequest(method, url, bytes.NewBuffer(jsonReq))
+		req, _ = http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(jsonReq))
 	} else {
-		req, _ = http.NewRequest(method, url, nil)
+		req, _ = http.NewRequestWithContext(ctx, method, url, nil)
 	}
 	for k, v := range headers {
 		req.Header.Add(k, v)
@@ -293,14 +315,14 @@ func MakeHttpBearerTokenCall(headers map[string]string, bearerToken, method, url
 	return response, statusCode, nil
 }
 
-func MakeHttpBasicAuthCall(headers map[string]string, username, password, method, url string, body interface{}, log logger.Logger) (interface{}, int, error) {
+func MakeHttpBasicAuthCall(ctx context.Context, headers map[string]string, username, password, method, url string, body interface{}, log logger.Logger) (interface{}, int, error) {
 
 	var req *http.Request
 	if body != nil {
 		jsonReq, _ := json.Marshal(body)
-		req, _ = http.NewRequest(method, url, bytes.NewBuffer(jsonReq))
+		req, _ = http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(jsonReq))
 	} else {
-		req, _ = http.NewRequest(method, url, nil)
+		req, _ = http.NewRequestWithContext(ctx, method, url, nil)
 	}
 
 	for k, v := range headers {
@@ -326,13 +348,13 @@ func MakeHttpBasicAuthCall(headers map[string]string, username, password, method
 	return response, statusCode, nil
 }
 
-func MakeSignedHttpDuoCall(headers map[string]string, method, host string, url string, body interface{}, log logger.Logger) (interface{}, int, error) {
+func MakeSignedHttpDuoCall(ctx context.Context, headers map[string]string, method, url string, body interface{}, log logger.Logger) (interface{}, int, error) {
 	var req *http.Request
 	if body != nil {
 		jsonReq, _ := json.Marshal(body)
-		req, _ = http.NewRequest(method, host+url, bytes.NewBuffer(jsonReq))
+		req, _ = http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(jsonReq))
 	} else {
-		req, _ = http.NewRequest(method, host+url, nil)
+		req, _ = http.NewRequestWithContext(ctx, method, url, nil)
 	}
 
 	if headers != nil {
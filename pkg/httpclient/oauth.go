@@ -0,0 +1,84 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OAuth2Token is an access token issued by an OAuth2 token endpoint
+type OAuth2Token struct {
+	AccessToken  string
+	RefreshToken string
+	// Expiry is zero when the endpoint did not say when the token expires
+	Expiry time.Time
+}
+
+// OAuth2Client identifies the connector to token endpoints, ID is empty when it is not registered
+type OAuth2Client struct {
+	ID     string
+	Secret string
+}
+
+// RefreshOAuth2Token exchanges a refresh token for a new access token at refreshUrl
+func RefreshOAuth2Token(ctx context.Context, refreshUrl string, client OAuth2Client, refreshToken string) (OAuth2Token, error) {
+	return requestOAuth2Token(ctx, refreshUrl, client, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+}
+
+// ClientCredentialsToken requests an access token for the client itself at tokenUrl
+func ClientCredentialsToken(ctx context.Context, tokenUrl string, client OAuth2Client) (OAuth2Token, error) {
+	return requestOAuth2Token(ctx, tokenUrl, client, url.Values{"grant_type": {"client_credentials"}})
+}
+
+// requestOAuth2Token requests a token with the grant in form. Confidential clients
+// authenticate with HTTP basic auth, public ones send their client_id in the form.
+func requestOAuth2Token(ctx context.Context, tokenUrl string, client OAuth2Client, form url.Values) (OAuth2Token, error) {
+	if client.ID != "" && client.Secret == "" {
+		form.Set("client_id", client.ID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return OAuth2Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if client.ID != "" && client.Secret != "" {
+		// the credentials are form encoded before being encoded as basic auth, RFC 6749 2.3.1
+		req.SetBasicAuth(url.QueryEscape(client.ID), url.QueryEscape(client.Secret))
+	}
+
+	resp, err := do(newClient(time.Second*10), req)
+	if err != nil {
+		return OAuth2Token{}, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return OAuth2Token{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return OAuth2Token{}, fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken  string  `json:"access_token"`
+		RefreshToken string  `json:"refresh_token"`
+		ExpiresIn    float64 `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return OAuth2Token{}, fmt.Errorf("invalid token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return OAuth2Token{}, fmt.Errorf("token response has no access_token")
+	}
+	refreshed := OAuth2Token{AccessToken: token.AccessToken, RefreshToken: token.RefreshToken}
+	if token.ExpiresIn > 0 {
+		refreshed.Expiry = time.Now().Add(time.Duration(token.ExpiresIn * float64(time.Second)))
+	}
+	return refreshed, nil
+}
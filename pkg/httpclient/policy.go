@@ -0,0 +1,198 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Redirect policies supported by the upstream client
+const (
+	RedirectAll       = "all"
+	RedirectNone      = "none"
+	RedirectSameHost  = "same-host"
+	RedirectAllowlist = "allowlist"
+)
+
+// PrivateNetworks are the loopback, private, link-local and shared address ranges
+// blocked when private upstream addresses are disallowed
+var PrivateNetworks = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+// credentialHeaders are removed from requests redirected to a different host
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// Policy restricts where upstream requests may be sent
+type Policy struct {
+	// Redirects is one of RedirectAll, RedirectNone, RedirectSameHost or RedirectAllowlist
+	Redirects string
+	// RedirectHosts are the hosts redirects may go to with RedirectAllowlist
+	RedirectHosts []string
+	// BlockedNetworks are never dialed, whatever the host name resolves to
+	BlockedNetworks []*net.IPNet
+	// SensitiveHeaders are dropped, along with the standard credential headers, on cross-host redirects
+	SensitiveHeaders []string
+}
+
+// ErrBlockedAddress is returned when an upstream host resolves to a blocked network
+var ErrBlockedAddress = errors.New("upstream address is not allowed")
+
+var transport atomic.Value
+
+func init() {
+	Configure(Policy{Redirects: RedirectAll})
+}
+
+// ParseNetworks parses a list of CIDRs or single IP addresses
+func ParseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Configure applies the policy to every client created by this package
+func Configure(policy Policy) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		// checking the address actually dialed also covers DNS rebinding
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			for _, blocked := range policy.BlockedNetworks {
+				if ip != nil && blocked.Contains(ip) {
+					return fmt.Errorf("%w: %s", ErrBlockedAddress, ip)
+				}
+			}
+			return nil
+		},
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = dialer.DialContext
+	transport.Store(&clientPolicy{policy: policy, transport: t})
+}
+
+type clientPolicy struct {
+	policy    Policy
+	transport *http.Transport
+}
+
+// newClient returns a client that enforces the configured policy
+func newClient(timeout time.Duration) *http.Client {
+	p := transport.Load().(*clientPolicy)
+	return &http.Client{
+		Transport:     p.transport,
+		Timeout:       timeout,
+		CheckRedirect: p.checkRedirect,
+	}
+}
+
+func (p *clientPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	origin := via[0].URL
+	sameHost := strings.EqualFold(req.URL.Host, origin.Host)
+
+	switch p.policy.Redirects {
+	case RedirectNone:
+		return http.ErrUseLastResponse
+	case RedirectSameHost:
+		if !sameHost || req.URL.Scheme != origin.Scheme {
+			return http.ErrUseLastResponse
+		}
+	case RedirectAllowlist:
+		if !sameHost && !hostAllowed(req.URL.Hostname(), p.policy.RedirectHosts) {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	if !sameHost {
+		for _, header := range append(credentialHeaders, p.policy.SensitiveHeaders...) {
+			req.Header.Del(header)
+		}
+	}
+	return nil
+}
+
+func hostAllowed(host string, allowed []string) bool {
+	for _, pattern := range allowed {
+		if strings.EqualFold(host, pattern) {
+			return true
+		}
+		if strings.HasPrefix(pattern, "*.") && strings.HasSuffix(strings.ToLower(host), strings.ToLower(pattern[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// JoinURL appends the path and query of an incoming request URI to the upstream base URL.
+// Absolute-form request URIs only contribute their path and query, dot segments are
+// rejected, and the result is guaranteed to keep the scheme and host of the base URL.
+func JoinURL(base, requestURI string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	if baseURL.Scheme == "" || baseURL.Host == "" {
+		return "", fmt.Errorf("invalid upstream url %q", base)
+	}
+
+	reqURL, err := url.ParseRequestURI(requestURI)
+	if err != nil {
+		return "", fmt.Errorf("invalid request uri: %w", err)
+	}
+	path := reqURL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	for _, segment := range strings.Split(reqURL.Path, "/") {
+		if segment == ".." || segment == "." || strings.Contains(segment, "\\") {
+			return "", fmt.Errorf("invalid path segment in request uri %q", requestURI)
+		}
+	}
+
+	joined, err := url.Parse(strings.TrimSuffix(baseURL.String(), "/") + path)
+	if err != nil {
+		return "", err
+	}
+	joined.RawQuery = reqURL.RawQuery
+	if joined.Scheme != baseURL.Scheme || joined.Host != baseURL.Host || joined.User.String() != baseURL.User.String() {
+		return "", fmt.Errorf("request uri %q changes the upstream host", requestURI)
+	}
+	return joined.String(), nil
+}
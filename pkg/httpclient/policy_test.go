@@ -0,0 +1,96 @@
+package httpclient
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJoinURL(t *testing.T) {
+	tests := []struct {
+		base, uri, want string
+		wantErr         bool
+	}{
+		{"https://api.example.com", "/v2/tickets?page=2", "https://api.example.com/v2/tickets?page=2", false},
+		{"https://api.example.com/api/", "/v2/tickets", "https://api.example.com/api/v2/tickets", false},
+		{"https://api.example.com", "http://evil.com/v2/tickets", "https://api.example.com/v2/tickets", false},
+		{"https://api.example.com", "//evil.com/v2", "https://api.example.com//evil.com/v2", false},
+		{"https://api.example.com", "@evil.com/v2", "", true},
+		{"https://api.example.com/api", "/../admin", "", true},
+		{"https://api.example.com/api", "/%2e%2e/admin", "", true},
+		{"https://", "/v2", "", true},
+	}
+	for _, tt := range tests {
+		got, err := JoinURL(tt.base, tt.uri)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("JoinURL(%q, %q) error = %v, wantErr %v", tt.base, tt.uri, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("JoinURL(%q, %q) = %q, want %q", tt.base, tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	defer Configure(Policy{Redirects: RedirectAll})
+
+	var forwarded string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get("X-Custom-Key") + r.Header.Get("Authorization")
+	}))
+	defer other.Close()
+	_, port, _ := net.SplitHostPort(other.Listener.Addr().String())
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 127.0.0.1 and localhost are different hosts as far as redirects are concerned
+		http.Redirect(w, r, "http://localhost:"+port, http.StatusFound)
+	}))
+	defer origin.Close()
+
+	call := func() int {
+		req, _ := http.NewRequest("GET", origin.URL, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("X-Custom-Key", "secret")
+		resp, err := newClient(0).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	Configure(Policy{Redirects: RedirectAll, SensitiveHeaders: []string{"X-Custom-Key"}})
+	if status := call(); status != http.StatusOK || forwarded != "" {
+		t.Errorf("expected credentials to be dropped on cross-host redirect, got %d %q", status, forwarded)
+	}
+
+	Configure(Policy{Redirects: RedirectSameHost})
+	if status := call(); status != http.StatusFound {
+		t.Errorf("expected cross-host redirect not to be followed, got %d", status)
+	}
+
+	Configure(Policy{Redirects: RedirectAllowlist, RedirectHosts: []string{"localhost"}})
+	if status := call(); status != http.StatusOK {
+		t.Errorf("expected allowlisted redirect to be followed, got %d", status)
+	}
+}
+
+func TestBlockedNetworks(t *testing.T) {
+	defer Configure(Policy{Redirects: RedirectAll})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	networks, err := ParseNetworks(PrivateNetworks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Configure(Policy{BlockedNetworks: networks})
+
+	_, err = newClient(0).Get(server.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("expected loopback address to be blocked, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Redactor masks sensitive values before they are written to the log output
+type Redactor interface {
+	Value(v interface{}) interface{}
+	String(s string) string
+	IsSensitiveKey(key string) bool
+}
+
+const redactedValue = "[REDACTED]"
+
+type redactingLogger struct {
+	Logger
+	r Redactor
+}
+
+// WithRedaction wraps the logger so every message and argument passes through the redactor
+func WithRedaction(log Logger, r Redactor) Logger {
+	if l, ok := log.(*zap.SugaredLogger); ok {
+		// skip the wrapper frame so the caller field still points at the original call site
+		log = l.Desugar().WithOptions(zap.AddCallerSkip(1)).Sugar()
+	}
+	return &redactingLogger{Logger: log, r: r}
+}
+
+func (l *redactingLogger) sprint(args []interface{}) string {
+	return l.r.String(fmt.Sprint(l.values(args)...))
+}
+
+func (l *redactingLogger) sprintf(format string, args []interface{}) string {
+	return l.r.String(fmt.Sprintf(format, l.values(args)...))
+}
+
+func (l *redactingLogger) values(args []interface{}) []interface{} {
+	out := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			out[i] = l.r.String(v)
+		case []byte:
+			out[i] = l.r.String(string(v))
+		case error:
+			out[i] = l.r.String(v.Error())
+		default:
+			out[i] = l.r.Value(v)
+		}
+	}
+	return out
+}
+
+func (l *redactingLogger) Infow(msg string, keysAndValues ...interface{}) {
+	kv := l.values(keysAndValues)
+	for i := 0; i+1 < len(kv); i += 2 {
+		// a sensitive key masks its value, whatever its type
+		if key, ok := kv[i].(string); ok && l.r.IsSensitiveKey(key) {
+			kv[i+1] = redactedValue
+		}
+	}
+	l.Logger.Infow(l.r.String(msg), kv...)
+}
+
+func (l *redactingLogger) Infof(format string, args ...interface{}) {
+	l.Logger.Info(l.sprintf(format, args))
+}
+
+func (l *redactingLogger) Fatalf(format string, args ...interface{}) {
+	l.Logger.Fatal(l.sprintf(format, args))
+}
+
+func (l *redactingLogger) Errorf(format string, args ...interface{}) {
+	l.Logger.Error(l.sprintf(format, args))
+}
+
+func (l *redactingLogger) Warnf(format string, args ...interface{}) {
+	l.Logger.Warn(l.sprintf(format, args))
+}
+
+func (l *redactingLogger) Debugf(format string, args ...interface{}) {
+	l.Logger.Debug(l.sprintf(format, args))
+}
+
+func (l *redactingLogger) Debug(args ...interface{}) {
+	l.Logger.Debug(l.sprint(args))
+}
+
+func (l *redactingLogger) Warn(args ...interface{}) {
+	l.Logger.Warn(l.sprint(args))
+}
+
+func (l *redactingLogger) Error(args ...interface{}) {
+	l.Logger.Error(l.sprint(args))
+}
+
+func (l *redactingLogger) Info(args ...interface{}) {
+	l.Logger.Info(l.sprint(args))
+}
+
+func (l *redactingLogger) Fatal(args ...interface{}) {
+	l.Logger.Fatal(l.sprint(args))
+}
@@ -86,6 +86,9 @@ func WithFields(log Logger, fields ...interface{}) Logger {
 	if l, ok := log.(*zap.SugaredLogger); ok {
 		return l.With(fields...)
 	}
+	if l, ok := log.(*redactingLogger); ok {
+		return &redactingLogger{Logger: WithFields(l.Logger, l.values(fields)...), r: l.r}
+	}
 
 	log.Error("incompatible logger type")
 	return log
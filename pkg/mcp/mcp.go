@@ -0,0 +1,304 @@
+// Package mcp implements the tools of a Model Context Protocol server, over JSON-RPC 2.0
+// messages read from stdio or posted over HTTP.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ProtocolVersion is the latest protocol revision the server implements
+const ProtocolVersion = "2025-06-18"
+
+// MaxMessageSize bounds the size of the messages posted over HTTP
+const MaxMessageSize = 4 << 20
+
+// protocolVersions are the revisions the server can speak, the client's is used when it is one of them
+var protocolVersions = map[string]bool{ProtocolVersion: true, "2025-03-26": true, "2024-11-05": true}
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+var (
+	// ErrUnknownTool is returned by Toolset.Call for tools it does not have
+	ErrUnknownTool = errors.New("unknown tool")
+	// ErrInvalidArguments is returned by Toolset.Call when the arguments do not fit the tool
+	ErrInvalidArguments = errors.New("invalid arguments")
+)
+
+// Tool describes a tool and the arguments it takes
+type Tool struct {
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// InputSchema is the JSON Schema of the arguments, an object
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	Annotations *Annotations           `json:"annotations,omitempty"`
+}
+
+// Annotations hint at the effects of a tool
+type Annotations struct {
+	ReadOnlyHint   bool `json:"readOnlyHint,omitempty"`
+	IdempotentHint bool `json:"idempotentHint,omitempty"`
+}
+
+// Content is a part of a tool result
+type Content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Result is the outcome of a tool call, IsError tells the model the call failed
+type Result struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// TextResult returns a result made of text
+func TextResult(text string, isError bool) *Result {
+	return &Result{Content: []Content{{Type: "text", Text: text}}, IsError: isError}
+}
+
+// Toolset lists and runs the tools of a server
+type Toolset interface {
+	Tools(ctx context.Context) []Tool
+	// Call runs a tool. Errors other than ErrUnknownTool and ErrInvalidArguments are
+	// reported to the model as failed calls.
+	Call(ctx context.Context, name string, arguments map[string]interface{}) (*Result, error)
+}
+
+// Server answers the messages of MCP clients with its tools
+type Server struct {
+	Name    string
+	Version string
+	// Instructions tell the model how to use the server, optional
+	Instructions string
+	Tools        Toolset
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Handle answers a JSON-RPC message or batch of messages. It returns nil when nothing is
+// to be answered, for notifications and responses.
+func (s *Server) Handle(ctx context.Context, data []byte) []byte {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return encode(errorResponse(nil, codeParseError, err.Error()))
+		}
+		if len(batch) == 0 {
+			return encode(errorResponse(nil, codeInvalidRequest, "empty batch"))
+		}
+		var responses []*response
+		for _, raw := range batch {
+			if res := s.handle(ctx, raw); res != nil {
+				responses = append(responses, res)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return encode(responses)
+	}
+	if res := s.handle(ctx, data); res != nil {
+		return encode(res)
+	}
+	return nil
+}
+
+func (s *Server) handle(ctx context.Context, raw json.RawMessage) *response {
+	var msg message
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return errorResponse(nil, codeParseError, err.Error())
+	}
+	if msg.Method == "" {
+		if len(msg.ID) > 0 {
+			// responses to requests the server never sends
+			return nil
+		}
+		return errorResponse(nil, codeInvalidRequest, "missing method")
+	}
+	result, err := s.dispatch(ctx, msg)
+	if len(msg.ID) == 0 {
+		// notifications are never answered
+		return nil
+	}
+	if err != nil {
+		return err.response(msg.ID)
+	}
+	return &response{JSONRPC: "2.0", ID: msg.ID, Result: result}
+}
+
+func (s *Server) dispatch(ctx context.Context, msg message) (interface{}, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if err := decodeParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		version := ProtocolVersion
+		if protocolVersions[params.ProtocolVersion] {
+			version = params.ProtocolVersion
+		}
+		result := map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{"listChanged": false}},
+			"serverInfo":      map[string]interface{}{"name": s.Name, "version": s.Version},
+		}
+		if s.Instructions != "" {
+			result["instructions"] = s.Instructions
+		}
+		return result, nil
+	case "ping":
+		return map[string]interface{}{}, nil
+	case "tools/list":
+		tools := s.Tools.Tools(ctx)
+		if tools == nil {
+			tools = []Tool{}
+		}
+		return map[string]interface{}{"tools": tools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+		}
+		if err := decodeParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		result, err := s.Tools.Call(ctx, params.Name, params.Arguments)
+		if errors.Is(err, ErrUnknownTool) || errors.Is(err, ErrInvalidArguments) {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+		if err != nil {
+			return TextResult(err.Error(), true), nil
+		}
+		return result, nil
+	}
+	if strings.HasPrefix(msg.Method, "notifications/") {
+		return nil, nil
+	}
+	return nil, &rpcError{codeMethodNotFound, "method not found: " + msg.Method}
+}
+
+func decodeParams(params json.RawMessage, v interface{}) *rpcError {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{codeInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+func (e *rpcError) response(id json.RawMessage) *response {
+	return &response{JSONRPC: "2.0", ID: id, Error: e}
+}
+
+func errorResponse(id json.RawMessage, code int, message string) *response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &response{JSONRPC: "2.0", ID: id, Error: &rpcError{code, message}}
+}
+
+func encode(v interface{}) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// ServeStdio answers the newline delimited messages read from in on out until in is closed.
+// Messages are handled concurrently so a slow tool call does not hold up the others.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	reader := bufio.NewReader(in)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			wg.Add(1)
+			go func(line []byte) {
+				defer wg.Done()
+				if res := s.Handle(ctx, line); res != nil {
+					mu.Lock()
+					defer mu.Unlock()
+					out.Write(append(res, '\n'))
+				}
+			}(line)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ServeHTTP implements the streamable HTTP transport without server sent events: messages
+// are posted and answered with a JSON body, or 202 Accepted when there is no answer.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		// there is no stream of server initiated messages to open
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// browsers must not be able to reach the server from other sites, e.g. through DNS rebinding
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxMessageSize))
+	if err != nil {
+		status := http.StatusBadRequest
+		if len(data) >= MaxMessageSize {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	res := s.Handle(r.Context(), data)
+	if res == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
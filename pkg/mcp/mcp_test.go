@@ -0,0 +1,154 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type echoTools struct{}
+
+func (echoTools) Tools(ctx context.Context) []Tool {
+	return []Tool{{Name: "echo", InputSchema: map[string]interface{}{"type": "object"}}}
+}
+
+func (echoTools) Call(ctx context.Context, name string, arguments map[string]interface{}) (*Result, error) {
+	switch {
+	case name != "echo":
+		return nil, fmt.Errorf("%w %s", ErrUnknownTool, name)
+	case arguments["fail"] != nil:
+		return nil, errors.New("upstream unavailable")
+	}
+	return TextResult(fmt.Sprint(arguments["text"]), false), nil
+}
+
+func decode(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+	var v map[string]interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("invalid response %s: %v", data, err)
+	}
+	return v
+}
+
+func TestServer(t *testing.T) {
+	s := &Server{Name: "test", Version: "1", Tools: echoTools{}}
+	ctx := context.Background()
+
+	res := decode(t, s.Handle(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{}}}`)))
+	result := res["result"].(map[string]interface{})
+	if result["protocolVersion"] != "2025-03-26" || result["capabilities"].(map[string]interface{})["tools"] == nil {
+		t.Errorf("unexpected initialize result %v", result)
+	}
+	res = decode(t, s.Handle(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`)))
+	if version := res["result"].(map[string]interface{})["protocolVersion"]; version != ProtocolVersion {
+		t.Errorf("expected the latest revision for unknown ones, got %v", version)
+	}
+	if out := s.Handle(ctx, []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); out != nil {
+		t.Errorf("expected no answer to notifications, got %s", out)
+	}
+
+	res = decode(t, s.Handle(ctx, []byte(`{"jsonrpc":"2.0","id":"a","method":"tools/list"}`)))
+	if tools := res["result"].(map[string]interface{})["tools"].([]interface{}); len(tools) != 1 || res["id"] != "a" {
+		t.Errorf("unexpected tools %v", res)
+	}
+
+	res = decode(t, s.Handle(ctx, []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`)))
+	content := res["result"].(map[string]interface{})["content"].([]interface{})
+	if text := content[0].(map[string]interface{})["text"]; text != "hi" {
+		t.Errorf("unexpected call result %v", res)
+	}
+	res = decode(t, s.Handle(ctx, []byte(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"fail":true}}}`)))
+	if result := res["result"].(map[string]interface{}); result["isError"] != true {
+		t.Errorf("expected a failed call result, got %v", res)
+	}
+	res = decode(t, s.Handle(ctx, []byte(`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"missing"}}`)))
+	if code := res["error"].(map[string]interface{})["code"]; code != float64(codeInvalidParams) {
+		t.Errorf("expected invalid params for unknown tools, got %v", res)
+	}
+	res = decode(t, s.Handle(ctx, []byte(`{"jsonrpc":"2.0","id":5,"method":"resources/list"}`)))
+	if code := res["error"].(map[string]interface{})["code"]; code != float64(codeMethodNotFound) {
+		t.Errorf("expected method not found, got %v", res)
+	}
+	res = decode(t, s.Handle(ctx, []byte(`{"jsonrpc":`)))
+	if code := res["error"].(map[string]interface{})["code"]; code != float64(codeParseError) || res["id"] != nil {
+		t.Errorf("expected a parse error, got %v", res)
+	}
+
+	var batch []interface{}
+	if err := json.Unmarshal(s.Handle(ctx, []byte(`[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"}]`)), &batch); err != nil || len(batch) != 1 {
+		t.Errorf("expected one answer to the batch, got %v %v", batch, err)
+	}
+}
+
+func TestServeStdio(t *testing.T) {
+	s := &Server{Name: "test", Version: "1", Tools: echoTools{}}
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n\n" +
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	var out bytes.Buffer
+	if err := s.ServeStdio(context.Background(), in, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected an answer per request, got %q", out.String())
+	}
+	ids := map[float64]bool{}
+	for _, line := range lines {
+		ids[decode(t, []byte(line))["id"].(float64)] = true
+	}
+	if !ids[1] || !ids[2] {
+		t.Errorf("unexpected answers %q", out.String())
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	server := httptest.NewServer(&Server{Name: "test", Version: "1", Tools: echoTools{}})
+	defer server.Close()
+
+	post := func(body, origin string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := post(`{"jsonrpc":"2.0","id":1,"method":"ping"}`, "")
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected answer %d %s", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	res.Body.Close()
+	if res := post(`{"jsonrpc":"2.0","method":"notifications/initialized"}`, ""); res.StatusCode != http.StatusAccepted {
+		t.Errorf("expected notifications to be accepted, got %d", res.StatusCode)
+	}
+	if res := post(`{"jsonrpc":"2.0","id":1,"method":"ping"}`, "https://evil.example"); res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected other origins to be rejected, got %d", res.StatusCode)
+	}
+	if res := post(`{"jsonrpc":"2.0","id":1,"method":"ping"}`, server.URL); res.StatusCode != http.StatusOK {
+		t.Errorf("expected the server's own origin to be allowed, got %d", res.StatusCode)
+	}
+	if res := post(`{"jsonrpc":"2.0","id":1,"method":"ping","params":{"padding":"`+strings.Repeat("x", MaxMessageSize)+`"}}`, ""); res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected large messages to be rejected, got %d", res.StatusCode)
+	}
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected no event stream, got %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,231 @@
+package parser
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Document is an API description, independent of the specification format it was read from
+type Document struct {
+	Title   string
+	Version string
+	// Servers are the base URLs of the API
+	Servers []string
+	// SecuritySchemes are the ways of authenticating, by name
+	SecuritySchemes map[string]*SecurityScheme
+	// Security is the default security of every operation
+	Security []SecurityRequirement
+	// Operations are ordered most specific path first, then by path and method
+	Operations []*Operation
+}
+
+// Operation is a single method on a path of the API
+type Operation struct {
+	ID          string
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	Parameters  []*Parameter
+	RequestBody *RequestBody
+	// Responses are keyed by status code, or "default"
+	Responses map[string]*Response
+	// Security overrides the document security when it is not nil, an empty list means
+	// the operation requires no authentication
+	Security []SecurityRequirement
+
+	pattern    *regexp.Regexp
+	pathParams []string
+}
+
+// Parameter locations
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+	InCookie = "cookie"
+)
+
+// Parameter is a path, query, header or cookie parameter of an operation
+type Parameter struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Schema      *Schema
+	Example     interface{}
+}
+
+// RequestBody describes the body an operation accepts
+type RequestBody struct {
+	Description string
+	Required    bool
+	// Content holds the schema of the body by media type
+	Content map[string]*MediaType
+}
+
+// Response describes a response of an operation
+type Response struct {
+	Description string
+	Headers     map[string]*Schema
+	// Content holds the schema of the response body by media type
+	Content map[string]*MediaType
+}
+
+// MediaType is the schema and examples of a body in one media type
+type MediaType struct {
+	Schema   *Schema
+	Example  interface{}
+	Examples map[string]interface{}
+}
+
+// Security scheme types
+const (
+	SchemeAPIKey        = "apiKey"
+	SchemeHTTP          = "http"
+	SchemeOAuth2        = "oauth2"
+	SchemeOpenIDConnect = "openIdConnect"
+)
+
+// SecurityScheme describes a way of authenticating to the API
+type SecurityScheme struct {
+	Type        string
+	Description string
+	// Name and In locate the key of apiKey schemes, In is header, query or cookie
+	Name string
+	In   string
+	// Scheme is the HTTP auth scheme of http schemes, e.g. basic or bearer
+	Scheme       string
+	BearerFormat string
+	// Flows are the OAuth2 flows by name, e.g. clientCredentials or authorizationCode
+	Flows            map[string]*OAuthFlow
+	OpenIDConnectURL string
+}
+
+// OAuthFlow describes an OAuth2 flow
+type OAuthFlow struct {
+	AuthorizationURL string
+	TokenURL         string
+	RefreshURL       string
+	Scopes           map[string]string
+}
+
+// SecurityRequirement lists schemes that must all be satisfied, with the required scopes
+type SecurityRequirement map[string][]string
+
+// Operation returns the operation with the given id, nil when there is none
+func (d *Document) Operation(id string) *Operation {
+	for _, op := range d.Operations {
+		if op.ID == id {
+			return op
+		}
+	}
+	return nil
+}
+
+// Name returns the operation id, or the method and path template when it has none
+func (op *Operation) Name() string {
+	if op.ID != "" {
+		return op.ID
+	}
+	return op.Method + " " + op.Path
+}
+
+// Match returns the operation handling method and path, along with the values of its path
+// parameters. Literal path segments take precedence over templated ones, so /pets/mine
+// matches before /pets/{id}.
+func (d *Document) Match(method, path string) (*Operation, map[string]string) {
+	method = strings.ToUpper(method)
+	for _, op := range d.Operations {
+		if op.Method != method {
+			continue
+		}
+		if values := op.match(path); values != nil {
+			return op, values
+		}
+	}
+	return nil, nil
+}
+
+// SecurityOf returns the security requirements of op, inheriting those of the document
+func (d *Document) SecurityOf(op *Operation) []SecurityRequirement {
+	if op.Security != nil {
+		return op.Security
+	}
+	return d.Security
+}
+
+var templateParam = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// match returns the path parameters when path matches the operation path template
+func (op *Operation) match(path string) map[string]string {
+	if op.pattern == nil {
+		return nil
+	}
+	groups := op.pattern.FindStringSubmatch(path)
+	if groups == nil {
+		return nil
+	}
+	values := make(map[string]string, len(op.pathParams))
+	for i, name := range op.pathParams {
+		values[name] = groups[i+1]
+	}
+	return values
+}
+
+// compileTemplate turns a path template such as /pets/{id} into a regular expression
+// and returns it with the names of the path parameters in order
+func compileTemplate(template string) (*regexp.Regexp, []string) {
+	template = strings.TrimSuffix(template, "/")
+	var expr strings.Builder
+	var names []string
+	expr.WriteString("^")
+	last := 0
+	for _, loc := range templateParam.FindAllStringSubmatchIndex(template, -1) {
+		expr.WriteString(regexp.QuoteMeta(template[last:loc[0]]))
+		expr.WriteString("([^/]+)")
+		names = append(names, template[loc[2]:loc[3]])
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(template[last:]))
+	expr.WriteString("/?$")
+	return regexp.MustCompile(expr.String()), names
+}
+
+// Index compiles the path templates of the operations and orders them for Match. Documents
+// built outside this package must be indexed before they are matched.
+func (d *Document) Index() {
+	d.index()
+}
+
+// index orders the operations and compiles their path templates, documents are read-only
+// afterwards and safe for concurrent use
+func (d *Document) index() {
+	for _, op := range d.Operations {
+		op.pattern, op.pathParams = compileTemplate(op.Path)
+	}
+	sortOperations(d.Operations)
+}
+
+// sortOperations orders operations by path and method, with literal path segments before
+// templated ones so the most specific operation matches first
+func sortOperations(ops []*Operation) {
+	sort.SliceStable(ops, func(i, j int) bool {
+		a, b := ops[i], ops[j]
+		if sa, sb := specificity(a.Path), specificity(b.Path); sa != sb {
+			return sa > sb
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Method < b.Method
+	})
+}
+
+// specificity is the length of the literal part of a path template
+func specificity(template string) int {
+	return len(templateParam.ReplaceAllString(template, ""))
+}
@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"math"
+)
+
+// InferSchema returns a schema describing value, a decoded JSON value. Objects describe
+// their properties, arrays the schema of their first item, whole numbers are integers and
+// null is a nullable schema of any type.
+func InferSchema(value interface{}) *Schema {
+	switch v := value.(type) {
+	case nil:
+		return &Schema{Nullable: true}
+	case bool:
+		return &Schema{Type: "boolean"}
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return &Schema{Type: "integer"}
+		}
+		return &Schema{Type: "number"}
+	case int, int64:
+		return &Schema{Type: "integer"}
+	case string:
+		return &Schema{Type: "string"}
+	case []interface{}:
+		s := &Schema{Type: "array"}
+		if len(v) > 0 {
+			s.Items = InferSchema(v[0])
+		}
+		return s
+	case map[string]interface{}:
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema, len(v))}
+		for name, property := range v {
+			s.Properties[name] = InferSchema(property)
+		}
+		return s
+	}
+	return &Schema{}
+}
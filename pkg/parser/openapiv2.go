@@ -1 +1,297 @@
 package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// methods are the operation keys of a path item
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// swaggerReader builds a Document from a Swagger 2.0 document
+type swaggerReader struct {
+	resolver *resolver
+	schemas  *schemaReader
+	file     string
+	consumes []string
+	produces []string
+}
+
+// readSwagger reads the Swagger 2.0 document raw, loaded from file
+func readSwagger(raw map[string]interface{}, file string, r *resolver) (*Document, error) {
+	sr := &swaggerReader{
+		resolver: r,
+		schemas:  newSchemaReader(r),
+		file:     file,
+		consumes: strings2(raw["consumes"]),
+		produces: strings2(raw["produces"]),
+	}
+
+	info := object(raw["info"])
+	doc := &Document{
+		Title:           str(info["title"]),
+		Version:         str(info["version"]),
+		SecuritySchemes: make(map[string]*SecurityScheme),
+		Security:        readSecurity(raw["security"]),
+	}
+
+	if host := str(raw["host"]); host != "" {
+		schemes := strings2(raw["schemes"])
+		if len(schemes) == 0 {
+			schemes = []string{"https"}
+		}
+		for _, scheme := range schemes {
+			doc.Servers = append(doc.Servers, scheme+"://"+host+strings.TrimSuffix(str(raw["basePath"]), "/"))
+		}
+	}
+
+	for name, raw := range object(raw["securityDefinitions"]) {
+		doc.SecuritySchemes[name] = readSwaggerSecurityScheme(object(raw))
+	}
+
+	paths := object(raw["paths"])
+	for _, path := range sortedKeys(paths) {
+		item, itemFile, err := r.deref(paths[path], file)
+		if err != nil {
+			return nil, fmt.Errorf("paths %s: %w", path, err)
+		}
+		for _, method := range methods {
+			rawOp, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			op, err := sr.operation(strings.ToUpper(method), path, list(item["parameters"]), rawOp, itemFile)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+			doc.Operations = append(doc.Operations, op)
+		}
+	}
+	doc.index()
+	return doc, nil
+}
+
+func (sr *swaggerReader) operation(method, path string, pathParams []interface{}, raw map[string]interface{}, file string) (*Operation, error) {
+	op := &Operation{
+		ID:          str(raw["operationId"]),
+		Method:      method,
+		Path:        path,
+		Summary:     str(raw["summary"]),
+		Description: str(raw["description"]),
+		Tags:        strings2(raw["tags"]),
+		Deprecated:  boolean(raw["deprecated"]),
+		Responses:   make(map[string]*Response),
+	}
+	if security, ok := raw["security"]; ok {
+		op.Security = readSecurity(security)
+		if op.Security == nil {
+			op.Security = []SecurityRequirement{}
+		}
+	}
+
+	consumes, produces := sr.consumes, sr.produces
+	if v, ok := raw["consumes"]; ok {
+		consumes = strings2(v)
+	}
+	if v, ok := raw["produces"]; ok {
+		produces = strings2(v)
+	}
+	if len(consumes) == 0 {
+		consumes = []string{"application/json"}
+	}
+	if len(produces) == 0 {
+		produces = []string{"application/json"}
+	}
+
+	// operation parameters override path parameters with the same name and location
+	params := make(map[string]map[string]interface{})
+	var order []string
+	for _, rawParam := range append(append([]interface{}{}, pathParams...), list(raw["parameters"])...) {
+		param, _, err := sr.resolver.deref(rawParam, file)
+		if err != nil {
+			return nil, err
+		}
+		key := str(param["in"]) + ":" + str(param["name"])
+		if _, ok := params[key]; !ok {
+			order = append(order, key)
+		}
+		params[key] = param
+	}
+
+	var form *Schema
+	for _, key := range order {
+		param := params[key]
+		switch in := str(param["in"]); in {
+		case "body":
+			schema, err := sr.schemas.read(param["schema"], file)
+			if err != nil {
+				return nil, err
+			}
+			op.RequestBody = &RequestBody{
+				Description: str(param["description"]),
+				Required:    boolean(param["required"]),
+				Content:     make(map[string]*MediaType),
+			}
+			for _, mediaType := range consumes {
+				op.RequestBody.Content[mediaType] = &MediaType{Schema: schema, Example: param["x-example"]}
+			}
+		case "formData":
+			if form == nil {
+				form = &Schema{Type: "object", Properties: make(map[string]*Schema)}
+			}
+			schema, err := sr.parameterSchema(param, file)
+			if err != nil {
+				return nil, err
+			}
+			form.Properties[str(param["name"])] = schema
+			if boolean(param["required"]) {
+				form.Required = append(form.Required, str(param["name"]))
+			}
+		default:
+			schema, err := sr.parameterSchema(param, file)
+			if err != nil {
+				return nil, err
+			}
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:        str(param["name"]),
+				In:          in,
+				Description: str(param["description"]),
+				Required:    boolean(param["required"]) || in == InPath,
+				Schema:      schema,
+				Example:     param["x-example"],
+			})
+		}
+	}
+	if form != nil {
+		mediaTypes := consumes
+		if mediaTypes[0] == "application/json" {
+			mediaTypes = []string{"application/x-www-form-urlencoded"}
+		}
+		op.RequestBody = &RequestBody{Required: len(form.Required) > 0, Content: make(map[string]*MediaType)}
+		for _, mediaType := range mediaTypes {
+			op.RequestBody.Content[mediaType] = &MediaType{Schema: form}
+		}
+	}
+
+	responses := object(raw["responses"])
+	for _, code := range sortedKeys(responses) {
+		if strings.HasPrefix(code, "x-") {
+			continue
+		}
+		rawResponse, responseFile, err := sr.resolver.deref(responses[code], file)
+		if err != nil {
+			return nil, fmt.Errorf("response %s: %w", code, err)
+		}
+		response := &Response{Description: str(rawResponse["description"])}
+		for name, rawHeader := range object(rawResponse["headers"]) {
+			header, err := sr.parameterSchema(object(rawHeader), responseFile)
+			if err != nil {
+				return nil, err
+			}
+			if response.Headers == nil {
+				response.Headers = make(map[string]*Schema)
+			}
+			response.Headers[name] = header
+		}
+		schema, err := sr.schemas.read(rawResponse["schema"], responseFile)
+		if err != nil {
+			return nil, fmt.Errorf("response %s: %w", code, err)
+		}
+		examples := object(rawResponse["examples"])
+		if schema != nil || len(examples) > 0 {
+			response.Content = make(map[string]*MediaType)
+			for _, mediaType := range produces {
+				response.Content[mediaType] = &MediaType{Schema: schema, Example: examples[mediaType]}
+			}
+			// examples may be given for media types the operation does not list
+			for mediaType, example := range examples {
+				if _, ok := response.Content[mediaType]; !ok {
+					response.Content[mediaType] = &MediaType{Schema: schema, Example: example}
+				}
+			}
+		}
+		op.Responses[code] = response
+	}
+	return op, nil
+}
+
+// parameterSchema builds the schema of a non body parameter or a header, whose type is
+// described inline in Swagger 2.0
+func (sr *swaggerReader) parameterSchema(param map[string]interface{}, file string) (*Schema, error) {
+	inline := make(map[string]interface{}, len(param))
+	for k, v := range param {
+		switch k {
+		case "name", "in", "required", "description", "collectionFormat", "allowEmptyValue":
+		default:
+			inline[k] = v
+		}
+	}
+	if inline["type"] == "file" {
+		inline["type"], inline["format"] = "string", "binary"
+	}
+	return sr.schemas.read(inline, file)
+}
+
+func readSwaggerSecurityScheme(raw map[string]interface{}) *SecurityScheme {
+	scheme := &SecurityScheme{Type: str(raw["type"]), Description: str(raw["description"])}
+	switch scheme.Type {
+	case "basic":
+		scheme.Type, scheme.Scheme = SchemeHTTP, "basic"
+	case SchemeAPIKey:
+		scheme.Name, scheme.In = str(raw["name"]), str(raw["in"])
+	case SchemeOAuth2:
+		// Swagger 2.0 flow names, renamed to their OpenAPI 3 equivalent
+		flows := map[string]string{
+			"implicit":    "implicit",
+			"password":    "password",
+			"application": "clientCredentials",
+			"accessCode":  "authorizationCode",
+		}
+		flow := &OAuthFlow{
+			AuthorizationURL: str(raw["authorizationUrl"]),
+			TokenURL:         str(raw["tokenUrl"]),
+			Scopes:           stringMap(raw["scopes"]),
+		}
+		scheme.Flows = map[string]*OAuthFlow{flows[str(raw["flow"])]: flow}
+	}
+	return scheme
+}
+
+// readSecurity reads a list of security requirements, nil when raw is not a list
+func readSecurity(raw interface{}) []SecurityRequirement {
+	var requirements []SecurityRequirement
+	for _, item := range list(raw) {
+		requirement := SecurityRequirement{}
+		for name, scopes := range object(item) {
+			requirement[name] = strings2(scopes)
+		}
+		requirements = append(requirements, requirement)
+	}
+	return requirements
+}
+
+func strings2(v interface{}) []string {
+	var s []string
+	for _, item := range list(v) {
+		s = append(s, fmt.Sprint(item))
+	}
+	return s
+}
+
+func stringMap(v interface{}) map[string]string {
+	m := make(map[string]string)
+	for k, item := range object(v) {
+		m[k] = fmt.Sprint(item)
+	}
+	return m
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
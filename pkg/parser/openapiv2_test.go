@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestLoadSwagger(t *testing.T) {
+	doc, err := Load("testdata/petstore.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "Petstore" || len(doc.Servers) != 1 || doc.Servers[0] != "https://petstore.example.com/v2" {
+		t.Errorf("unexpected document %q %v", doc.Title, doc.Servers)
+	}
+	if len(doc.Operations) != 4 {
+		t.Fatalf("expected 4 operations, got %d", len(doc.Operations))
+	}
+
+	tests := []struct {
+		method, path, id string
+		params           map[string]string
+	}{
+		{"GET", "/pets", "listPets", map[string]string{}},
+		{"post", "/pets/", "createPet", map[string]string{}},
+		{"GET", "/pets/mine", "myPets", map[string]string{}},
+		{"GET", "/pets/42", "getPet", map[string]string{"id": "42"}},
+		{"DELETE", "/pets/42", "", nil},
+		{"GET", "/pets/42/toys", "", nil},
+	}
+	for _, test := range tests {
+		op, params := doc.Match(test.method, test.path)
+		if test.id == "" {
+			if op != nil {
+				t.Errorf("%s %s: expected no match, got %s", test.method, test.path, op.ID)
+			}
+			continue
+		}
+		if op == nil || op.ID != test.id {
+			t.Errorf("%s %s: expected %s, got %v", test.method, test.path, test.id, op)
+			continue
+		}
+		for name, value := range test.params {
+			if params[name] != value {
+				t.Errorf("%s %s: expected %s=%s, got %v", test.method, test.path, name, value, params)
+			}
+		}
+	}
+
+	list := doc.Operation("listPets")
+	if len(list.Parameters) != 1 || list.Parameters[0].Name != "limit" || list.Parameters[0].Schema.Type != "integer" ||
+		*list.Parameters[0].Schema.Maximum != 100 {
+		t.Errorf("unexpected parameters %+v", list.Parameters)
+	}
+	pets := list.Responses["200"].Content["application/json"].Schema
+	if pets.Type != "array" || pets.Items.Ref != "#/definitions/Pet" {
+		t.Errorf("unexpected response schema %+v", pets)
+	}
+	// recursive schemas point back to themselves
+	if pets.Items.Properties["children"].Items != pets.Items {
+		t.Error("expected the recursive Pet schema to be shared")
+	}
+
+	// operation parameters override path parameters
+	get := doc.Operation("getPet")
+	if len(get.Parameters) != 1 || get.Parameters[0].Schema.Type != "integer" || !get.Parameters[0].Required {
+		t.Errorf("unexpected parameters %+v", get.Parameters[0])
+	}
+
+	create := doc.Operation("createPet")
+	body := create.RequestBody
+	if body == nil || !body.Required || body.Content["application/json"].Schema.Required[0] != "name" {
+		t.Errorf("unexpected request body %+v", body)
+	}
+	if create.Responses["201"].Headers["Location"].Type != "string" {
+		t.Errorf("unexpected response %+v", create.Responses["201"])
+	}
+
+	// external references
+	mine := doc.Operation("myPets")
+	if mine.Responses["default"].Content["application/json"].Schema.Properties["code"].Type != "integer" {
+		t.Errorf("unexpected response %+v", mine.Responses["default"])
+	}
+
+	if s := doc.SecurityOf(list); len(s) != 1 || !hasScheme(s[0], "api_key") {
+		t.Errorf("expected the document security, got %v", s)
+	}
+	if s := doc.SecurityOf(mine); s == nil || len(s) != 0 {
+		t.Errorf("expected no security, got %v", s)
+	}
+	if s := doc.SecurityOf(create); len(s) != 1 || s[0]["oauth"][0] != "pets:read" {
+		t.Errorf("expected the oauth security, got %v", s)
+	}
+	if key := doc.SecuritySchemes["api_key"]; key.Type != SchemeAPIKey || key.In != InHeader || key.Name != "X-Api-Key" {
+		t.Errorf("unexpected scheme %+v", key)
+	}
+	if flow := doc.SecuritySchemes["oauth"].Flows["clientCredentials"]; flow == nil || flow.TokenURL != "https://auth.example.com/token" {
+		t.Errorf("unexpected scheme %+v", doc.SecuritySchemes["oauth"])
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	if _, err := Load("testdata/errors.yaml"); err == nil {
+		t.Error("expected a document without a version to be rejected")
+	}
+	if _, err := Load("testdata/missing.yaml"); err == nil {
+		t.Error("expected a missing document to be rejected")
+	}
+}
+
+func hasScheme(requirement SecurityRequirement, name string) bool {
+	_, ok := requirement[name]
+	return ok
+}
@@ -0,0 +1,246 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// openAPIReader builds a Document from an OpenAPI 3.x document
+type openAPIReader struct {
+	resolver *resolver
+	schemas  *schemaReader
+}
+
+// readOpenAPI reads the OpenAPI 3.x document raw, loaded from file
+func readOpenAPI(raw map[string]interface{}, file string, r *resolver) (*Document, error) {
+	or := &openAPIReader{resolver: r, schemas: newSchemaReader(r)}
+
+	info := object(raw["info"])
+	doc := &Document{
+		Title:           str(info["title"]),
+		Version:         str(info["version"]),
+		Servers:         readServers(raw["servers"]),
+		SecuritySchemes: make(map[string]*SecurityScheme),
+		Security:        readSecurity(raw["security"]),
+	}
+
+	components := object(raw["components"])
+	for name, rawScheme := range object(components["securitySchemes"]) {
+		scheme, _, err := r.deref(rawScheme, file)
+		if err != nil {
+			return nil, fmt.Errorf("security scheme %s: %w", name, err)
+		}
+		doc.SecuritySchemes[name] = readSecurityScheme(scheme)
+	}
+
+	paths := object(raw["paths"])
+	for _, path := range sortedKeys(paths) {
+		if strings.HasPrefix(path, "x-") {
+			continue
+		}
+		item, itemFile, err := r.deref(paths[path], file)
+		if err != nil {
+			return nil, fmt.Errorf("paths %s: %w", path, err)
+		}
+		for _, method := range methods {
+			rawOp, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			op, err := or.operation(strings.ToUpper(method), path, list(item["parameters"]), rawOp, itemFile)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+			doc.Operations = append(doc.Operations, op)
+		}
+	}
+	doc.index()
+	return doc, nil
+}
+
+func (or *openAPIReader) operation(method, path string, pathParams []interface{}, raw map[string]interface{}, file string) (*Operation, error) {
+	op := &Operation{
+		ID:          str(raw["operationId"]),
+		Method:      method,
+		Path:        path,
+		Summary:     str(raw["summary"]),
+		Description: str(raw["description"]),
+		Tags:        strings2(raw["tags"]),
+		Deprecated:  boolean(raw["deprecated"]),
+		Responses:   make(map[string]*Response),
+	}
+	if security, ok := raw["security"]; ok {
+		op.Security = readSecurity(security)
+		if op.Security == nil {
+			op.Security = []SecurityRequirement{}
+		}
+	}
+
+	// operation parameters override path parameters with the same name and location
+	params := make(map[string]*Parameter)
+	var order []string
+	for _, rawParam := range append(append([]interface{}{}, pathParams...), list(raw["parameters"])...) {
+		param, err := or.parameter(rawParam, file)
+		if err != nil {
+			return nil, err
+		}
+		key := param.In + ":" + param.Name
+		if _, ok := params[key]; !ok {
+			order = append(order, key)
+		}
+		params[key] = param
+	}
+	for _, key := range order {
+		op.Parameters = append(op.Parameters, params[key])
+	}
+
+	if rawBody, ok := raw["requestBody"]; ok {
+		body, bodyFile, err := or.resolver.deref(rawBody, file)
+		if err != nil {
+			return nil, fmt.Errorf("request body: %w", err)
+		}
+		op.RequestBody = &RequestBody{
+			Description: str(body["description"]),
+			Required:    boolean(body["required"]),
+		}
+		if op.RequestBody.Content, err = or.content(body["content"], bodyFile); err != nil {
+			return nil, fmt.Errorf("request body: %w", err)
+		}
+	}
+
+	responses := object(raw["responses"])
+	for _, code := range sortedKeys(responses) {
+		if strings.HasPrefix(code, "x-") {
+			continue
+		}
+		rawResponse, responseFile, err := or.resolver.deref(responses[code], file)
+		if err != nil {
+			return nil, fmt.Errorf("response %s: %w", code, err)
+		}
+		response := &Response{Description: str(rawResponse["description"])}
+		for name, rawHeader := range object(rawResponse["headers"]) {
+			header, headerFile, err := or.resolver.deref(rawHeader, responseFile)
+			if err != nil {
+				return nil, fmt.Errorf("response %s header %s: %w", code, name, err)
+			}
+			schema, err := or.schemas.read(header["schema"], headerFile)
+			if err != nil {
+				return nil, err
+			}
+			if response.Headers == nil {
+				response.Headers = make(map[string]*Schema)
+			}
+			response.Headers[name] = schema
+		}
+		if response.Content, err = or.content(rawResponse["content"], responseFile); err != nil {
+			return nil, fmt.Errorf("response %s: %w", code, err)
+		}
+		op.Responses[code] = response
+	}
+	return op, nil
+}
+
+func (or *openAPIReader) parameter(raw interface{}, file string) (*Parameter, error) {
+	param, paramFile, err := or.resolver.deref(raw, file)
+	if err != nil {
+		return nil, err
+	}
+	p := &Parameter{
+		Name:        str(param["name"]),
+		In:          str(param["in"]),
+		Description: str(param["description"]),
+		Required:    boolean(param["required"]) || str(param["in"]) == InPath,
+		Example:     param["example"],
+	}
+	rawSchema := param["schema"]
+	// parameters described with content have a single media type
+	for _, media := range object(param["content"]) {
+		rawSchema = object(media)["schema"]
+	}
+	if p.Schema, err = or.schemas.read(rawSchema, paramFile); err != nil {
+		return nil, fmt.Errorf("parameter %s: %w", p.Name, err)
+	}
+	if p.Example == nil {
+		p.Example = firstExample(or.resolver, param["examples"], paramFile)
+	}
+	return p, nil
+}
+
+// content reads a map of media types to their schema and examples
+func (or *openAPIReader) content(raw interface{}, file string) (map[string]*MediaType, error) {
+	content := object(raw)
+	if len(content) == 0 {
+		return nil, nil
+	}
+	mediaTypes := make(map[string]*MediaType, len(content))
+	for name, rawMedia := range content {
+		media := object(rawMedia)
+		schema, err := or.schemas.read(media["schema"], file)
+		if err != nil {
+			return nil, err
+		}
+		mediaType := &MediaType{Schema: schema, Example: media["example"]}
+		for exampleName, rawExample := range object(media["examples"]) {
+			example, _, err := or.resolver.deref(rawExample, file)
+			if err != nil {
+				return nil, fmt.Errorf("example %s: %w", exampleName, err)
+			}
+			if mediaType.Examples == nil {
+				mediaType.Examples = make(map[string]interface{})
+			}
+			mediaType.Examples[exampleName] = example["value"]
+		}
+		mediaTypes[name] = mediaType
+	}
+	return mediaTypes, nil
+}
+
+// firstExample returns the value of the first named example, by name
+func firstExample(r *resolver, raw interface{}, file string) interface{} {
+	examples := object(raw)
+	for _, name := range sortedKeys(examples) {
+		if example, _, err := r.deref(examples[name], file); err == nil {
+			return example["value"]
+		}
+	}
+	return nil
+}
+
+// readServers returns the server urls with their variables set to their default
+func readServers(raw interface{}) []string {
+	var servers []string
+	for _, item := range list(raw) {
+		server := object(item)
+		u := str(server["url"])
+		for name, variable := range object(server["variables"]) {
+			u = strings.ReplaceAll(u, "{"+name+"}", fmt.Sprint(object(variable)["default"]))
+		}
+		servers = append(servers, strings.TrimSuffix(u, "/"))
+	}
+	return servers
+}
+
+func readSecurityScheme(raw map[string]interface{}) *SecurityScheme {
+	scheme := &SecurityScheme{
+		Type:             str(raw["type"]),
+		Description:      str(raw["description"]),
+		Name:             str(raw["name"]),
+		In:               str(raw["in"]),
+		Scheme:           strings.ToLower(str(raw["scheme"])),
+		BearerFormat:     str(raw["bearerFormat"]),
+		OpenIDConnectURL: str(raw["openIdConnectUrl"]),
+	}
+	for name, rawFlow := range object(raw["flows"]) {
+		flow := object(rawFlow)
+		if scheme.Flows == nil {
+			scheme.Flows = make(map[string]*OAuthFlow)
+		}
+		scheme.Flows[name] = &OAuthFlow{
+			AuthorizationURL: str(flow["authorizationUrl"]),
+			TokenURL:         str(flow["tokenUrl"]),
+			RefreshURL:       str(flow["refreshUrl"]),
+			Scopes:           stringMap(flow["scopes"]),
+		}
+	}
+	return scheme
+}
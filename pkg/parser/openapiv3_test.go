@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestLoadOpenAPI(t *testing.T) {
+	doc, err := Load("testdata/tickets.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0] != "https://acme.example.com/api/v2" {
+		t.Errorf("expected the server variables to be set to their default, got %v", doc.Servers)
+	}
+
+	key, token, oauth := doc.SecuritySchemes["key"], doc.SecuritySchemes["token"], doc.SecuritySchemes["oauth"]
+	if key.Type != SchemeAPIKey || key.In != InQuery || key.Name != "api_key" {
+		t.Errorf("unexpected scheme %+v", key)
+	}
+	if token.Type != SchemeHTTP || token.Scheme != "bearer" {
+		t.Errorf("unexpected scheme %+v", token)
+	}
+	if oauth.Type != SchemeOAuth2 || oauth.Flows["clientCredentials"].TokenURL != "https://auth.example.com/token" {
+		t.Errorf("unexpected scheme %+v", oauth)
+	}
+
+	get, params := doc.Match("GET", "/tickets/7")
+	if get == nil || get.ID != "getTicket" || params["id"] != "7" {
+		t.Fatalf("unexpected match %v %v", get, params)
+	}
+	if len(get.Parameters) != 2 || get.Parameters[0].Schema.Type != "integer" || get.Parameters[1].Schema.Items.Type != "string" {
+		t.Errorf("unexpected parameters %+v", get.Parameters)
+	}
+	media := get.Responses["200"].Content["application/json"]
+	if media.Schema.Ref != "#/components/schemas/Ticket" || media.Example.(map[string]interface{})["subject"] != "Printer on fire" {
+		t.Errorf("unexpected response %+v", media)
+	}
+	if s := doc.SecurityOf(get); len(s) != 1 || !hasScheme(s[0], "key") {
+		t.Errorf("expected the document security, got %v", s)
+	}
+
+	create := doc.Operation("createTicket")
+	body := create.RequestBody.Content["application/json"]
+	if !create.RequestBody.Required || *body.Schema.Properties["subject"].MaxLength != 255 || len(body.Schema.Properties["status"].Enum) != 2 {
+		t.Errorf("unexpected request body %+v", body.Schema)
+	}
+	if body.Examples["printer"] == nil {
+		t.Errorf("expected the named example, got %v", body.Examples)
+	}
+	if create.Responses["201"].Headers["Location"].Type != "string" {
+		t.Errorf("unexpected response headers %+v", create.Responses["201"].Headers)
+	}
+	if s := doc.SecurityOf(create); len(s) != 2 || !hasScheme(s[0], "oauth") || !hasScheme(s[1], "token") {
+		t.Errorf("expected the operation security, got %v", s)
+	}
+}
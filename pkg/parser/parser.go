@@ -0,0 +1,39 @@
+// Package parser reads API descriptions, OpenAPI 3 and Swagger 2.0 documents or Postman
+// collections, into a Document, an operation table independent of the format of the
+// description.
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Load reads the API description in the YAML or JSON file at path and resolves the
+// references it makes, including references to other local files
+func Load(path string) (*Document, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	r := newResolver()
+	raw, err := r.load(path)
+	if err != nil {
+		return nil, err
+	}
+	root := object(raw)
+	switch {
+	case str(root["swagger"]) == "2.0":
+		return readSwagger(root, path, r)
+	case strings.HasPrefix(str(root["openapi"]), "3."):
+		return readOpenAPI(root, path, r)
+	case isPostman(root):
+		return readPostman(root)
+	case root["swagger"] != nil:
+		return nil, fmt.Errorf("unsupported swagger version %v", root["swagger"])
+	case root["openapi"] != nil:
+		return nil, fmt.Errorf("unsupported openapi version %v", root["openapi"])
+	default:
+		return nil, fmt.Errorf("%s is neither a Swagger 2.0 or OpenAPI 3 document nor a Postman collection", path)
+	}
+}
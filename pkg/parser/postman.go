@@ -0,0 +1,600 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// postmanVariable matches the {{name}} variables of Postman collections
+var postmanVariable = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// isPostman returns whether raw is a Postman collection
+func isPostman(raw map[string]interface{}) bool {
+	return strings.Contains(str(object(raw["info"])["schema"]), "getpostman.com/json/collection/")
+}
+
+// postmanReader builds a Document from a Postman v2.1 collection. Requests become
+// operations, their saved responses the examples of the operation, and the auth of the
+// collection, folders and requests security schemes.
+type postmanReader struct {
+	doc       *Document
+	variables map[string]string
+	// servers counts the base URL of every request, the most used one comes first
+	servers map[string]int
+	// schemes are the names of the security schemes by their definition
+	schemes map[string]string
+	ops     map[string]*Operation
+	ids     map[string]bool
+}
+
+// readPostman reads the Postman collection raw
+func readPostman(raw map[string]interface{}) (*Document, error) {
+	info := object(raw["info"])
+	if schema := str(info["schema"]); !strings.Contains(schema, "/v2.1") {
+		return nil, fmt.Errorf("unsupported Postman collection schema %s, export the collection as v2.1", schema)
+	}
+	pr := &postmanReader{
+		doc: &Document{
+			Title:           str(info["name"]),
+			Version:         str(info["version"]),
+			SecuritySchemes: make(map[string]*SecurityScheme),
+		},
+		variables: make(map[string]string),
+		servers:   make(map[string]int),
+		schemes:   make(map[string]string),
+		ops:       make(map[string]*Operation),
+		ids:       make(map[string]bool),
+	}
+	for _, item := range list(raw["variable"]) {
+		variable := object(item)
+		if !boolean(variable["disabled"]) && variable["value"] != nil {
+			pr.variables[str(variable["key"])] = fmt.Sprint(variable["value"])
+		}
+	}
+
+	auth := object(raw["auth"])
+	if name := pr.scheme(auth); name != "" {
+		pr.doc.Security = []SecurityRequirement{{name: []string{}}}
+	}
+	pr.items(list(raw["item"]), nil, auth, auth)
+
+	for server := range pr.servers {
+		pr.doc.Servers = append(pr.doc.Servers, server)
+	}
+	sort.Slice(pr.doc.Servers, func(i, j int) bool {
+		a, b := pr.doc.Servers[i], pr.doc.Servers[j]
+		if pr.servers[a] != pr.servers[b] {
+			return pr.servers[a] > pr.servers[b]
+		}
+		return a < b
+	})
+	pr.doc.index()
+	return pr.doc, nil
+}
+
+// items reads the requests of a folder, tagged with the name of their top level folder.
+// Items inherit the auth of their folder unless they set their own.
+func (pr *postmanReader) items(items []interface{}, tags []string, collectionAuth, auth map[string]interface{}) {
+	for _, raw := range items {
+		item := object(raw)
+		itemAuth := auth
+		if own := object(item["auth"]); own != nil && str(own["type"]) != "inherit" {
+			itemAuth = own
+		}
+		if children, ok := item["item"]; ok {
+			folderTags := tags
+			if len(tags) == 0 {
+				folderTags = []string{str(item["name"])}
+			}
+			pr.items(list(children), folderTags, collectionAuth, itemAuth)
+			continue
+		}
+		pr.request(item, tags, collectionAuth, itemAuth)
+	}
+}
+
+func (pr *postmanReader) request(item map[string]interface{}, tags []string, collectionAuth, auth map[string]interface{}) {
+	request := object(item["request"])
+	if raw, ok := item["request"].(string); ok {
+		request = map[string]interface{}{"url": raw}
+	}
+	// folders hold their auth, requests hold theirs on the request
+	if own := object(request["auth"]); own != nil && str(own["type"]) != "inherit" {
+		auth = own
+	}
+	method := strings.ToUpper(str(request["method"]))
+	if method == "" {
+		method = "GET"
+	}
+	server, path, params := pr.url(request["url"])
+	if server != "" {
+		pr.servers[server]++
+	}
+
+	op := &Operation{
+		ID:          pr.operationID(str(item["name"])),
+		Method:      method,
+		Path:        path,
+		Summary:     str(item["name"]),
+		Description: description(request["description"]),
+		Tags:        tags,
+		Parameters:  params,
+		Responses:   make(map[string]*Response),
+	}
+	if name := pr.scheme(auth); name != pr.scheme(collectionAuth) {
+		op.Security = []SecurityRequirement{}
+		if name != "" {
+			op.Security = append(op.Security, SecurityRequirement{name: []string{}})
+		}
+	}
+
+	contentType := ""
+	for _, raw := range list(request["header"]) {
+		header := object(raw)
+		name := str(header["key"])
+		if boolean(header["disabled"]) || name == "" {
+			continue
+		}
+		switch strings.ToLower(name) {
+		case "content-type":
+			contentType = pr.substitute(str(header["value"]))
+		case "authorization":
+			// credentials are described by the auth of the request
+		default:
+			op.Parameters = append(op.Parameters, pr.parameter(name, InHeader, header))
+		}
+	}
+	op.RequestBody = pr.body(object(request["body"]), contentType)
+
+	for i, raw := range list(item["response"]) {
+		pr.response(op, object(raw), i)
+	}
+
+	key := method + " " + path
+	if existing, ok := pr.ops[key]; ok {
+		mergeOperation(existing, op)
+		return
+	}
+	pr.ops[key] = op
+	pr.doc.Operations = append(pr.doc.Operations, op)
+}
+
+// url reads the url of a request into the server, the path template and the path and
+// query parameters. Path segments such as :id or {{id}} are path parameters.
+func (pr *postmanReader) url(raw interface{}) (string, string, []*Parameter) {
+	u := object(raw)
+	if s, ok := raw.(string); ok {
+		u = map[string]interface{}{"raw": s}
+	}
+	if u["host"] == nil && u["path"] == nil {
+		u = pr.parseURL(str(u["raw"]))
+	}
+
+	host := str(u["host"])
+	if segments := list(u["host"]); segments != nil {
+		host = strings.Join(strings2(segments), ".")
+	}
+	host = pr.substitute(host)
+	if port := str(u["port"]); port != "" {
+		host += ":" + port
+	}
+	server := host
+	if !strings.Contains(server, "://") && server != "" {
+		protocol := str(u["protocol"])
+		if protocol == "" {
+			protocol = "https"
+		}
+		server = protocol + "://" + server
+	}
+	if strings.Contains(server, "{{") {
+		server = ""
+	}
+	server = strings.TrimSuffix(server, "/")
+
+	examples := make(map[string]map[string]interface{})
+	for _, item := range list(u["variable"]) {
+		variable := object(item)
+		examples[str(variable["key"])] = variable
+	}
+
+	var params []*Parameter
+	segments := strings2(u["path"])
+	if path, ok := u["path"].(string); ok {
+		segments = strings.Split(path, "/")
+	}
+	var template []string
+	for _, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		name := ""
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			name = segment[1:]
+		case postmanVariable.FindString(segment) == segment:
+			name = postmanVariable.FindStringSubmatch(segment)[1]
+		}
+		if name == "" {
+			template = append(template, pr.substitute(segment))
+			continue
+		}
+		template = append(template, "{"+name+"}")
+		param := &Parameter{Name: name, In: InPath, Required: true, Schema: &Schema{Type: "string"}}
+		if variable, ok := examples[name]; ok {
+			param.Description = description(variable["description"])
+			param.Example = example(name, str(variable["value"]))
+		}
+		params = append(params, param)
+	}
+
+	for _, item := range list(u["query"]) {
+		query := object(item)
+		if boolean(query["disabled"]) || str(query["key"]) == "" {
+			continue
+		}
+		params = append(params, pr.parameter(str(query["key"]), InQuery, query))
+	}
+	return server, "/" + strings.Join(template, "/"), params
+}
+
+// parseURL splits a raw url into the parts of a Postman url object
+func (pr *postmanReader) parseURL(raw string) map[string]interface{} {
+	u := make(map[string]interface{})
+	raw, query, _ := strings.Cut(raw, "?")
+	if protocol, rest, ok := strings.Cut(raw, "://"); ok {
+		u["protocol"], raw = protocol, rest
+	}
+	host, path, _ := strings.Cut(raw, "/")
+	u["host"], u["path"] = host, path
+	if query != "" {
+		var items []interface{}
+		for _, pair := range strings.Split(query, "&") {
+			key, value, _ := strings.Cut(pair, "=")
+			if unescaped, err := url.QueryUnescape(value); err == nil {
+				value = unescaped
+			}
+			items = append(items, map[string]interface{}{"key": key, "value": value})
+		}
+		u["query"] = items
+	}
+	return u
+}
+
+// parameter reads a header or query parameter, its value being the example
+func (pr *postmanReader) parameter(name, in string, raw map[string]interface{}) *Parameter {
+	return &Parameter{
+		Name:        name,
+		In:          in,
+		Description: description(raw["description"]),
+		Schema:      &Schema{Type: "string"},
+		Example:     example(name, str(raw["value"])),
+	}
+}
+
+// example returns the value of the named parameter as its example. Documents are served
+// to anyone, so values holding variables, which often are credentials, and the values of
+// credentials have none.
+func example(name, value string) interface{} {
+	if value == "" || strings.Contains(value, "{{") || isCredential(name) {
+		return nil
+	}
+	return value
+}
+
+// isCredential returns whether a parameter name looks like it holds a credential
+func isCredential(name string) bool {
+	name = strings.ToLower(name)
+	if name == "authorization" || name == "proxy-authorization" || name == "cookie" {
+		return true
+	}
+	for _, word := range []string{"key", "token", "secret", "password"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// substitute replaces the collection variables of s, leaving undefined ones as they are
+func (pr *postmanReader) substitute(s string) string {
+	return postmanVariable.ReplaceAllStringFunc(s, func(variable string) string {
+		if value, ok := pr.variables[postmanVariable.FindStringSubmatch(variable)[1]]; ok {
+			return value
+		}
+		return variable
+	})
+}
+
+// body reads the body of a request, its content being the example of the request body
+func (pr *postmanReader) body(body map[string]interface{}, contentType string) *RequestBody {
+	var mediaType string
+	var value interface{}
+	// bodies holding variables are described without their example
+	variables := false
+	switch str(body["mode"]) {
+	case "raw":
+		raw := str(body["raw"])
+		if strings.TrimSpace(raw) == "" {
+			return nil
+		}
+		mediaType = contentType
+		if mediaType == "" && str(object(object(body["options"])["raw"])["language"]) == "json" {
+			mediaType = "application/json"
+		}
+		variables = postmanVariable.MatchString(raw)
+		mediaType, value = decodeExample(mediaType, pr.substitute(raw))
+	case "urlencoded", "formdata":
+		mediaType = "application/x-www-form-urlencoded"
+		if str(body["mode"]) == "formdata" {
+			mediaType = "multipart/form-data"
+		}
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		values := make(map[string]interface{})
+		for _, item := range list(body[str(body["mode"])]) {
+			field := object(item)
+			name := str(field["key"])
+			if boolean(field["disabled"]) || name == "" {
+				continue
+			}
+			schema.Properties[name] = &Schema{Type: "string", Description: description(field["description"])}
+			if str(field["type"]) == "file" {
+				schema.Properties[name].Format = "binary"
+				continue
+			}
+			if value := example(name, str(field["value"])); value != nil {
+				values[name] = value
+			}
+		}
+		return &RequestBody{Required: true, Content: map[string]*MediaType{mediaType: {Schema: schema, Example: values}}}
+	case "graphql":
+		graphql := object(body["graphql"])
+		mediaType = "application/json"
+		value = map[string]interface{}{"query": str(graphql["query"])}
+		var graphqlVariables interface{}
+		if json.Unmarshal([]byte(str(graphql["variables"])), &graphqlVariables) == nil {
+			value.(map[string]interface{})["variables"] = graphqlVariables
+		}
+	default:
+		return nil
+	}
+	media := exampleMedia(value)
+	if variables {
+		media.Example = nil
+	}
+	return &RequestBody{Required: true, Content: map[string]*MediaType{mediaType: media}}
+}
+
+// response adds the saved response raw to the responses of op, as an example named after it
+func (pr *postmanReader) response(op *Operation, raw map[string]interface{}, index int) {
+	code := number(raw["code"])
+	if code == nil || *code < 100 {
+		return
+	}
+	status := strconv.Itoa(int(*code))
+	contentType := ""
+	for _, item := range list(raw["header"]) {
+		header := object(item)
+		if strings.EqualFold(str(header["key"]), "content-type") {
+			contentType = str(header["value"])
+		}
+	}
+	name := str(raw["name"])
+	if name == "" {
+		name = "example" + strconv.Itoa(index+1)
+	}
+
+	response, ok := op.Responses[status]
+	if !ok {
+		response = &Response{Description: name}
+		op.Responses[status] = response
+	}
+	body := str(raw["body"])
+	if strings.TrimSpace(body) == "" {
+		return
+	}
+	mediaType, example := decodeExample(contentType, body)
+	if response.Content == nil {
+		response.Content = make(map[string]*MediaType)
+	}
+	media, ok := response.Content[mediaType]
+	if !ok {
+		media = exampleMedia(example)
+		response.Content[mediaType] = media
+	}
+	if media.Examples == nil {
+		media.Examples = make(map[string]interface{})
+	}
+	media.Examples[name] = withoutCredentials(example)
+}
+
+// scheme returns the name of the security scheme of a Postman auth, adding the scheme to the
+// document the first time it is seen. It returns an empty name for requests without auth or
+// with an auth type that has no OpenAPI equivalent.
+func (pr *postmanReader) scheme(auth map[string]interface{}) string {
+	authType := str(auth["type"])
+	params := make(map[string]string)
+	for _, item := range list(auth[authType]) {
+		param := object(item)
+		if value, ok := param["value"].(string); ok {
+			params[str(param["key"])] = pr.substitute(value)
+		}
+	}
+
+	var name string
+	var scheme *SecurityScheme
+	switch authType {
+	case "apikey":
+		in := params["in"]
+		if in == "" {
+			in = InHeader
+		}
+		name, scheme = "apiKey", &SecurityScheme{Type: SchemeAPIKey, Name: params["key"], In: in}
+	case "bearer":
+		name, scheme = "bearerAuth", &SecurityScheme{Type: SchemeHTTP, Scheme: "bearer"}
+	case "basic":
+		name, scheme = "basicAuth", &SecurityScheme{Type: SchemeHTTP, Scheme: "basic"}
+	case "oauth2":
+		flows := map[string]string{
+			"client_credentials":   "clientCredentials",
+			"authorization_code":   "authorizationCode",
+			"password_credentials": "password",
+			"implicit":             "implicit",
+		}
+		flow, ok := flows[params["grant_type"]]
+		if !ok {
+			flow = "authorizationCode"
+		}
+		scopes := make(map[string]string)
+		for _, scope := range strings.Fields(params["scope"]) {
+			scopes[scope] = ""
+		}
+		name, scheme = "oauth2", &SecurityScheme{Type: SchemeOAuth2, Flows: map[string]*OAuthFlow{flow: {
+			AuthorizationURL: params["authUrl"],
+			TokenURL:         params["accessTokenUrl"],
+			Scopes:           scopes,
+		}}}
+	default:
+		return ""
+	}
+
+	definition, _ := json.Marshal(scheme)
+	if existing, ok := pr.schemes[string(definition)]; ok {
+		return existing
+	}
+	// schemes of the same type with different settings are numbered
+	base := name
+	for i := 2; pr.doc.SecuritySchemes[name] != nil; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	pr.schemes[string(definition)] = name
+	pr.doc.SecuritySchemes[name] = scheme
+	return name
+}
+
+// operationID derives a unique camel case operation id from the name of a request
+func (pr *postmanReader) operationID(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	id := ""
+	for i, word := range words {
+		first := []rune(word)
+		if i == 0 {
+			first[0] = unicode.ToLower(first[0])
+		} else {
+			first[0] = unicode.ToUpper(first[0])
+		}
+		id += string(first)
+	}
+	if id == "" {
+		id = "operation"
+	}
+	unique := id
+	for i := 2; pr.ids[unique]; i++ {
+		unique = id + strconv.Itoa(i)
+	}
+	pr.ids[unique] = true
+	return unique
+}
+
+// mergeOperation adds the parameters and responses of op missing from existing, for
+// requests of a collection sharing a method and path
+func mergeOperation(existing, op *Operation) {
+	for _, param := range op.Parameters {
+		found := false
+		for _, other := range existing.Parameters {
+			found = found || other.In == param.In && other.Name == param.Name
+		}
+		if !found {
+			existing.Parameters = append(existing.Parameters, param)
+		}
+	}
+	if existing.RequestBody == nil {
+		existing.RequestBody = op.RequestBody
+	}
+	for status, response := range op.Responses {
+		other, ok := existing.Responses[status]
+		if !ok {
+			existing.Responses[status] = response
+			continue
+		}
+		for mediaType, media := range response.Content {
+			if other.Content == nil {
+				other.Content = make(map[string]*MediaType)
+			}
+			otherMedia, ok := other.Content[mediaType]
+			if !ok {
+				other.Content[mediaType] = media
+				continue
+			}
+			for name, example := range media.Examples {
+				if _, ok := otherMedia.Examples[name]; !ok {
+					otherMedia.Examples[name] = example
+				}
+			}
+		}
+	}
+}
+
+// decodeExample returns the media type of a body and its value, decoded when it is JSON.
+// Bodies of unknown type are JSON when they decode as such, and text otherwise.
+func decodeExample(contentType, body string) (string, interface{}) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || contentType == "" {
+		mediaType = ""
+	}
+	if mediaType == "" || isJSON(mediaType) {
+		var value interface{}
+		if err := json.Unmarshal([]byte(body), &value); err == nil {
+			if mediaType == "" {
+				mediaType = "application/json"
+			}
+			return mediaType, value
+		}
+	}
+	if mediaType == "" {
+		mediaType = "text/plain"
+	}
+	return mediaType, body
+}
+
+// exampleMedia returns a media type whose example is value without its credentials, with a
+// schema inferred from value
+func exampleMedia(value interface{}) *MediaType {
+	return &MediaType{Schema: InferSchema(value), Example: withoutCredentials(value)}
+}
+
+// withoutCredentials returns a copy of the decoded JSON value without the properties, at any
+// depth, whose name looks like it holds a credential
+func withoutCredentials(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for name, property := range v {
+			if !isCredential(name) {
+				copied[name] = withoutCredentials(property)
+			}
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = withoutCredentials(item)
+		}
+		return copied
+	}
+	return value
+}
+
+// description reads a Postman description, a string or an object with content
+func description(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return str(object(v)["content"])
+}
@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLoadPostman(t *testing.T) {
+	doc, err := Load("testdata/helpdesk.postman_collection.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "Helpdesk" || len(doc.Servers) != 1 || doc.Servers[0] != "https://helpdesk.example.com/api/v2" {
+		t.Errorf("unexpected document %q %v", doc.Title, doc.Servers)
+	}
+	if key := doc.SecuritySchemes["apiKey"]; key == nil || key.Type != SchemeAPIKey || key.In != InHeader || key.Name != "X-Api-Key" {
+		t.Errorf("expected the collection auth as a scheme, got %+v", doc.SecuritySchemes)
+	}
+	if len(doc.Security) != 1 || !hasScheme(doc.Security[0], "apiKey") {
+		t.Errorf("expected the collection auth as the default security, got %v", doc.Security)
+	}
+
+	list, params := doc.Match("GET", "/tickets")
+	if list == nil || list.ID != "listTickets" || len(list.Tags) != 1 || list.Tags[0] != "Tickets" || list.Security != nil {
+		t.Fatalf("unexpected operation %+v %v", list, params)
+	}
+	var names []string
+	for _, param := range list.Parameters {
+		names = append(names, param.In+":"+param.Name+"="+param.Example.(string))
+	}
+	if len(names) != 3 || names[0] != "query:page=1" || names[1] != "query:status=open" || names[2] != "header:Accept-Language=en" {
+		t.Errorf("expected the enabled query parameters and headers, got %v", names)
+	}
+	media := list.Responses["200"].Content["application/json"]
+	if media.Schema.Type != "array" || media.Schema.Items.Properties["id"].Type != "integer" || media.Examples["Open tickets"] == nil {
+		t.Errorf("expected the saved response as an example, got %+v", media)
+	}
+
+	get, params := doc.Match("GET", "/tickets/7")
+	if get == nil || params["id"] != "7" || get.Parameters[0].Description != "Ticket id" || get.Parameters[0].Example != "7" {
+		t.Fatalf("unexpected operation %+v %v", get, params)
+	}
+	if len(get.Responses) != 2 || get.Responses["404"].Description != "Missing" {
+		t.Errorf("expected a response per saved status, got %v", get.Responses)
+	}
+
+	create := doc.Operation("createTicket")
+	body := create.RequestBody.Content["application/json"]
+	if create.Method != "POST" || body.Schema.Properties["priority"].Type != "integer" || body.Example.(map[string]interface{})["subject"] != "Printer on fire" {
+		t.Errorf("expected the raw body as the example, got %+v", body)
+	}
+
+	if health := doc.Operation("health"); health.Security == nil || len(health.Security) != 0 {
+		t.Errorf("expected no auth, got %v", health.Security)
+	}
+	upload, params := doc.Match("POST", "/tickets/7/attachments")
+	if upload == nil || params["ticketId"] != "7" || len(upload.Security) != 1 || !hasScheme(upload.Security[0], "bearerAuth") {
+		t.Fatalf("unexpected operation %+v %v", upload, params)
+	}
+	form := upload.RequestBody.Content["multipart/form-data"]
+	if form.Schema.Properties["file"].Format != "binary" || form.Example.(map[string]interface{})["comment"] != "see attached" {
+		t.Errorf("unexpected form %+v", form)
+	}
+
+	// the collection renders as an OpenAPI document
+	rendered := doc.OpenAPI()
+	if len(rendered["paths"].(map[string]interface{})) != 4 {
+		t.Errorf("expected 4 paths, got %v", rendered["paths"])
+	}
+}
+
+func TestPostmanExamplesWithoutCredentials(t *testing.T) {
+	var raw map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+  "info": {"name": "Keys", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+  "variable": [{"key": "token", "value": "s3cr3t"}, {"key": "account", "value": "acme"}],
+  "item": [{
+    "name": "Search",
+    "request": {
+      "method": "POST",
+      "header": [{"key": "X-Api-Key", "value": "literal-key"}, {"key": "X-Account", "value": "{{account}}"}, {"key": "X-Trace", "value": "on"}],
+      "url": "https://api.example.com/search?access_token=abc&q=printer",
+      "body": {"mode": "raw", "raw": "{\"token\": \"{{token}}\"}", "options": {"raw": {"language": "json"}}}
+    }
+  }, {
+    "name": "Login",
+    "request": {
+      "method": "POST",
+      "url": "https://api.example.com/login",
+      "body": {"mode": "raw", "raw": "{\"user\": {\"name\": \"alice\", \"password\": \"hunter2\"}, \"api_key\": \"literal-key\"}", "options": {"raw": {"language": "json"}}}
+    },
+    "response": [{"name": "Session", "code": 200, "body": "{\"session\": {\"accessToken\": \"abc\", \"expires\": 3600}}"}]
+  }]
+}`), &raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := readPostman(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := doc.Operation("search")
+	examples := make(map[string]interface{})
+	for _, param := range op.Parameters {
+		examples[param.Name] = param.Example
+	}
+	if examples["X-Api-Key"] != nil || examples["access_token"] != nil || examples["X-Account"] != nil {
+		t.Errorf("expected no example for credentials and variables, got %v", examples)
+	}
+	if examples["X-Trace"] != "on" || examples["q"] != "printer" {
+		t.Errorf("expected literal values as examples, got %v", examples)
+	}
+	body := op.RequestBody.Content["application/json"]
+	if body.Example != nil || body.Schema.Properties["token"] == nil {
+		t.Errorf("expected the body to be described without its example, got %+v", body)
+	}
+
+	// literal credentials are left out of JSON examples but kept in their schema
+	login := doc.Operation("login")
+	body = login.RequestBody.Content["application/json"]
+	example, _ := json.Marshal(body.Example)
+	if string(example) != `{"user":{"name":"alice"}}` || body.Schema.Properties["api_key"] == nil || body.Schema.Properties["user"].Properties["password"] == nil {
+		t.Errorf("expected the body example without credentials, got %s %+v", example, body.Schema)
+	}
+	response := login.Responses["200"].Content["application/json"]
+	if example, _ = json.Marshal(response.Examples["Session"]); string(example) != `{"session":{"expires":3600}}` {
+		t.Errorf("expected the response example without credentials, got %s", example)
+	}
+	if example, _ = json.Marshal(response.Example); string(example) != `{"session":{"expires":3600}}` {
+		t.Errorf("expected the response example without credentials, got %s", example)
+	}
+}
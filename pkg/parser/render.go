@@ -0,0 +1,364 @@
+package parser
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIVersion is the version of the documents rendered by Document.OpenAPI
+const OpenAPIVersion = "3.0.3"
+
+// OpenAPI renders the document as an OpenAPI 3.0 document, ready to be encoded as JSON or
+// YAML. Schemas read from references are rendered once under components/schemas and
+// referred to, so recursive schemas render.
+func (d *Document) OpenAPI() map[string]interface{} {
+	r := newRenderer("#/components/schemas/")
+
+	doc := map[string]interface{}{
+		"openapi": OpenAPIVersion,
+		"info":    map[string]interface{}{"title": d.Title, "version": d.Version},
+	}
+	if len(d.Servers) > 0 {
+		var servers []interface{}
+		for _, server := range d.Servers {
+			servers = append(servers, map[string]interface{}{"url": server})
+		}
+		doc["servers"] = servers
+	}
+
+	paths := make(map[string]interface{})
+	for _, op := range d.Operations {
+		item, ok := paths[op.Path].(map[string]interface{})
+		if !ok {
+			item = make(map[string]interface{})
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = r.operation(op)
+	}
+	doc["paths"] = paths
+
+	components := make(map[string]interface{})
+	if len(d.SecuritySchemes) > 0 {
+		schemes := make(map[string]interface{}, len(d.SecuritySchemes))
+		for name, scheme := range d.SecuritySchemes {
+			schemes[name] = renderSecurityScheme(scheme)
+		}
+		components["securitySchemes"] = schemes
+	}
+	if d.Security != nil {
+		doc["security"] = renderSecurity(d.Security)
+	}
+	if len(r.schemas) > 0 {
+		components["schemas"] = r.schemas
+	}
+	if len(components) > 0 {
+		doc["components"] = components
+	}
+	return doc
+}
+
+// renderer renders schemas, naming those read from references
+type renderer struct {
+	names   map[*Schema]string
+	schemas map[string]interface{}
+	taken   map[string]bool
+	// refs prefixes the names of referenced schemas
+	refs string
+	// jsonSchema renders JSON Schema rather than OpenAPI 3.0 schemas
+	jsonSchema bool
+}
+
+func newRenderer(refs string) *renderer {
+	return &renderer{names: make(map[*Schema]string), schemas: make(map[string]interface{}), taken: make(map[string]bool), refs: refs}
+}
+
+// JSONSchemaRenderer renders schemas as JSON Schema (draft 2020-12), for clients that do not
+// read OpenAPI. Schemas read from references are rendered once, as definitions to place under
+// $defs of the root schema the rendered schemas belong to.
+type JSONSchemaRenderer struct {
+	r *renderer
+}
+
+// NewJSONSchemaRenderer returns a renderer whose references point at $defs of the root schema
+func NewJSONSchemaRenderer() *JSONSchemaRenderer {
+	r := newRenderer("#/$defs/")
+	r.jsonSchema = true
+	return &JSONSchemaRenderer{r}
+}
+
+// Render renders s, an empty schema accepting anything when s is nil
+func (j *JSONSchemaRenderer) Render(s *Schema) map[string]interface{} {
+	return j.r.schema(s)
+}
+
+// Definitions returns the schemas referred to by the rendered ones, by name
+func (j *JSONSchemaRenderer) Definitions() map[string]interface{} {
+	return j.r.schemas
+}
+
+func (r *renderer) operation(op *Operation) map[string]interface{} {
+	m := make(map[string]interface{})
+	set(m, "operationId", op.ID)
+	set(m, "summary", op.Summary)
+	set(m, "description", op.Description)
+	if len(op.Tags) > 0 {
+		m["tags"] = op.Tags
+	}
+	if op.Deprecated {
+		m["deprecated"] = true
+	}
+	if len(op.Parameters) > 0 {
+		var params []interface{}
+		for _, p := range op.Parameters {
+			param := map[string]interface{}{"name": p.Name, "in": p.In}
+			set(param, "description", p.Description)
+			if p.Required {
+				param["required"] = true
+			}
+			if p.Schema != nil {
+				param["schema"] = r.schema(p.Schema)
+			}
+			if p.Example != nil {
+				param["example"] = p.Example
+			}
+			params = append(params, param)
+		}
+		m["parameters"] = params
+	}
+	if op.RequestBody != nil {
+		body := map[string]interface{}{"content": r.content(op.RequestBody.Content)}
+		set(body, "description", op.RequestBody.Description)
+		if op.RequestBody.Required {
+			body["required"] = true
+		}
+		m["requestBody"] = body
+	}
+
+	responses := make(map[string]interface{})
+	for code, response := range op.Responses {
+		rendered := map[string]interface{}{"description": response.Description}
+		if len(response.Headers) > 0 {
+			headers := make(map[string]interface{}, len(response.Headers))
+			for name, schema := range response.Headers {
+				headers[name] = map[string]interface{}{"schema": r.schema(schema)}
+			}
+			rendered["headers"] = headers
+		}
+		if len(response.Content) > 0 {
+			rendered["content"] = r.content(response.Content)
+		}
+		responses[code] = rendered
+	}
+	// documents must describe at least one response
+	if len(responses) == 0 {
+		responses["default"] = map[string]interface{}{"description": "response"}
+	}
+	m["responses"] = responses
+	if op.Security != nil {
+		m["security"] = renderSecurity(op.Security)
+	}
+	return m
+}
+
+func (r *renderer) content(content map[string]*MediaType) map[string]interface{} {
+	m := make(map[string]interface{}, len(content))
+	for name, media := range content {
+		rendered := make(map[string]interface{})
+		if media.Schema != nil {
+			rendered["schema"] = r.schema(media.Schema)
+		}
+		if media.Example != nil {
+			rendered["example"] = media.Example
+		}
+		if len(media.Examples) > 0 {
+			examples := make(map[string]interface{}, len(media.Examples))
+			for exampleName, value := range media.Examples {
+				examples[exampleName] = map[string]interface{}{"value": value}
+			}
+			rendered["examples"] = examples
+		}
+		m[name] = rendered
+	}
+	return m
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// schema renders s inline, or as a reference to components/schemas when it was read from one
+func (r *renderer) schema(s *Schema) map[string]interface{} {
+	if s == nil {
+		return map[string]interface{}{}
+	}
+	if s.Ref == "" {
+		return r.body(s)
+	}
+	name, ok := r.names[s]
+	if !ok {
+		base := unsafeName.ReplaceAllString(s.Ref[strings.LastIndex(s.Ref, "/")+1:], "_")
+		if base == "" {
+			base = "Schema"
+		}
+		name = base
+		for i := 2; r.taken[name]; i++ {
+			name = base + strconv.Itoa(i)
+		}
+		r.names[s], r.taken[name] = name, true
+		// named before it is rendered so references back to it do not recurse
+		r.schemas[name] = r.body(s)
+	}
+	return map[string]interface{}{"$ref": r.refs + name}
+}
+
+func (r *renderer) body(s *Schema) map[string]interface{} {
+	m := make(map[string]interface{})
+	set(m, "type", s.Type)
+	set(m, "format", s.Format)
+	set(m, "title", s.Title)
+	set(m, "description", s.Description)
+	set(m, "pattern", s.Pattern)
+	for key, flag := range map[string]bool{
+		"nullable": s.Nullable, "readOnly": s.ReadOnly, "writeOnly": s.WriteOnly, "uniqueItems": s.UniqueItems,
+		"exclusiveMinimum": s.ExclusiveMinimum, "exclusiveMaximum": s.ExclusiveMaximum,
+	} {
+		if flag {
+			m[key] = true
+		}
+	}
+	for key, value := range map[string]interface{}{"enum": s.Enum, "default": s.Default, "example": s.Example} {
+		if value != nil && !(key == "enum" && len(s.Enum) == 0) {
+			m[key] = value
+		}
+	}
+	for key, value := range map[string]*float64{"minimum": s.Minimum, "maximum": s.Maximum, "multipleOf": s.MultipleOf} {
+		if value != nil {
+			m[key] = *value
+		}
+	}
+	for key, value := range map[string]*int{
+		"minLength": s.MinLength, "maxLength": s.MaxLength, "minItems": s.MinItems, "maxItems": s.MaxItems,
+		"minProperties": s.MinProperties, "maxProperties": s.MaxProperties,
+	} {
+		if value != nil {
+			m[key] = *value
+		}
+	}
+	if s.Items != nil {
+		m["items"] = r.schema(s.Items)
+	}
+	if len(s.Properties) > 0 {
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		properties := make(map[string]interface{}, len(names))
+		for _, name := range names {
+			properties[name] = r.schema(s.Properties[name])
+		}
+		m["properties"] = properties
+	}
+	if len(s.Required) > 0 {
+		m["required"] = s.Required
+	}
+	if s.AdditionalProperties != nil {
+		m["additionalProperties"] = r.schema(s.AdditionalProperties)
+	} else if s.NoAdditionalProperties {
+		m["additionalProperties"] = false
+	}
+	for key, subs := range map[string][]*Schema{"allOf": s.AllOf, "oneOf": s.OneOf, "anyOf": s.AnyOf} {
+		if len(subs) == 0 {
+			continue
+		}
+		var rendered []interface{}
+		for _, sub := range subs {
+			rendered = append(rendered, r.schema(sub))
+		}
+		m[key] = rendered
+	}
+	if s.Not != nil {
+		m["not"] = r.schema(s.Not)
+	}
+	if s.Discriminator != "" {
+		m["discriminator"] = map[string]interface{}{"propertyName": s.Discriminator}
+	}
+	if r.jsonSchema {
+		toJSONSchema(m)
+	}
+	return m
+}
+
+// toJSONSchema replaces the OpenAPI 3.0 keywords of a rendered schema by their JSON Schema
+// equivalents and drops those JSON Schema does not have
+func toJSONSchema(m map[string]interface{}) {
+	if m["nullable"] == true {
+		if t, ok := m["type"].(string); ok {
+			m["type"] = []interface{}{t, "null"}
+		}
+	}
+	for _, bound := range []string{"Minimum", "Maximum"} {
+		exclusive, inclusive := "exclusive"+bound, strings.ToLower(bound)
+		if m[exclusive] == true {
+			if value, ok := m[inclusive]; ok {
+				m[exclusive] = value
+				delete(m, inclusive)
+			} else {
+				delete(m, exclusive)
+			}
+		}
+	}
+	if example, ok := m["example"]; ok {
+		m["examples"] = []interface{}{example}
+	}
+	delete(m, "nullable")
+	delete(m, "example")
+	delete(m, "discriminator")
+}
+
+func renderSecurityScheme(scheme *SecurityScheme) map[string]interface{} {
+	m := map[string]interface{}{"type": scheme.Type}
+	set(m, "description", scheme.Description)
+	set(m, "name", scheme.Name)
+	set(m, "in", scheme.In)
+	set(m, "scheme", scheme.Scheme)
+	set(m, "bearerFormat", scheme.BearerFormat)
+	set(m, "openIdConnectUrl", scheme.OpenIDConnectURL)
+	if len(scheme.Flows) > 0 {
+		flows := make(map[string]interface{}, len(scheme.Flows))
+		for name, flow := range scheme.Flows {
+			rendered := map[string]interface{}{"scopes": flow.Scopes}
+			if flow.Scopes == nil {
+				rendered["scopes"] = map[string]string{}
+			}
+			set(rendered, "authorizationUrl", flow.AuthorizationURL)
+			set(rendered, "tokenUrl", flow.TokenURL)
+			set(rendered, "refreshUrl", flow.RefreshURL)
+			flows[name] = rendered
+		}
+		m["flows"] = flows
+	}
+	return m
+}
+
+func renderSecurity(requirements []SecurityRequirement) []interface{} {
+	rendered := []interface{}{}
+	for _, requirement := range requirements {
+		m := make(map[string]interface{}, len(requirement))
+		for name, scopes := range requirement {
+			if scopes == nil {
+				scopes = []string{}
+			}
+			m[name] = scopes
+		}
+		rendered = append(rendered, m)
+	}
+	return rendered
+}
+
+// set adds a string to m unless it is empty
+func set(m map[string]interface{}, key, value string) {
+	if value != "" {
+		m[key] = value
+	}
+}
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderOpenAPI(t *testing.T) {
+	for _, file := range []string{"testdata/petstore.yaml", "testdata/tickets.yaml"} {
+		doc, err := Load(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rendered := doc.OpenAPI()
+		if rendered["openapi"] != OpenAPIVersion {
+			t.Errorf("%s: unexpected version %v", file, rendered["openapi"])
+		}
+
+		// the rendered document reads back into the same operation table
+		raw, err := json.Marshal(rendered)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		path := filepath.Join(t.TempDir(), "openapi.json")
+		if err := os.WriteFile(path, raw, 0600); err != nil {
+			t.Fatal(err)
+		}
+		again, err := Load(path)
+		if err != nil {
+			t.Fatalf("%s: the rendered document does not load: %v\n%s", file, err, raw)
+		}
+		if again.Title != doc.Title || len(again.Servers) != len(doc.Servers) || len(again.SecuritySchemes) != len(doc.SecuritySchemes) {
+			t.Errorf("%s: unexpected document %+v", file, again)
+		}
+		if len(again.Operations) != len(doc.Operations) {
+			t.Fatalf("%s: expected %d operations, got %d", file, len(doc.Operations), len(again.Operations))
+		}
+		for i, op := range doc.Operations {
+			got := again.Operations[i]
+			if got.Name() != op.Name() || len(got.Parameters) != len(op.Parameters) || len(got.Responses) != len(op.Responses) ||
+				(got.RequestBody == nil) != (op.RequestBody == nil) || len(got.Security) != len(op.Security) {
+				t.Errorf("%s: operation %s does not round trip: %+v", file, op.Name(), got)
+			}
+		}
+	}
+}
+
+func TestRenderReferences(t *testing.T) {
+	doc, err := Load("testdata/tickets.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered := doc.OpenAPI()
+	schemas := rendered["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	if schemas["Ticket"] == nil {
+		t.Fatalf("expected the referenced schema under components, got %v", schemas)
+	}
+	get := rendered["paths"].(map[string]interface{})["/tickets/{id}"].(map[string]interface{})["get"].(map[string]interface{})
+	schema := get["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"]
+	if ref := schema.(map[string]interface{})["$ref"]; ref != "#/components/schemas/Ticket" {
+		t.Errorf("expected a reference to the schema, got %v", schema)
+	}
+}
+
+func TestRenderJSONSchema(t *testing.T) {
+	zero := 0.0
+	node := &Schema{Ref: "#/components/schemas/Node", Type: "object"}
+	node.Properties = map[string]*Schema{
+		"weight":   {Type: "number", Minimum: &zero, ExclusiveMinimum: true, Example: 1.5},
+		"label":    {Type: "string", Nullable: true},
+		"children": {Type: "array", Items: node},
+	}
+
+	renderer := NewJSONSchemaRenderer()
+	rendered := renderer.Render(&Schema{Type: "array", Items: node})
+	if ref := rendered["items"].(map[string]interface{})["$ref"]; ref != "#/$defs/Node" {
+		t.Fatalf("expected a reference to the definitions, got %v", rendered)
+	}
+	defs := renderer.Definitions()
+	properties := defs["Node"].(map[string]interface{})["properties"].(map[string]interface{})
+
+	weight := properties["weight"].(map[string]interface{})
+	if weight["exclusiveMinimum"] != 0.0 || weight["minimum"] != nil || weight["example"] != nil || len(weight["examples"].([]interface{})) != 1 {
+		t.Errorf("unexpected number schema %v", weight)
+	}
+	label := properties["label"].(map[string]interface{})
+	if types, ok := label["type"].([]interface{}); !ok || len(types) != 2 || types[1] != "null" || label["nullable"] != nil {
+		t.Errorf("unexpected nullable schema %v", label)
+	}
+	if ref := properties["children"].(map[string]interface{})["items"].(map[string]interface{})["$ref"]; ref != "#/$defs/Node" {
+		t.Errorf("expected the recursive schema to refer to itself, got %v", ref)
+	}
+}
@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// resolver loads documents and the local files they refer to, and resolves $ref
+// references to their targets. Remote references are not followed.
+type resolver struct {
+	files map[string]interface{}
+}
+
+func newResolver() *resolver {
+	return &resolver{files: make(map[string]interface{})}
+}
+
+// load reads a YAML or JSON file, JSON being a subset of YAML
+func (r *resolver) load(path string) (interface{}, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if doc, ok := r.files[path]; ok {
+		return doc, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	doc = normalize(doc)
+	r.files[path] = doc
+	return doc, nil
+}
+
+// split returns the absolute file and the JSON pointer of a reference made from file
+func (r *resolver) split(ref, file string) (string, string, error) {
+	location, pointer, _ := strings.Cut(ref, "#")
+	if location == "" {
+		return file, pointer, nil
+	}
+	if u, err := url.Parse(location); err == nil && u.Scheme != "" {
+		return "", "", fmt.Errorf("remote reference %s is not supported", ref)
+	}
+	if !filepath.IsAbs(location) {
+		location = filepath.Join(filepath.Dir(file), location)
+	}
+	return location, pointer, nil
+}
+
+// key identifies the target of a reference regardless of the file it is made from
+func (r *resolver) key(ref, file string) string {
+	target, pointer, err := r.split(ref, file)
+	if err != nil {
+		return ref
+	}
+	return target + "#" + pointer
+}
+
+// resolve returns the target of ref, made from file, and the file the target is in
+func (r *resolver) resolve(ref, file string) (interface{}, string, error) {
+	target, pointer, err := r.split(ref, file)
+	if err != nil {
+		return nil, "", err
+	}
+	doc, err := r.load(target)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to resolve %s: %w", ref, err)
+	}
+	target, _ = filepath.Abs(target)
+	value, err := lookup(doc, pointer)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to resolve %s: %w", ref, err)
+	}
+	return value, target, nil
+}
+
+// deref follows the $ref of a parameter, response or other object until it reaches an
+// object without one
+func (r *resolver) deref(raw interface{}, file string) (map[string]interface{}, string, error) {
+	seen := make(map[string]bool)
+	for {
+		m := object(raw)
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m, file, nil
+		}
+		key := r.key(ref, file)
+		if seen[key] {
+			return nil, "", fmt.Errorf("circular reference %s", ref)
+		}
+		seen[key] = true
+		var err error
+		if raw, file, err = r.resolve(ref, file); err != nil {
+			return nil, "", err
+		}
+	}
+}
+
+// lookup returns the value at a JSON pointer such as /definitions/Pet
+func lookup(doc interface{}, pointer string) (interface{}, error) {
+	if pointer == "" || pointer == "/" {
+		return doc, nil
+	}
+	value := doc
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token, _ = url.PathUnescape(token)
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = v[token]; !ok {
+				return nil, fmt.Errorf("%s not found", pointer)
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("%s not found", pointer)
+			}
+			value = v[i]
+		default:
+			return nil, fmt.Errorf("%s not found", pointer)
+		}
+	}
+	return value, nil
+}
+
+// normalize turns YAML maps with non string keys, such as unquoted status codes, into
+// maps keyed by strings
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = normalize(item)
+		}
+		return value
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[fmt.Sprint(k)] = normalize(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalize(item)
+		}
+		return value
+	}
+	return v
+}
@@ -0,0 +1,277 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mock is a response of an operation built from its description
+type Mock struct {
+	Status      int
+	ContentType string
+	Headers     map[string]string
+	// Body is nil when the response has no content
+	Body interface{}
+}
+
+// Mock builds the response of op with the given status, or its first success response when
+// status is 0, from the examples of the description or from a sample of the response schema.
+// example names the example to use among those of the response, the first one by default.
+func (op *Operation) Mock(status int, example string) (*Mock, error) {
+	code, response := op.mockResponse(status)
+	if response == nil {
+		return nil, fmt.Errorf("operation %s does not describe a %d response", op.Name(), status)
+	}
+	mock := &Mock{Status: code}
+	if len(response.Headers) > 0 {
+		mock.Headers = make(map[string]string, len(response.Headers))
+		for name, schema := range response.Headers {
+			if value := schema.Sample(); value != nil {
+				mock.Headers[name] = fmt.Sprint(value)
+			}
+		}
+	}
+
+	mediaType, media := mockMediaType(response.Content)
+	if media == nil {
+		if example != "" {
+			return nil, fmt.Errorf("response %d of operation %s has no example %q", code, op.Name(), example)
+		}
+		return mock, nil
+	}
+	mock.ContentType = mediaType
+	switch {
+	case example != "":
+		value, ok := media.Examples[example]
+		if !ok {
+			return nil, fmt.Errorf("response %d of operation %s has no example %q", code, op.Name(), example)
+		}
+		mock.Body = value
+	case media.Example != nil:
+		mock.Body = media.Example
+	case len(media.Examples) > 0:
+		names := make([]string, 0, len(media.Examples))
+		for name := range media.Examples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		mock.Body = media.Examples[names[0]]
+	default:
+		mock.Body = media.Schema.Sample()
+	}
+	return mock, nil
+}
+
+// mockResponse returns the response of op for status. When status is 0, it is the first
+// success response, or the first response, with ranges such as 2XX answered with their
+// first code and default with 200.
+func (op *Operation) mockResponse(status int) (int, *Response) {
+	if status != 0 {
+		return status, op.Response(status)
+	}
+	if len(op.Responses) == 0 {
+		return 200, &Response{}
+	}
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	// numeric codes sort before ranges, and ranges before default
+	sort.Strings(codes)
+	code := codes[0]
+	for _, candidate := range codes {
+		if strings.HasPrefix(candidate, "2") {
+			code = candidate
+			break
+		}
+	}
+	status, err := strconv.Atoi(code)
+	if err != nil {
+		status = 200
+		if digit, err := strconv.Atoi(code[:1]); err == nil && len(code) == 3 {
+			status = digit * 100
+		}
+	}
+	return status, op.Responses[code]
+}
+
+// mockMediaType returns the JSON media type of content, or its first media type
+func mockMediaType(content map[string]*MediaType) (string, *MediaType) {
+	names := make([]string, 0, len(content))
+	for name := range content {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if mediaType, _, err := mime.ParseMediaType(name); err == nil && isJSON(mediaType) {
+			return name, content[name]
+		}
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	name := names[0]
+	if strings.Contains(name, "*") {
+		// wildcards describe any content, which is answered as JSON
+		return "application/json", content[name]
+	}
+	return name, content[name]
+}
+
+// Sample returns a value of the schema for a response: its example, default or first enum
+// value, or a value generated from its type and constraints. Write-only properties are left
+// out and recursive schemas stop at their first repetition.
+func (s *Schema) Sample() interface{} {
+	return s.sample(make(map[*Schema]bool))
+}
+
+func (s *Schema) sample(path map[*Schema]bool) interface{} {
+	if s == nil || path[s] {
+		return nil
+	}
+	switch {
+	case s.Example != nil:
+		return s.Example
+	case s.Default != nil:
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	}
+	path[s] = true
+	defer delete(path, s)
+
+	switch {
+	case len(s.AllOf) > 0:
+		merged := make(map[string]interface{})
+		for _, sub := range s.AllOf {
+			value := sub.sample(path)
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				// composition of non objects, the first value stands for all of them
+				return value
+			}
+			for name, v := range object {
+				merged[name] = v
+			}
+		}
+		if object, ok := s.sampleObject(path).(map[string]interface{}); ok {
+			for name, v := range object {
+				merged[name] = v
+			}
+		}
+		return merged
+	case len(s.OneOf) > 0:
+		return s.OneOf[0].sample(path)
+	case len(s.AnyOf) > 0:
+		return s.AnyOf[0].sample(path)
+	}
+
+	switch s.Type {
+	case "object":
+		return s.sampleObject(path)
+	case "array":
+		items := []interface{}{}
+		if item := s.Items.sample(path); item != nil {
+			count := 1
+			if s.MinItems != nil && *s.MinItems > count {
+				count = *s.MinItems
+			}
+			if s.MaxItems != nil && *s.MaxItems < count {
+				count = *s.MaxItems
+			}
+			for i := 0; i < count; i++ {
+				items = append(items, item)
+			}
+		}
+		return items
+	case "string":
+		return s.sampleString()
+	case "integer":
+		// numbers are float64 as in decoded JSON
+		return math.Ceil(s.sampleNumber(1))
+	case "number":
+		return s.sampleNumber(0.5)
+	case "boolean":
+		return true
+	case "":
+		if len(s.Properties) > 0 || s.AdditionalProperties != nil {
+			return s.sampleObject(path)
+		}
+		if s.Items != nil {
+			return []interface{}{}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) sampleObject(path map[*Schema]bool) interface{} {
+	object := make(map[string]interface{})
+	for name, property := range s.Properties {
+		if property.WriteOnly {
+			continue
+		}
+		if value := property.sample(path); value != nil || property.Nullable {
+			object[name] = value
+		}
+	}
+	if len(s.Properties) == 0 && s.AdditionalProperties != nil {
+		if value := s.AdditionalProperties.sample(path); value != nil {
+			object["additionalProp1"] = value
+		}
+	}
+	return object
+}
+
+// sampleStrings are samples of string formats, which are valid for their format
+var sampleStrings = map[string]string{
+	"date-time": "2024-01-01T00:00:00Z",
+	"date":      "2024-01-01",
+	"email":     "user@example.com",
+	"uuid":      "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+	"uri":       "https://example.com",
+	"hostname":  "example.com",
+	"ipv4":      "192.0.2.1",
+	"ipv6":      "2001:db8::1",
+	"byte":      "c3RyaW5n",
+	"password":  "********",
+}
+
+func (s *Schema) sampleString() string {
+	value, ok := sampleStrings[s.Format]
+	if !ok {
+		value = "string"
+	}
+	if s.MinLength != nil && len(value) < *s.MinLength {
+		value += strings.Repeat("x", *s.MinLength-len(value))
+	}
+	if s.MaxLength != nil && len(value) > *s.MaxLength {
+		value = value[:*s.MaxLength]
+	}
+	return value
+}
+
+// sampleNumber returns 0, or the closest value to it within the bounds of the schema, step
+// being the distance kept from exclusive bounds
+func (s *Schema) sampleNumber(step float64) float64 {
+	value := 0.0
+	if s.Minimum != nil && value <= *s.Minimum {
+		value = *s.Minimum
+		if s.ExclusiveMinimum {
+			value += step
+		}
+	}
+	if s.Maximum != nil && value >= *s.Maximum {
+		value = *s.Maximum
+		if s.ExclusiveMaximum {
+			value -= step
+		}
+	}
+	if s.MultipleOf != nil && *s.MultipleOf > 0 {
+		value = math.Ceil(value / *s.MultipleOf) * *s.MultipleOf
+	}
+	return value
+}
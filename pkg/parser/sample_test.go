@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMock(t *testing.T) {
+	doc, err := Load("testdata/tickets.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// examples of the document come first
+	mock, err := doc.Operation("getTicket").Mock(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mock.Status != 200 || mock.ContentType != "application/json" || mock.Body.(map[string]interface{})["subject"] != "Printer on fire" {
+		t.Errorf("unexpected mock %+v", mock)
+	}
+
+	// then samples of the schema, which match it
+	create := doc.Operation("createTicket")
+	mock, err = create.Mock(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"id": 0.0, "subject": "string", "status": "open"}
+	if mock.Status != 201 || !reflect.DeepEqual(mock.Body, expected) || mock.Headers["Location"] != "string" {
+		t.Errorf("unexpected mock %+v", mock)
+	}
+	if violations := create.ValidateResponse(201, mock.ContentType, mock.Body); len(violations) > 0 {
+		t.Errorf("expected the sample to match the schema, got %v", violations)
+	}
+
+	if _, err := create.Mock(418, ""); err == nil {
+		t.Error("expected an undescribed status to be rejected")
+	}
+	if _, err := create.Mock(0, "missing"); err == nil {
+		t.Error("expected an unknown example to be rejected")
+	}
+}
+
+func TestSample(t *testing.T) {
+	minimum, maximum, length := 10.0, 20.0, 12
+	for _, c := range []struct {
+		schema   *Schema
+		expected interface{}
+	}{
+		{&Schema{Type: "integer", Minimum: &minimum, ExclusiveMinimum: true}, 11.0},
+		{&Schema{Type: "number", Maximum: &maximum}, 0.0},
+		{&Schema{Type: "string", Format: "email", MaxLength: &length}, "user@example"},
+		{&Schema{Type: "string", Default: "fast"}, "fast"},
+		{&Schema{Type: "array", Items: &Schema{Type: "boolean"}}, []interface{}{true}},
+		{&Schema{Type: "object", Properties: map[string]*Schema{
+			"password": {Type: "string", WriteOnly: true},
+			"name":     {Type: "string"},
+		}}, map[string]interface{}{"name": "string"}},
+		{&Schema{AllOf: []*Schema{
+			{Type: "object", Properties: map[string]*Schema{"id": {Type: "integer"}}},
+			{Type: "object", Properties: map[string]*Schema{"name": {Type: "string"}}},
+		}}, map[string]interface{}{"id": 0.0, "name": "string"}},
+		{&Schema{OneOf: []*Schema{{Type: "boolean"}, {Type: "string"}}}, true},
+	} {
+		if got := c.schema.Sample(); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%+v: expected %#v, got %#v", c.schema, c.expected, got)
+		}
+	}
+
+	// recursive schemas stop at their first repetition
+	node := &Schema{Type: "object", Properties: map[string]*Schema{"name": {Type: "string"}}}
+	node.Properties["children"] = &Schema{Type: "array", Items: node}
+	expected := map[string]interface{}{"name": "string", "children": []interface{}{}}
+	if got := node.Sample(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,235 @@
+package parser
+
+import "regexp"
+
+// Schema is the subset of JSON Schema shared by Swagger 2.0 and OpenAPI 3 documents.
+// References are resolved, a schema referring to itself points back to the same Schema.
+type Schema struct {
+	// Ref is the reference the schema was resolved from, e.g. #/definitions/Pet
+	Ref         string
+	Title       string
+	Description string
+	Type        string
+	Format      string
+	Nullable    bool
+	ReadOnly    bool
+	WriteOnly   bool
+	Enum        []interface{}
+	Default     interface{}
+	Example     interface{}
+
+	// numbers
+	Minimum          *float64
+	Maximum          *float64
+	ExclusiveMinimum bool
+	ExclusiveMaximum bool
+	MultipleOf       *float64
+
+	// strings
+	MinLength *int
+	MaxLength *int
+	Pattern   string
+
+	// arrays
+	Items       *Schema
+	MinItems    *int
+	MaxItems    *int
+	UniqueItems bool
+
+	// objects
+	Properties map[string]*Schema
+	Required   []string
+	// AdditionalProperties is the schema of properties not listed in Properties, nil when
+	// any value is allowed, see NoAdditionalProperties
+	AdditionalProperties   *Schema
+	NoAdditionalProperties bool
+	MinProperties          *int
+	MaxProperties          *int
+
+	AllOf []*Schema
+	OneOf []*Schema
+	AnyOf []*Schema
+	Not   *Schema
+	// Discriminator is the property telling oneOf and anyOf alternatives apart
+	Discriminator string
+
+	pattern *regexp.Regexp
+}
+
+// schemaReader builds schemas from their raw form, resolving references once so recursive
+// schemas do not loop
+type schemaReader struct {
+	resolver *resolver
+	byRef    map[string]*Schema
+}
+
+func newSchemaReader(r *resolver) *schemaReader {
+	return &schemaReader{resolver: r, byRef: make(map[string]*Schema)}
+}
+
+// read returns the schema described by raw, found in file. It returns nil for a nil raw.
+func (sr *schemaReader) read(raw interface{}, file string) (*Schema, error) {
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	if ref, ok := m["$ref"].(string); ok {
+		key := sr.resolver.key(ref, file)
+		if s, ok := sr.byRef[key]; ok {
+			return s, nil
+		}
+		target, targetFile, err := sr.resolver.resolve(ref, file)
+		if err != nil {
+			return nil, err
+		}
+		// registered before it is filled so references back to it resolve to it
+		s := &Schema{Ref: ref}
+		sr.byRef[key] = s
+		return s, sr.fill(s, target, targetFile)
+	}
+	s := &Schema{}
+	return s, sr.fill(s, m, file)
+}
+
+func (sr *schemaReader) fill(s *Schema, raw interface{}, file string) error {
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	s.Title = str(m["title"])
+	s.Description = str(m["description"])
+	s.Format = str(m["format"])
+	s.Pattern = str(m["pattern"])
+	if s.Pattern != "" {
+		// patterns Go cannot compile, such as those with lookaheads, are not checked
+		s.pattern, _ = regexp.Compile(s.Pattern)
+	}
+	s.Enum, _ = m["enum"].([]interface{})
+	s.Default = m["default"]
+	s.Example = m["example"]
+	s.ReadOnly = boolean(m["readOnly"])
+	s.WriteOnly = boolean(m["writeOnly"])
+	s.Nullable = boolean(m["nullable"]) || boolean(m["x-nullable"])
+	s.UniqueItems = boolean(m["uniqueItems"])
+
+	switch t := m["type"].(type) {
+	case string:
+		s.Type = t
+	case []interface{}:
+		// JSON Schema type lists, "null" makes the schema nullable
+		for _, item := range t {
+			if item == "null" {
+				s.Nullable = true
+			} else if s.Type == "" {
+				s.Type = str(item)
+			}
+		}
+	}
+
+	s.Minimum, s.Maximum, s.MultipleOf = number(m["minimum"]), number(m["maximum"]), number(m["multipleOf"])
+	// OpenAPI 3.1 uses numeric exclusive bounds, earlier versions boolean flags
+	if v := number(m["exclusiveMinimum"]); v != nil {
+		s.Minimum, s.ExclusiveMinimum = v, true
+	} else {
+		s.ExclusiveMinimum = boolean(m["exclusiveMinimum"])
+	}
+	if v := number(m["exclusiveMaximum"]); v != nil {
+		s.Maximum, s.ExclusiveMaximum = v, true
+	} else {
+		s.ExclusiveMaximum = boolean(m["exclusiveMaximum"])
+	}
+	s.MinLength, s.MaxLength = integer(m["minLength"]), integer(m["maxLength"])
+	s.MinItems, s.MaxItems = integer(m["minItems"]), integer(m["maxItems"])
+	s.MinProperties, s.MaxProperties = integer(m["minProperties"]), integer(m["maxProperties"])
+	for _, name := range list(m["required"]) {
+		s.Required = append(s.Required, str(name))
+	}
+
+	var err error
+	if s.Items, err = sr.read(m["items"], file); err != nil {
+		return err
+	}
+	if s.Not, err = sr.read(m["not"], file); err != nil {
+		return err
+	}
+	switch additional := m["additionalProperties"].(type) {
+	case bool:
+		s.NoAdditionalProperties = !additional
+	case map[string]interface{}:
+		if s.AdditionalProperties, err = sr.read(additional, file); err != nil {
+			return err
+		}
+	}
+	if properties, ok := m["properties"].(map[string]interface{}); ok {
+		s.Properties = make(map[string]*Schema, len(properties))
+		for name, raw := range properties {
+			if s.Properties[name], err = sr.read(raw, file); err != nil {
+				return err
+			}
+		}
+	}
+	for _, composition := range []struct {
+		key    string
+		target *[]*Schema
+	}{{"allOf", &s.AllOf}, {"oneOf", &s.OneOf}, {"anyOf", &s.AnyOf}} {
+		for _, raw := range list(m[composition.key]) {
+			sub, err := sr.read(raw, file)
+			if err != nil {
+				return err
+			}
+			*composition.target = append(*composition.target, sub)
+		}
+	}
+	switch discriminator := m["discriminator"].(type) {
+	case string:
+		s.Discriminator = discriminator
+	case map[string]interface{}:
+		s.Discriminator = str(discriminator["propertyName"])
+	}
+	return nil
+}
+
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func boolean(v interface{}) bool {
+	b, _ := v.(bool)
+	return b
+}
+
+func list(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+func object(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func number(v interface{}) *float64 {
+	var f float64
+	switch n := v.(type) {
+	case float64:
+		f = n
+	case int:
+		f = float64(n)
+	case int64:
+		f = float64(n)
+	case uint64:
+		f = float64(n)
+	default:
+		return nil
+	}
+	return &f
+}
+
+func integer(v interface{}) *int {
+	if f := number(v); f != nil {
+		i := int(*f)
+		return &i
+	}
+	return nil
+}
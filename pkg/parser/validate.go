@@ -0,0 +1,487 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Violation is a value that does not match the API description
+type Violation struct {
+	// In is where the value is: path, query, header, cookie or body
+	In string `json:"in"`
+	// Name is the parameter name, followed by the JSON pointer of the value within it, e.g.
+	// /items/0/sku for a body
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %s %s", v.In, v.Name, v.Message)
+}
+
+// Request holds the parts of a request that are validated against an operation
+type Request struct {
+	PathParams map[string]string
+	Query      url.Values
+	Header     http.Header
+	// Body is the decoded JSON body, HasBody tells an absent body from a JSON null
+	Body    interface{}
+	HasBody bool
+}
+
+// direction tells which of readOnly and writeOnly properties are left out
+type direction int
+
+const (
+	anyDirection direction = iota
+	requestDirection
+	responseDirection
+)
+
+// Validate returns the violations of value, decoded from JSON, against the schema. The
+// names of the violations are JSON pointers within value.
+func (s *Schema) Validate(value interface{}) []Violation {
+	return s.validate(value, "", anyDirection, 0)
+}
+
+// ValidateRequest returns the violations of the parameters and JSON body of req against
+// the operation. Parameters are converted to the type of their schema, query arrays are
+// read from repeated or comma separated values.
+func (op *Operation) ValidateRequest(req Request) []Violation {
+	var violations []Violation
+	for _, p := range op.Parameters {
+		var values []string
+		switch p.In {
+		case InPath:
+			if value, ok := req.PathParams[p.Name]; ok {
+				values = []string{value}
+			}
+		case InQuery:
+			values = req.Query[p.Name]
+		case InHeader:
+			values = req.Header.Values(p.Name)
+		case InCookie:
+			if cookie, err := (&http.Request{Header: req.Header}).Cookie(p.Name); err == nil {
+				values = []string{cookie.Value}
+			}
+		}
+		if len(values) == 0 {
+			if p.Required {
+				violations = append(violations, Violation{In: p.In, Name: p.Name, Message: "is required"})
+			}
+			continue
+		}
+		value, err := coerce(p.Schema, values)
+		if err != nil {
+			violations = append(violations, Violation{In: p.In, Name: p.Name, Message: err.Error()})
+			continue
+		}
+		for _, violation := range p.Schema.validate(value, "", requestDirection, 0) {
+			violation.In, violation.Name = p.In, p.Name+violation.Name
+			violations = append(violations, violation)
+		}
+	}
+
+	if op.RequestBody != nil {
+		if !req.HasBody {
+			if op.RequestBody.Required {
+				violations = append(violations, Violation{In: "body", Message: "is required"})
+			}
+		} else if media := MediaTypeFor(op.RequestBody.Content, req.Header.Get("Content-Type")); media != nil {
+			for _, violation := range media.Schema.validate(req.Body, "", requestDirection, 0) {
+				violation.In = "body"
+				violations = append(violations, violation)
+			}
+		}
+	}
+	return violations
+}
+
+// ValidateResponse returns the violations of a JSON response body against the response
+// the operation describes for status, nil when it describes none
+func (op *Operation) ValidateResponse(status int, contentType string, body interface{}) []Violation {
+	response := op.Response(status)
+	if response == nil {
+		return nil
+	}
+	media := MediaTypeFor(response.Content, contentType)
+	if media == nil {
+		return nil
+	}
+	violations := media.Schema.validate(body, "", responseDirection, 0)
+	for i := range violations {
+		violations[i].In = "body"
+	}
+	return violations
+}
+
+// Response returns the response described for status: the exact code, then the range
+// such as 2XX, then the default response
+func (op *Operation) Response(status int) *Response {
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx", "default"} {
+		if response, ok := op.Responses[key]; ok {
+			return response
+		}
+	}
+	return nil
+}
+
+// MediaTypeFor returns the JSON media type of content matching contentType, falling back
+// to wildcards and to application/json when contentType is empty. It returns nil when the
+// body is not JSON or content does not describe it.
+func MediaTypeFor(content map[string]*MediaType, contentType string) *MediaType {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || contentType == "" {
+		mediaType = "application/json"
+	}
+	if !isJSON(mediaType) {
+		return nil
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, candidate := range []string{mediaType, major + "/*", "*/*"} {
+		if media, ok := content[candidate]; ok && media.Schema != nil {
+			return media
+		}
+	}
+	return nil
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// coerce converts parameter values to the type of their schema
+func coerce(s *Schema, values []string) (interface{}, error) {
+	if s == nil {
+		return values[0], nil
+	}
+	if s.Type == "array" {
+		var items []string
+		for _, value := range values {
+			items = append(items, strings.Split(value, ",")...)
+		}
+		array := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			value, err := coerceScalar(s.Items, item)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		return array, nil
+	}
+	return coerceScalar(s, values[0])
+}
+
+func coerceScalar(s *Schema, value string) (interface{}, error) {
+	if s == nil {
+		return value, nil
+	}
+	switch s.Type {
+	case "integer", "number":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be %s, got %q", article(s.Type), value)
+		}
+		return f, nil
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be a boolean, got %q", value)
+		}
+		return b, nil
+	}
+	return value, nil
+}
+
+// maxDepth stops validating values nested deeper than any sensible document
+const maxDepth = 64
+
+func (s *Schema) validate(value interface{}, pointer string, dir direction, depth int) []Violation {
+	if s == nil || depth > maxDepth {
+		return nil
+	}
+	var violations []Violation
+	fail := func(format string, args ...interface{}) []Violation {
+		return append(violations, Violation{Name: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return fail("must not be null")
+	}
+
+	if s.Type != "" && !hasType(value, s.Type) {
+		return fail("must be %s, got %s", article(s.Type), typeOf(value))
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if equal(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			violations = fail("must be one of %s", enumList(s.Enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		violations = append(violations, s.validateNumber(v, pointer)...)
+	case string:
+		violations = append(violations, s.validateString(v, pointer)...)
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			violations = fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			violations = fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.UniqueItems {
+			for i := range v {
+				for j := 0; j < i; j++ {
+					if equal(v[i], v[j]) {
+						violations = fail("must not have duplicate items, %d and %d are equal", j, i)
+					}
+				}
+			}
+		}
+		for i, item := range v {
+			violations = append(violations, s.Items.validate(item, pointer+"/"+strconv.Itoa(i), dir, depth+1)...)
+		}
+	case map[string]interface{}:
+		violations = append(violations, s.validateObject(v, pointer, dir, depth)...)
+	}
+
+	for _, sub := range s.AllOf {
+		violations = append(violations, sub.validate(value, pointer, dir, depth+1)...)
+	}
+	if len(s.AnyOf) > 0 {
+		matched := false
+		for _, sub := range s.AnyOf {
+			if len(sub.validate(value, pointer, dir, depth+1)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			violations = fail("must match at least one of the anyOf schemas")
+		}
+	}
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, sub := range s.OneOf {
+			if len(sub.validate(value, pointer, dir, depth+1)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			violations = fail("must match exactly one of the oneOf schemas, matches %d", matches)
+		}
+	}
+	if s.Not != nil && len(s.Not.validate(value, pointer, dir, depth+1)) == 0 {
+		violations = fail("must not match the not schema")
+	}
+	return violations
+}
+
+func (s *Schema) validateNumber(v float64, pointer string) []Violation {
+	var violations []Violation
+	fail := func(format string, args ...interface{}) {
+		violations = append(violations, Violation{Name: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.Minimum != nil {
+		if s.ExclusiveMinimum && v <= *s.Minimum {
+			fail("must be greater than %v", *s.Minimum)
+		} else if v < *s.Minimum {
+			fail("must be at least %v", *s.Minimum)
+		}
+	}
+	if s.Maximum != nil {
+		if s.ExclusiveMaximum && v >= *s.Maximum {
+			fail("must be less than %v", *s.Maximum)
+		} else if v > *s.Maximum {
+			fail("must be at most %v", *s.Maximum)
+		}
+	}
+	if s.MultipleOf != nil && *s.MultipleOf > 0 {
+		if q := v / *s.MultipleOf; math.Abs(q-math.Round(q)) > 1e-9 {
+			fail("must be a multiple of %v", *s.MultipleOf)
+		}
+	}
+	switch s.Format {
+	case "int32":
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			fail("must be a 32 bit integer")
+		}
+	}
+	return violations
+}
+
+func (s *Schema) validateString(v string, pointer string) []Violation {
+	var violations []Violation
+	fail := func(format string, args ...interface{}) {
+		violations = append(violations, Violation{Name: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+	length := utf8.RuneCountInString(v)
+	if s.MinLength != nil && length < *s.MinLength {
+		fail("must be at least %d characters long", *s.MinLength)
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		fail("must be at most %d characters long", *s.MaxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(v) {
+		fail("must match the pattern %s", s.Pattern)
+	}
+	if check, ok := formats[s.Format]; ok && !check(v) {
+		fail("must be a valid %s", s.Format)
+	}
+	return violations
+}
+
+func (s *Schema) validateObject(v map[string]interface{}, pointer string, dir direction, depth int) []Violation {
+	var violations []Violation
+	fail := func(name, format string, args ...interface{}) {
+		violations = append(violations, Violation{Name: name, Message: fmt.Sprintf(format, args...)})
+	}
+	for _, name := range s.Required {
+		if _, ok := v[name]; ok {
+			continue
+		}
+		// read-only properties are not sent in requests and write-only ones not returned
+		if property := s.Properties[name]; property != nil &&
+			(dir == requestDirection && property.ReadOnly || dir == responseDirection && property.WriteOnly) {
+			continue
+		}
+		fail(pointer+"/"+escape(name), "is required")
+	}
+	if s.MinProperties != nil && len(v) < *s.MinProperties {
+		fail(pointer, "must have at least %d properties", *s.MinProperties)
+	}
+	if s.MaxProperties != nil && len(v) > *s.MaxProperties {
+		fail(pointer, "must have at most %d properties", *s.MaxProperties)
+	}
+
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child := pointer + "/" + escape(name)
+		if property, ok := s.Properties[name]; ok {
+			violations = append(violations, property.validate(v[name], child, dir, depth+1)...)
+		} else if s.AdditionalProperties != nil {
+			violations = append(violations, s.AdditionalProperties.validate(v[name], child, dir, depth+1)...)
+		} else if s.NoAdditionalProperties {
+			fail(child, "is not allowed")
+		}
+	}
+	return violations
+}
+
+// formats checks the string formats most APIs use, other formats are not checked
+var formats = map[string]func(string) bool{
+	"date-time": func(v string) bool {
+		_, err := time.Parse(time.RFC3339, v)
+		return err == nil
+	},
+	"date": func(v string) bool {
+		_, err := time.Parse("2006-01-02", v)
+		return err == nil
+	},
+	"email": func(v string) bool {
+		_, err := mail.ParseAddress(v)
+		return err == nil
+	},
+	"uuid": regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+	"ipv4": func(v string) bool {
+		ip := net.ParseIP(v)
+		return ip != nil && ip.To4() != nil && !strings.Contains(v, ":")
+	},
+	"ipv6": func(v string) bool {
+		return net.ParseIP(v) != nil && strings.Contains(v, ":")
+	},
+	"uri": func(v string) bool {
+		u, err := url.Parse(v)
+		return err == nil && u.Scheme != ""
+	},
+}
+
+func hasType(value interface{}, t string) bool {
+	switch v := value.(type) {
+	case float64:
+		return t == "number" || t == "integer" && v == math.Trunc(v)
+	case string:
+		return t == "string"
+	case bool:
+		return t == "boolean"
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	}
+	return false
+}
+
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func article(t string) string {
+	switch t {
+	case "integer", "object", "array":
+		return "an " + t
+	}
+	return "a " + t
+}
+
+// equal compares JSON values, numbers read from YAML documents being ints
+func equal(a, b interface{}) bool {
+	if x, y := number(a), number(b); x != nil && y != nil {
+		return *x == *y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func enumList(values []interface{}) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = fmt.Sprint(v)
+	}
+	return strings.Join(s, ", ")
+}
+
+// escape escapes a property name for use in a JSON pointer
+func escape(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
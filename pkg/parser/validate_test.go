@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	doc, err := Load("testdata/tickets.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket := doc.Operation("getTicket").Responses["200"].Content["application/json"].Schema
+
+	tests := []struct {
+		body     string
+		expected []string
+	}{
+		{`{"id": 1, "subject": "Printer on fire", "status": "open"}`, nil},
+		{`{"id": 1.5, "subject": "", "status": "pending"}`, []string{"/id must be an integer, got a number", "/status must be one of open, closed"}},
+		{`{"status": null}`, []string{"/subject is required", "/status must not be null"}},
+		{`{"subject": "` + strings.Repeat("a", 256) + `"}`, []string{"/subject must be at most 255 characters long"}},
+		{`[]`, []string{" must be an object, got an array"}},
+	}
+	for _, test := range tests {
+		var value interface{}
+		if err := json.Unmarshal([]byte(test.body), &value); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, violation := range ticket.Validate(value) {
+			got = append(got, violation.Name+" "+violation.Message)
+		}
+		if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+			t.Errorf("%s: expected %q, got %q", test.body, test.expected, got)
+		}
+	}
+
+	min, max := 2, 3
+	tags := &Schema{Type: "array", MinItems: &min, MaxItems: &max, UniqueItems: true, Items: &Schema{Type: "string", Format: "email"}}
+	if violations := tags.Validate([]interface{}{"a@example.com", "a@example.com", "nope"}); len(violations) != 2 {
+		t.Errorf("expected duplicate and format violations, got %v", violations)
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	doc, err := Load("testdata/tickets.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := doc.Operation("getTicket")
+	violations := get.ValidateRequest(Request{PathParams: map[string]string{"id": "abc"}, Query: url.Values{"fields": {"subject,status"}}})
+	if len(violations) != 1 || violations[0].In != InPath || violations[0].Name != "id" {
+		t.Errorf("expected the id to be rejected, got %v", violations)
+	}
+	if violations := get.ValidateRequest(Request{PathParams: map[string]string{"id": "7"}}); len(violations) != 0 {
+		t.Errorf("expected a valid request, got %v", violations)
+	}
+
+	create := doc.Operation("createTicket")
+	header := http.Header{"Content-Type": {"application/json; charset=utf-8"}}
+	if violations := create.ValidateRequest(Request{Header: header}); len(violations) != 1 || violations[0].Message != "is required" {
+		t.Errorf("expected the body to be required, got %v", violations)
+	}
+	// read-only properties are not required in requests
+	body := map[string]interface{}{"subject": "Printer on fire"}
+	if violations := create.ValidateRequest(Request{Header: header, Body: body, HasBody: true}); len(violations) != 0 {
+		t.Errorf("expected a valid body, got %v", violations)
+	}
+	// bodies of other media types are not validated
+	if violations := create.ValidateRequest(Request{Header: http.Header{"Content-Type": {"text/csv"}}, Body: "a,b", HasBody: true}); len(violations) != 0 {
+		t.Errorf("expected a csv body not to be validated, got %v", violations)
+	}
+}
@@ -0,0 +1,192 @@
+// Package quota enforces per caller request quotas over sliding windows and keeps
+// usage accounting for every caller.
+package quota
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Result describes the outcome of a quota check
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Usage reports the consumption of a single caller
+type Usage struct {
+	Caller   string `json:"caller"`
+	Limit    int    `json:"limit"`
+	Used     int    `json:"used"`
+	Total    int64  `json:"total"`
+	Rejected int64  `json:"rejected"`
+}
+
+type counter struct {
+	windowStart time.Time
+	current     int
+	previous    int
+	total       int64
+	rejected    int64
+}
+
+// Limiter implements a sliding window counter per caller: the number of requests in
+// the current window is added to the previous window's count, weighted by how much of
+// the previous window still overlaps the sliding window.
+type Limiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	overrides map[string]int
+	counters  map[string]*counter
+	now       func() time.Time
+	// swept is when idle counters were last evicted
+	swept time.Time
+}
+
+// New creates a Limiter allowing limit requests per window for each caller. Overrides
+// replace the limit for specific callers, a limit of zero or less means unlimited.
+func New(limit int, window time.Duration, overrides map[string]int) *Limiter {
+	return &Limiter{
+		limit:     limit,
+		window:    window,
+		overrides: overrides,
+		counters:  make(map[string]*counter),
+		now:       time.Now,
+	}
+}
+
+// Update changes the limits, keeping the counters of every caller
+func (l *Limiter) Update(limit int, window time.Duration, overrides map[string]int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.overrides = overrides
+	if window != l.window {
+		// counters are only meaningful for the window they were recorded with
+		l.window = window
+		for _, c := range l.counters {
+			c.current, c.previous = 0, 0
+		}
+	}
+}
+
+func (l *Limiter) limitFor(caller string) int {
+	if limit, ok := l.overrides[caller]; ok {
+		return limit
+	}
+	return l.limit
+}
+
+// advance moves the counter forward to the window containing now
+func (l *Limiter) advance(c *counter, now time.Time) {
+	elapsed := now.Sub(c.windowStart)
+	if elapsed < l.window {
+		return
+	}
+	if elapsed < 2*l.window {
+		c.previous = c.current
+	} else {
+		c.previous = 0
+	}
+	c.current = 0
+	c.windowStart = c.windowStart.Add(elapsed.Truncate(l.window))
+}
+
+func (l *Limiter) estimate(c *counter, now time.Time) float64 {
+	// multiplied before dividing so that estimates at whole fractions of the window are exact
+	return float64(c.previous)*float64(l.window-now.Sub(c.windowStart))/float64(l.window) + float64(c.current)
+}
+
+// Allow records a request for the caller and reports whether it is within quota
+func (l *Limiter) Allow(caller string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.swept) >= l.window {
+		l.sweep(now)
+	}
+	c, ok := l.counters[caller]
+	if !ok {
+		c = &counter{windowStart: now}
+		l.counters[caller] = c
+	}
+	l.advance(c, now)
+	c.total++
+
+	limit := l.limitFor(caller)
+	reset := l.window - now.Sub(c.windowStart)
+	if limit <= 0 {
+		c.current++
+		return Result{Allowed: true, Limit: limit, Remaining: -1, Reset: reset}
+	}
+
+	used := l.estimate(c, now)
+	if used+1 > float64(limit) {
+		c.rejected++
+		return Result{
+			Limit:      limit,
+			Reset:      reset,
+			RetryAfter: l.retryAfter(c, now, limit),
+		}
+	}
+	c.current++
+	return Result{
+		Allowed:   true,
+		Limit:     limit,
+		Remaining: int(math.Max(0, math.Floor(float64(limit)-used-1))),
+		Reset:     reset,
+	}
+}
+
+// sweep evicts the counters of callers without requests in the last two windows, which no
+// longer count towards any limit, so callers that come and go do not accumulate
+func (l *Limiter) sweep(now time.Time) {
+	for caller, c := range l.counters {
+		if now.Sub(c.windowStart) >= 2*l.window {
+			delete(l.counters, caller)
+		}
+	}
+	l.swept = now
+}
+
+// retryAfter returns how long until the sliding window estimate leaves room for one more request
+func (l *Limiter) retryAfter(c *counter, now time.Time, limit int) time.Duration {
+	elapsed := float64(now.Sub(c.windowStart))
+	window := float64(l.window)
+	if c.current < limit {
+		// the previous window's share has to decay to at most limit-current-1 requests
+		wait := window*float64(c.previous-(limit-c.current-1))/float64(c.previous) - elapsed
+		return time.Duration(math.Max(math.Ceil(wait), 0))
+	}
+	// wait for the current window to become the previous one and decay to at most limit-1 requests
+	wait := window - elapsed + window*float64(c.current-(limit-1))/float64(c.current)
+	return time.Duration(math.Ceil(wait))
+}
+
+// Usage returns the consumption of the callers seen in the last two windows, sorted by caller
+func (l *Limiter) Usage() []Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	usage := make([]Usage, 0, len(l.counters))
+	for caller, c := range l.counters {
+		l.advance(c, now)
+		usage = append(usage, Usage{
+			Caller:   caller,
+			Limit:    l.limitFor(caller),
+			Used:     int(math.Ceil(l.estimate(c, now))),
+			Total:    c.total,
+			Rejected: c.rejected,
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Caller < usage[j].Caller })
+	return usage
+}
@@ -0,0 +1,110 @@
+package quota
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLimiterSlidingWindow(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(2, time.Minute, map[string]int{"vip": 5})
+	l.now = func() time.Time { return now }
+
+	if !l.Allow("team-a").Allowed || !l.Allow("team-a").Allowed {
+		t.Fatal("expected the first two requests to be allowed")
+	}
+	res := l.Allow("team-a")
+	if res.Allowed {
+		t.Fatal("expected the third request to be rejected")
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > 2*time.Minute {
+		t.Errorf("unexpected retry after %v", res.RetryAfter)
+	}
+
+	// other callers have their own quota
+	for i := 0; i < 5; i++ {
+		if !l.Allow("vip").Allowed {
+			t.Fatalf("expected vip request %d to be allowed", i)
+		}
+	}
+
+	// half way through the next window, half of the previous window still counts
+	now = now.Add(90 * time.Second)
+	if res := l.Allow("team-a"); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected one more request to fit, got %+v", res)
+	}
+	if l.Allow("team-a").Allowed {
+		t.Errorf("expected the quota to be exhausted again")
+	}
+
+	usage := l.Usage()
+	if len(usage) != 2 || usage[0].Caller != "team-a" || usage[0].Total != 5 || usage[0].Rejected != 2 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+}
+
+func TestLimiterEvictsIdleCallers(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(2, time.Minute, nil)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 100; i++ {
+		l.Allow(fmt.Sprintf("rotating-%d", i))
+	}
+	now = now.Add(90 * time.Second)
+	l.Allow("team-a")
+	if len(l.counters) != 101 {
+		t.Fatalf("expected callers of the previous window to be kept, got %d", len(l.counters))
+	}
+	now = now.Add(2 * time.Minute)
+	l.Allow("team-a")
+	if len(l.counters) != 1 || l.counters["team-a"] == nil {
+		t.Errorf("expected idle callers to be evicted, got %d counters", len(l.counters))
+	}
+}
+
+func TestLimiterRetryAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		allowed []time.Duration
+		retry   time.Duration
+	}{
+		// the current window is full, it has to become the previous one and decay
+		{"current window", 3, []time.Duration{0, 0, 0}, 80 * time.Second},
+		// the previous window still holds a share of the quota
+		{"previous window", 3, []time.Duration{0, 0, 0, 80 * time.Second}, 100 * time.Second},
+		{"uneven", 7, []time.Duration{0, 0, 0, 0, 0, 0, 0, 70 * time.Second}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+			now := start
+			l := New(tt.limit, time.Minute, nil)
+			l.now = func() time.Time { return now }
+			for i, at := range tt.allowed {
+				now = start.Add(at)
+				if !l.Allow("team-a").Allowed {
+					t.Fatalf("expected request %d to be allowed", i)
+				}
+			}
+			res := l.Allow("team-a")
+			if res.Allowed {
+				t.Fatal("expected the quota to be exhausted")
+			}
+			if tt.retry != 0 && now.Add(res.RetryAfter) != start.Add(tt.retry) {
+				t.Errorf("expected to retry after %v, got %v", start.Add(tt.retry).Sub(now), res.RetryAfter)
+			}
+			retry := now.Add(res.RetryAfter)
+			now = retry.Add(-time.Millisecond)
+			if l.Allow("team-a").Allowed {
+				t.Errorf("expected a retry before %v to be rejected", res.RetryAfter)
+			}
+			now = retry
+			if res := l.Allow("team-a"); !res.Allowed {
+				t.Errorf("expected a retry after exactly %v to be allowed, got %+v", res.RetryAfter, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+// Package recorder learns the API description of an upstream from the calls proxied to
+// it. Only the structure of requests and responses is recorded, never their values.
+package recorder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kosha/passthrough-connector/pkg/parser"
+)
+
+// MaxOperations bounds the operations recorded per route, so paths with ids that are not
+// recognized as such do not grow the recording forever
+const MaxOperations = 500
+
+// MaxParameters bounds the query parameters recorded per operation, parameters first seen
+// after it is reached are ignored
+const MaxParameters = 50
+
+// MaxProperties bounds the properties recorded per object. Objects with more properties, or
+// keyed by ids, are maps whose values are recorded together as their additional properties.
+const MaxProperties = 100
+
+// ErrNotFound is returned when nothing was recorded for a route
+var ErrNotFound = errors.New("no traffic recorded for the route")
+
+// Recorder accumulates the operations observed on every route. The recording is kept in
+// memory and, when it has a file, saved to it and merged with it when it is opened.
+type Recorder struct {
+	path string
+
+	mu     sync.Mutex
+	routes map[string]*API
+	dirty  bool
+}
+
+// API is what was observed on a route
+type API struct {
+	Server string `json:"server,omitempty"`
+	// Operations are keyed by method and path template
+	Operations map[string]*Operation `json:"operations"`
+}
+
+// Operation is what was observed of the calls to a method and path template
+type Operation struct {
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Samples    int               `json:"samples"`
+	LastSeen   time.Time         `json:"lastSeen"`
+	PathParams map[string]*Shape `json:"pathParams,omitempty"`
+	Query      map[string]*Shape `json:"query,omitempty"`
+	// Request is the shape of the request bodies, nil when none was sent
+	Request *Shape `json:"request,omitempty"`
+	// Responses are keyed by status code, their shape is empty when they had no body
+	Responses map[string]*Shape `json:"responses"`
+}
+
+// Summary describes the recording of a route
+type Summary struct {
+	Route      string    `json:"route"`
+	Operations int       `json:"operations"`
+	Samples    int       `json:"samples"`
+	LastSeen   time.Time `json:"lastSeen"`
+}
+
+// Open returns a recorder merging observations with those saved in the file at path. A
+// missing file is an empty recording and an empty path keeps the recording in memory.
+func Open(path string) (*Recorder, error) {
+	r := &Recorder{path: path, routes: make(map[string]*API)}
+	if path == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &r.routes); err != nil {
+		return nil, fmt.Errorf("invalid recording %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Observe records a call to the upstream server of a route: the request uri, relative to
+// the server, and the decoded JSON bodies of the request and the response, nil when they
+// had none
+func (r *Recorder) Observe(route, server, method, requestUri string, body interface{}, status int, response interface{}) {
+	u, err := url.ParseRequestURI(requestUri)
+	if err != nil {
+		return
+	}
+	template, params := Template(u.Path)
+	method = strings.ToUpper(method)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	api, ok := r.routes[route]
+	if !ok {
+		api = &API{Operations: make(map[string]*Operation)}
+		r.routes[route] = api
+	}
+	if api.Server == "" {
+		api.Server = server
+	}
+	key := method + " " + template
+	op, ok := api.Operations[key]
+	if !ok {
+		if len(api.Operations) >= MaxOperations {
+			return
+		}
+		op = &Operation{Method: method, Path: template, Responses: make(map[string]*Shape)}
+		api.Operations[key] = op
+	}
+	r.dirty = true
+
+	op.Samples++
+	op.LastSeen = time.Now().UTC()
+	for name, value := range params {
+		if op.PathParams == nil {
+			op.PathParams = make(map[string]*Shape)
+		}
+		shape(op.PathParams, name).observeParameter(value)
+	}
+	for name, values := range u.Query() {
+		if op.Query == nil {
+			op.Query = make(map[string]*Shape)
+		}
+		if op.Query[name] == nil && len(op.Query) >= MaxParameters {
+			continue
+		}
+		shape(op.Query, name).observeParameter(values[0])
+	}
+	if body != nil {
+		if op.Request == nil {
+			op.Request = &Shape{}
+		}
+		op.Request.observe(body, 0)
+	}
+	code := strconv.Itoa(status)
+	if op.Responses[code] == nil {
+		op.Responses[code] = &Shape{}
+	}
+	if response != nil {
+		op.Responses[code].observe(response, 0)
+	}
+}
+
+func shape(shapes map[string]*Shape, name string) *Shape {
+	if shapes[name] == nil {
+		shapes[name] = &Shape{}
+	}
+	return shapes[name]
+}
+
+// Routes summarizes the recording of every route, by route name
+func (r *Recorder) Routes() []Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	summaries := make([]Summary, 0, len(r.routes))
+	for name, api := range r.routes {
+		summary := Summary{Route: name, Operations: len(api.Operations)}
+		for _, op := range api.Operations {
+			summary.Samples += op.Samples
+			if op.LastSeen.After(summary.LastSeen) {
+				summary.LastSeen = op.LastSeen
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Route < summaries[j].Route })
+	return summaries
+}
+
+// Forget drops the recording of a route
+func (r *Recorder) Forget(route string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.routes[route]; !ok {
+		return ErrNotFound
+	}
+	delete(r.routes, route)
+	r.dirty = true
+	return nil
+}
+
+// Document returns the API description of what was observed on a route
+func (r *Recorder) Document(route string) (*parser.Document, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	api, ok := r.routes[route]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	doc := &parser.Document{Title: route, Version: "recorded"}
+	if api.Server != "" {
+		doc.Servers = []string{api.Server}
+	}
+	for _, recorded := range api.Operations {
+		op := &parser.Operation{
+			Method:      recorded.Method,
+			Path:        recorded.Path,
+			Description: fmt.Sprintf("Recorded from %d calls, last on %s.", recorded.Samples, recorded.LastSeen.Format(time.RFC3339)),
+			Responses:   make(map[string]*parser.Response),
+		}
+		for _, name := range sortedNames(recorded.PathParams) {
+			op.Parameters = append(op.Parameters, &parser.Parameter{
+				Name: name, In: parser.InPath, Required: true, Schema: recorded.PathParams[name].Schema(),
+			})
+		}
+		for _, name := range sortedNames(recorded.Query) {
+			query := recorded.Query[name]
+			op.Parameters = append(op.Parameters, &parser.Parameter{
+				Name: name, In: parser.InQuery, Required: query.Samples == recorded.Samples, Schema: query.Schema(),
+			})
+		}
+		if recorded.Request != nil {
+			op.RequestBody = &parser.RequestBody{
+				Required: recorded.Request.Samples == recorded.Samples,
+				Content:  map[string]*parser.MediaType{"application/json": {Schema: recorded.Request.Schema()}},
+			}
+		}
+		for code, body := range recorded.Responses {
+			status, _ := strconv.Atoi(code)
+			response := &parser.Response{Description: http.StatusText(status)}
+			if body.Samples > 0 {
+				response.Content = map[string]*parser.MediaType{"application/json": {Schema: body.Schema()}}
+			}
+			op.Responses[code] = response
+		}
+		doc.Operations = append(doc.Operations, op)
+	}
+	doc.Index()
+	return doc, nil
+}
+
+func sortedNames(shapes map[string]*Shape) []string {
+	names := make([]string, 0, len(shapes))
+	for name := range shapes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetPath changes the file the recording is saved to, an empty path keeping it in memory.
+// Routes recorded in the file and not in memory are kept and the recording is saved to the
+// file on the next Save.
+func (r *Recorder) SetPath(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if path == r.path {
+		return nil
+	}
+	if path != "" {
+		saved, err := Open(path)
+		if err != nil {
+			return err
+		}
+		for route, api := range saved.routes {
+			if _, ok := r.routes[route]; !ok {
+				r.routes[route] = api
+			}
+		}
+		r.dirty = len(r.routes) > 0
+	}
+	r.path = path
+	return nil
+}
+
+// Save writes the recording to its file when it changed since it was last saved. The file
+// is replaced as a whole so a crash never leaves a partial recording behind.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.path == "" || !r.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.routes, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return err
+	}
+	r.dirty = false
+	return nil
+}
@@ -0,0 +1,168 @@
+package recorder
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/tickets":            "/tickets",
+		"/tickets/7":          "/tickets/{ticketId}",
+		"/tickets/7/notes/12": "/tickets/{ticketId}/notes/{noteId}",
+		"/companies/3fa85f64-5717-4562-b3fc-2c963f66afa6": "/companies/{companyId}",
+		"/users/me":            "/users/me",
+		"/v2/files/a1b2c3d4e5": "/v2/files/{fileId}",
+		"/7/8":                 "/{id}/{id2}",
+	} {
+		if template, _ := Template(path); template != expected {
+			t.Errorf("%s: expected %s, got %s", path, expected, template)
+		}
+	}
+	if _, params := Template("/tickets/7/notes/12"); !reflect.DeepEqual(params, map[string]string{"ticketId": "7", "noteId": "12"}) {
+		t.Errorf("unexpected parameters %v", params)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.json")
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Observe("tickets", "https://acme.example.com/api", "GET", "/tickets/7?fields=subject", nil, 200,
+		map[string]interface{}{"id": 7.0, "subject": "Printer on fire", "created": "2024-05-01T10:00:00Z", "tags": []interface{}{"printer"}})
+	r.Observe("tickets", "https://acme.example.com/api", "GET", "/tickets/8", nil, 200,
+		map[string]interface{}{"id": 8.0, "subject": "Coffee machine", "created": "2024-05-02T10:00:00Z", "assignee": nil})
+	r.Observe("tickets", "https://acme.example.com/api", "GET", "/tickets/9", nil, 404, nil)
+	if err := r.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// observations are merged with the saved recording
+	r, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Observe("tickets", "https://acme.example.com/api", "POST", "/tickets", map[string]interface{}{"subject": "Lights out", "priority": 2.5}, 201,
+		map[string]interface{}{"id": 10.0})
+
+	doc, err := r.Document("tickets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0] != "https://acme.example.com/api" || len(doc.Operations) != 2 {
+		t.Fatalf("unexpected document %+v", doc)
+	}
+	get, params := doc.Match("GET", "/tickets/42")
+	if get == nil || params["ticketId"] != "42" {
+		t.Fatalf("expected the path template to match, got %+v %v", get, params)
+	}
+	if get.Parameters[0].Schema.Type != "integer" || get.Parameters[1].Name != "fields" || get.Parameters[1].Required {
+		t.Errorf("unexpected parameters %+v %+v", get.Parameters[0], get.Parameters[1])
+	}
+	ticket := get.Responses["200"].Content["application/json"].Schema
+	if !reflect.DeepEqual(ticket.Required, []string{"created", "id", "subject"}) {
+		t.Errorf("expected the fields present in every response to be required, got %v", ticket.Required)
+	}
+	if ticket.Properties["created"].Format != "date-time" || !ticket.Properties["assignee"].Nullable || ticket.Properties["tags"].Items.Type != "string" {
+		t.Errorf("unexpected schema %+v", ticket.Properties)
+	}
+	if missing := get.Responses["404"]; missing == nil || missing.Content != nil || missing.Description != "Not Found" {
+		t.Errorf("expected a response without content, got %+v", missing)
+	}
+
+	create, _ := doc.Match("POST", "/tickets")
+	body := create.RequestBody.Content["application/json"].Schema
+	if !create.RequestBody.Required || body.Properties["priority"].Type != "number" {
+		t.Errorf("unexpected request body %+v", body)
+	}
+
+	if summaries := r.Routes(); len(summaries) != 1 || summaries[0].Operations != 2 || summaries[0].Samples != 4 {
+		t.Errorf("unexpected summaries %+v", summaries)
+	}
+	if err := r.Forget("tickets"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Document("tickets"); err != ErrNotFound {
+		t.Errorf("expected the recording to be forgotten, got %v", err)
+	}
+}
+
+func TestShapeTypes(t *testing.T) {
+	s := &Shape{}
+	for _, value := range []interface{}{1.0, 2.5, "three"} {
+		s.observe(value, 0)
+	}
+	schema := s.Schema()
+	if len(schema.AnyOf) != 2 || schema.AnyOf[0].Type != "number" || schema.AnyOf[1].Type != "string" {
+		t.Errorf("expected numbers and strings, got %+v", schema.AnyOf)
+	}
+}
+
+func TestShapeBounded(t *testing.T) {
+	wide := make(map[string]interface{})
+	for i := 0; i < MaxProperties+1; i++ {
+		wide[fmt.Sprint("field", i)] = "value"
+	}
+	s := &Shape{}
+	s.observe(map[string]interface{}{"users": map[string]interface{}{"7": map[string]interface{}{"name": "ann"}}, "wide": wide}, 0)
+	s.observe(map[string]interface{}{"users": map[string]interface{}{"8": map[string]interface{}{"name": "bob"}}}, 0)
+	schema := s.Schema()
+	users := schema.Properties["users"]
+	if len(users.Properties) != 0 || users.AdditionalProperties == nil || users.AdditionalProperties.Properties["name"].Type != "string" {
+		t.Errorf("expected objects keyed by ids to be maps, got %+v", users)
+	}
+	if users.AdditionalProperties.Required[0] != "name" {
+		t.Errorf("expected the values of maps to be recorded together, got %+v", users.AdditionalProperties)
+	}
+	if w := schema.Properties["wide"]; len(w.Properties) != 0 || w.AdditionalProperties == nil || w.AdditionalProperties.Type != "string" {
+		t.Errorf("expected objects with too many properties to be maps, got %+v", w)
+	}
+
+	r, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := url.Values{}
+	for i := 0; i < 2*MaxParameters; i++ {
+		query.Set(fmt.Sprint("key", i), "x")
+	}
+	r.Observe("tickets", "", "GET", "/tickets?"+query.Encode(), nil, 200, nil)
+	if op := r.routes["tickets"].Operations["GET /tickets"]; len(op.Query) != MaxParameters {
+		t.Errorf("expected at most %d query parameters, got %d", MaxParameters, len(op.Query))
+	}
+}
+
+func TestRecorderSetPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.json")
+	saved, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved.Observe("users", "https://acme.example.com", "GET", "/users", nil, 200, nil)
+	if err := saved.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Observe("tickets", "https://acme.example.com", "GET", "/tickets", nil, 200, nil)
+	if err := r.SetPath(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if r, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if routes := r.Routes(); len(routes) != 2 {
+		t.Errorf("expected the recording in memory and in the file to be saved, got %+v", routes)
+	}
+}
@@ -0,0 +1,237 @@
+package recorder
+
+import (
+	"math"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/kosha/passthrough-connector/pkg/parser"
+)
+
+// maxDepth bounds how deep observed values are walked
+const maxDepth = 16
+
+// Shape is the structure of the samples of a JSON value: how many samples had each type,
+// the properties of objects and the items of arrays. Values themselves are not kept.
+type Shape struct {
+	Samples    int               `json:"samples"`
+	Types      map[string]int    `json:"types"`
+	Formats    map[string]int    `json:"formats,omitempty"`
+	Properties map[string]*Shape `json:"properties,omitempty"`
+	// Additional is the shape of the values of map-like objects, which have no Properties
+	Additional *Shape `json:"additionalProperties,omitempty"`
+	Items      *Shape `json:"items,omitempty"`
+}
+
+// formats are the string formats recognized in samples
+var formats = []struct {
+	name  string
+	match func(string) bool
+}{
+	{"date-time", func(v string) bool {
+		_, err := time.Parse(time.RFC3339, v)
+		return err == nil
+	}},
+	{"date", func(v string) bool {
+		_, err := time.Parse("2006-01-02", v)
+		return err == nil
+	}},
+	{"uuid", regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString},
+	{"email", func(v string) bool {
+		address, err := mail.ParseAddress(v)
+		return err == nil && address.Address == v
+	}},
+}
+
+func (s *Shape) observe(value interface{}, depth int) {
+	s.Samples++
+	if s.Types == nil {
+		s.Types = make(map[string]int)
+	}
+	switch v := value.(type) {
+	case nil:
+		s.Types["null"]++
+	case bool:
+		s.Types["boolean"]++
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			s.Types["integer"]++
+		} else {
+			s.Types["number"]++
+		}
+	case string:
+		s.Types["string"]++
+		for _, format := range formats {
+			if format.match(v) {
+				if s.Formats == nil {
+					s.Formats = make(map[string]int)
+				}
+				s.Formats[format.name]++
+				break
+			}
+		}
+	case []interface{}:
+		s.Types["array"]++
+		if depth >= maxDepth {
+			return
+		}
+		for _, item := range v {
+			if s.Items == nil {
+				s.Items = &Shape{}
+			}
+			s.Items.observe(item, depth+1)
+		}
+	case map[string]interface{}:
+		s.Types["object"]++
+		if depth >= maxDepth {
+			return
+		}
+		for name, property := range v {
+			if s.Additional == nil && s.Properties[name] == nil && (isMapKey(name) || len(s.Properties) >= MaxProperties) {
+				s.collapse()
+			}
+			if s.Additional != nil {
+				s.Additional.observe(property, depth+1)
+				continue
+			}
+			if s.Properties == nil {
+				s.Properties = make(map[string]*Shape)
+			}
+			shape(s.Properties, name).observe(property, depth+1)
+		}
+	}
+}
+
+// isMapKey returns whether a property name is the key of a map rather than a field, a
+// number or a UUID
+func isMapKey(name string) bool {
+	return numeric.MatchString(name) || uuid.MatchString(name)
+}
+
+// collapse records the properties of the map-like object s together, as its additional
+// properties
+func (s *Shape) collapse() {
+	s.Additional = &Shape{}
+	for _, property := range s.Properties {
+		s.Additional.merge(property)
+	}
+	s.Properties = nil
+}
+
+// merge adds the samples of o to s
+func (s *Shape) merge(o *Shape) {
+	s.Samples += o.Samples
+	for t, count := range o.Types {
+		if s.Types == nil {
+			s.Types = make(map[string]int)
+		}
+		s.Types[t] += count
+	}
+	for format, count := range o.Formats {
+		if s.Formats == nil {
+			s.Formats = make(map[string]int)
+		}
+		s.Formats[format] += count
+	}
+	if o.Items != nil {
+		if s.Items == nil {
+			s.Items = &Shape{}
+		}
+		s.Items.merge(o.Items)
+	}
+	added := 0
+	for name := range o.Properties {
+		if s.Properties[name] == nil {
+			added++
+		}
+	}
+	if s.Additional == nil && (o.Additional != nil || len(s.Properties)+added > MaxProperties) {
+		s.collapse()
+	}
+	if s.Additional != nil {
+		if o.Additional != nil {
+			s.Additional.merge(o.Additional)
+		}
+		for _, property := range o.Properties {
+			s.Additional.merge(property)
+		}
+		return
+	}
+	for name, property := range o.Properties {
+		if s.Properties == nil {
+			s.Properties = make(map[string]*Shape)
+		}
+		shape(s.Properties, name).merge(property)
+	}
+}
+
+// observeParameter records a path or query parameter, typed after the value it parses as
+func (s *Shape) observeParameter(value string) {
+	if f, err := strconv.ParseFloat(value, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		s.observe(f, 0)
+	} else if b, err := strconv.ParseBool(value); err == nil {
+		s.observe(b, 0)
+	} else {
+		s.observe(value, 0)
+	}
+}
+
+// Schema returns the schema of the samples. Integers and numbers observed together are
+// numbers, properties present in every object are required and values of several types
+// are any of them.
+func (s *Shape) Schema() *parser.Schema {
+	schema := &parser.Schema{Nullable: s.Types["null"] > 0}
+	var types []string
+	for t, count := range s.Types {
+		if t == "null" || count == 0 || t == "integer" && s.Types["number"] > 0 {
+			continue
+		}
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	switch len(types) {
+	case 0:
+		return schema
+	case 1:
+		s.fill(schema, types[0])
+	default:
+		for _, t := range types {
+			alternative := &parser.Schema{}
+			s.fill(alternative, t)
+			schema.AnyOf = append(schema.AnyOf, alternative)
+		}
+	}
+	return schema
+}
+
+// fill describes the samples of type t in schema
+func (s *Shape) fill(schema *parser.Schema, t string) {
+	schema.Type = t
+	switch t {
+	case "string":
+		for format, count := range s.Formats {
+			if count == s.Types["string"] {
+				schema.Format = format
+			}
+		}
+	case "array":
+		if s.Items != nil {
+			schema.Items = s.Items.Schema()
+		}
+	case "object":
+		if s.Additional != nil {
+			schema.AdditionalProperties = s.Additional.Schema()
+		}
+		schema.Properties = make(map[string]*parser.Schema, len(s.Properties))
+		for name, property := range s.Properties {
+			schema.Properties[name] = property.Schema()
+			if property.Samples == s.Types["object"] {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		sort.Strings(schema.Required)
+	}
+}
@@ -0,0 +1,65 @@
+package recorder
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	numeric = regexp.MustCompile(`^[0-9]+$`)
+	uuid    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	token   = regexp.MustCompile(`^[A-Za-z0-9_-]{8,}$`)
+	digit   = regexp.MustCompile(`[0-9]`)
+	letter  = regexp.MustCompile(`[A-Za-z]`)
+)
+
+// isIdentifier returns whether a path segment looks like the id of a resource: a number,
+// a UUID, or a token of at least 8 characters mixing letters and digits
+func isIdentifier(segment string) bool {
+	return numeric.MatchString(segment) || uuid.MatchString(segment) ||
+		token.MatchString(segment) && digit.MatchString(segment) && letter.MatchString(segment)
+}
+
+// Template returns the path template of path, replacing the segments that look like ids
+// with parameters named after the collection they follow, e.g. /tickets/7 is
+// /tickets/{ticketId}, along with the value of every parameter
+func Template(path string) (string, map[string]string) {
+	segments := strings.Split(path, "/")
+	params := make(map[string]string)
+	for i, segment := range segments {
+		if segment == "" || !isIdentifier(segment) {
+			continue
+		}
+		name := "id"
+		if i > 0 && segments[i-1] != "" && !strings.HasPrefix(segments[i-1], "{") {
+			name = singular(segments[i-1]) + "Id"
+		}
+		unique := name
+		for n := 2; params[unique] != ""; n++ {
+			unique = name + strconv.Itoa(n)
+		}
+		params[unique] = segment
+		segments[i] = "{" + unique + "}"
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// singular returns the singular of an English plural, good enough for collection names
+func singular(word string) string {
+	word = strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' {
+			return -1
+		}
+		return r
+	}, word)
+	switch {
+	case strings.HasSuffix(word, "ies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "sses"), strings.HasSuffix(word, "xes"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
@@ -0,0 +1,181 @@
+// Package redact masks sensitive values in JSON payloads, headers and log text
+// before they leave the connector.
+package redact
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Mask is the placeholder written in place of a redacted value
+const Mask = "[REDACTED]"
+
+// DefaultHeaders are always treated as sensitive, regardless of configuration
+var DefaultHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// authSchemes are the HTTP auth schemes whose credentials follow them after a space
+const authSchemes = `(?:Bearer|Basic|Digest|Token|Negotiate|NTLM|ApiKey|HMAC|OAuth|AWS4-HMAC-SHA256)\b`
+
+type pattern struct {
+	segments []string
+	anchored bool
+}
+
+// Redactor masks values matching a set of JSON path patterns and header names.
+//
+// Field patterns are dot separated key paths. A pattern starting with "$." is
+// anchored at the document root, any other pattern matches at any depth (so
+// "password" masks every "password" key). A "*" segment matches any single key
+// and arrays are traversed transparently, so "employees.ssn" matches the "ssn"
+// key of every element of the "employees" array.
+type Redactor struct {
+	patterns []pattern
+	headers  map[string]bool
+	text     *regexp.Regexp
+}
+
+// New creates a Redactor for the given field patterns and header names
+func New(fields, headers []string) *Redactor {
+	r := &Redactor{headers: make(map[string]bool)}
+
+	keys := make(map[string]bool)
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		p := pattern{}
+		if strings.HasPrefix(field, "$.") {
+			p.anchored = true
+			field = strings.TrimPrefix(field, "$.")
+		}
+		p.segments = strings.Split(field, ".")
+		r.patterns = append(r.patterns, p)
+		if last := p.segments[len(p.segments)-1]; last != "*" {
+			keys[strings.ToLower(last)] = true
+		}
+	}
+
+	for _, header := range append(DefaultHeaders, headers...) {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		r.headers[http.CanonicalHeaderKey(header)] = true
+		keys[strings.ToLower(header)] = true
+	}
+
+	var quoted []string
+	for key := range keys {
+		quoted = append(quoted, regexp.QuoteMeta(key))
+	}
+	if len(quoted) > 0 {
+		// matches `"key": "value"`, `key=value` and `key:value` (as printed by %v for maps). An
+		// auth scheme is masked along with the credentials following it, as in `Bearer abc`.
+		r.text = regexp.MustCompile(`(?i)("?\b(?:` + strings.Join(quoted, "|") + `)"?\s*[:=]\s*)("(?:[^"\\]|\\.)*"|` + authSchemes + `[ \t]+[^\s,;&}\]]+|[^\s,;&}\]]+)`)
+	}
+	return r
+}
+
+// Value returns a copy of v with every field matching a pattern replaced by Mask.
+// Values that are not decoded JSON (maps, slices and scalars) are returned unchanged.
+func (r *Redactor) Value(v interface{}) interface{} {
+	if r == nil || len(r.patterns) == 0 {
+		return v
+	}
+	return r.walk(v, nil)
+}
+
+func (r *Redactor) walk(v interface{}, path []string) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			childPath := append(path[:len(path):len(path)], k)
+			if r.matches(childPath) {
+				out[k] = Mask
+				continue
+			}
+			out[k] = r.walk(child, childPath)
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]string, len(val))
+		for k, child := range val {
+			if r.matches(append(path[:len(path):len(path)], k)) {
+				out[k] = Mask
+				continue
+			}
+			out[k] = child
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, child := range val {
+			out[i] = r.walk(child, path)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func (r *Redactor) matches(path []string) bool {
+	for _, p := range r.patterns {
+		if p.anchored && len(p.segments) != len(path) {
+			continue
+		}
+		if len(p.segments) > len(path) {
+			continue
+		}
+		offset := len(path) - len(p.segments)
+		matched := true
+		for i, segment := range p.segments {
+			if segment != "*" && !strings.EqualFold(segment, path[offset+i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSensitiveHeader reports whether the named header is redacted
+func (r *Redactor) IsSensitiveHeader(name string) bool {
+	if r == nil {
+		return false
+	}
+	return r.headers[http.CanonicalHeaderKey(name)]
+}
+
+// IsSensitiveKey reports whether a top level key or header of that name is redacted
+func (r *Redactor) IsSensitiveKey(key string) bool {
+	if r == nil {
+		return false
+	}
+	return r.IsSensitiveHeader(key) || r.matches([]string{key})
+}
+
+// Headers returns a copy of headers with sensitive values replaced by Mask
+func (r *Redactor) Headers(headers map[string]string) map[string]string {
+	out := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if r.IsSensitiveHeader(k) {
+			v = Mask
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// String masks `key: value` and `key=value` occurrences of sensitive keys in free-form text
+func (r *Redactor) String(s string) string {
+	if r == nil || r.text == nil {
+		return s
+	}
+	return r.text.ReplaceAllString(s, "${1}"+Mask)
+}
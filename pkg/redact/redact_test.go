@@ -0,0 +1,65 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValue(t *testing.T) {
+	r := New([]string{"password", "$.user.ssn", "employees.*.token"}, nil)
+
+	in := map[string]interface{}{
+		"password": "secret",
+		"user": map[string]interface{}{
+			"ssn":      "123-45-6789",
+			"password": "nested",
+			"name":     "jane",
+		},
+		"employees": []interface{}{
+			map[string]interface{}{"ssn": "987-65-4321", "auth": map[string]interface{}{"token": "abc"}},
+		},
+	}
+
+	out := r.Value(in).(map[string]interface{})
+	if out["password"] != Mask {
+		t.Errorf("top level password was not redacted: %v", out["password"])
+	}
+	user := out["user"].(map[string]interface{})
+	if user["ssn"] != Mask || user["password"] != Mask || user["name"] != "jane" {
+		t.Errorf("unexpected user object: %v", user)
+	}
+	employee := out["employees"].([]interface{})[0].(map[string]interface{})
+	if employee["ssn"] != "987-65-4321" {
+		t.Errorf("anchored pattern matched outside the root: %v", employee)
+	}
+	if employee["auth"].(map[string]interface{})["token"] != Mask {
+		t.Errorf("wildcard pattern did not match: %v", employee)
+	}
+	// the input must not be modified
+	if in["password"] != "secret" {
+		t.Errorf("input was modified")
+	}
+}
+
+func TestString(t *testing.T) {
+	r := New([]string{"password"}, []string{"X-Secret"})
+
+	s := r.String(`{"password": "hunter2", "name": "jane"} x-secret=abc map[Authorization:Bearer tok3n] Authorization: Basic dXNlcjpwYXNz`)
+	for _, leaked := range []string{"hunter2", "abc", "tok3n", "dXNlcjpwYXNz", "Bearer"} {
+		if strings.Contains(s, leaked) {
+			t.Errorf("%q leaked in %q", leaked, s)
+		}
+	}
+	if !strings.Contains(s, "jane") {
+		t.Errorf("non sensitive value was redacted: %q", s)
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	r := New(nil, []string{"x-custom-key"})
+
+	out := r.Headers(map[string]string{"Authorization": "Basic abc", "X-Custom-Key": "123", "Accept": "*/*"})
+	if out["Authorization"] != Mask || out["X-Custom-Key"] != Mask || out["Accept"] != "*/*" {
+		t.Errorf("unexpected headers: %v", out)
+	}
+}
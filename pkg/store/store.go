@@ -0,0 +1,127 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+)
+
+// ErrNotFound is returned when no route with the requested name is stored
+var ErrNotFound = errors.New("route not found")
+
+// Store keeps upstream routes managed at runtime in a JSON file, in the same format as ROUTES
+type Store struct {
+	path string
+
+	mu     sync.RWMutex
+	routes map[string]config.Route
+}
+
+// Open reads the routes stored in path. A missing file is an empty store.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, routes: make(map[string]config.Route)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var routes []config.Route
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return nil, fmt.Errorf("invalid store %s: %w", path, err)
+	}
+	for _, route := range routes {
+		s.routes[route.Name] = route
+	}
+	return s, nil
+}
+
+// List returns the stored routes ordered by name
+func (s *Store) List() []config.Route {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.list()
+}
+
+func (s *Store) list() []config.Route {
+	routes := make([]config.Route, 0, len(s.routes))
+	for _, route := range s.routes {
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Name < routes[j].Name })
+	return routes
+}
+
+// Get returns the route with the given name
+func (s *Store) Get(name string) (config.Route, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	route, ok := s.routes[name]
+	if !ok {
+		return config.Route{}, ErrNotFound
+	}
+	return route, nil
+}
+
+// Put adds or replaces the route with the same name and saves the store
+func (s *Store) Put(route config.Route) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, existed := s.routes[route.Name]
+	s.routes[route.Name] = route
+	if err := s.save(); err != nil {
+		if existed {
+			s.routes[route.Name] = previous
+		} else {
+			delete(s.routes, route.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes the route with the given name and saves the store
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	route, ok := s.routes[name]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.routes, name)
+	if err := s.save(); err != nil {
+		s.routes[name] = route
+		return err
+	}
+	return nil
+}
+
+// save writes the routes to a temporary file which then replaces the store, so a crash
+// never leaves a partially written store behind. The file holds credentials and is only
+// readable by its owner.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
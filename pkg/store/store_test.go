@@ -0,0 +1,63 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kosha/passthrough-connector/pkg/config"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "connectors.json")
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.List()) != 0 {
+		t.Fatalf("expected a missing file to be an empty store")
+	}
+
+	duo := config.Route{Name: "duo", Prefix: "/duo", ServerURL: "https://api-1234.duosecurity.com",
+		Auth: config.Auth{Type: "HMAC", IKey: "ikey", SKey: "skey"}}
+	fs := config.Route{Name: "freshservice", Prefix: "/fs", ServerURL: "https://acme.freshservice.com/api/v2",
+		Auth: config.Auth{Type: "API_KEY", ApiKey: "key"}}
+	for _, route := range []config.Route{fs, duo} {
+		if err := s.Put(route); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected the store to be readable by its owner only, got %v", info.Mode().Perm())
+	}
+
+	// the routes survive reopening the store
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := s.List()
+	if len(routes) != 2 || routes[0].Name != "duo" || routes[1].Name != "freshservice" {
+		t.Fatalf("expected duo and freshservice ordered by name, got %+v", routes)
+	}
+	if got, _ := s.Get("duo"); got.Auth.SKey != "skey" {
+		t.Errorf("expected stored credentials to be kept, got %+v", got.Auth)
+	}
+
+	if err := s.Delete("duo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("duo"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := s.Delete("duo"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting a missing route, got %v", err)
+	}
+}